@@ -7,6 +7,7 @@ package cli
 import (
 
 	// Stdlib:
+	"errors"
 	"regexp"
 	"strconv"
 	"strings"
@@ -86,51 +87,9 @@ type quadrupletsValue struct {
 
 func (q *quadrupletsValue) Set(value string) error {
 
-	// 1. Four elements:
-	if quad := strings.Split(value, ":"); len(quad) != 4 {
-		log.WithField("value", value).
-			Fatal("Expected 4 elements, but got " + strconv.Itoa(len(quad)))
-
-		// 2. Positive integer:
-	} else if i, err := strconv.Atoi(quad[0]); err != nil || i < 0 {
-		log.WithField("value", value).
-			Fatal("First quadruplet element must be a positive integer, but got: " + quad[0])
-
-		// 3. Valid instance type:
-	} else if !func() bool {
-		for _, t := range q.types {
-			if t == quad[1] {
-				return true
-			}
-		}
-		return false
-	}() {
-		log.WithField("value", value).
-			Fatal("Second quadruplet element must be a valid instance type, but got: " + quad[1])
-
-		// 4. Valid DNS name:
-	} else if match, err := regexp.MatchString("^[a-z\\d-]+$", quad[2]); err != nil || !match {
-		log.WithField("value", value).
-			Fatal("Third quadruplet element must matmatch ^[a-z\\d-]+$, but got: " + quad[2])
-
-		// 5. Valid Káto roles:
-	} else if !func() bool {
-		for _, role := range strings.Split(quad[3], ",") {
-			found := false
-			for _, r := range q.roles {
-				if r == role {
-					found = true
-					break
-				}
-			}
-			if !found {
-				return false
-			}
-		}
-		return true
-	}() {
-		log.WithField("value", value).
-			Fatal("Fourth quadruplet element must be a valid list of Káto roles, but got: " + quad[3])
+	// Validate the quadruplet:
+	if err := CheckQuadruplet(value, q.types, q.roles); err != nil {
+		log.WithField("value", value).Fatal(err)
 	}
 
 	// All tests ok:
@@ -154,3 +113,51 @@ func Quadruplets(s kingpin.Settings, types, roles []string) *[]string {
 	s.SetValue(target)
 	return &target.quadList
 }
+
+//-----------------------------------------------------------------------------
+// func: CheckQuadruplet
+//-----------------------------------------------------------------------------
+
+// CheckQuadruplet validates a <count>:<type>:<host_name>:<roles> quadruplet
+// against the given instance types and roles.
+func CheckQuadruplet(value string, types, roles []string) error {
+
+	// 1. Four elements:
+	quad := strings.Split(value, ":")
+	if len(quad) != 4 {
+		return errors.New("Expected 4 elements, but got " + strconv.Itoa(len(quad)))
+	}
+
+	// 2. Positive integer:
+	if i, err := strconv.Atoi(quad[0]); err != nil || i < 0 {
+		return errors.New("First quadruplet element must be a positive integer, but got: " + quad[0])
+	}
+
+	// 3. Valid instance type:
+	if !contains(types, quad[1]) {
+		return errors.New("Second quadruplet element must be a valid instance type, but got: " + quad[1])
+	}
+
+	// 4. Valid DNS name:
+	if match, err := regexp.MatchString("^[a-z\\d-]+$", quad[2]); err != nil || !match {
+		return errors.New("Third quadruplet element must match ^[a-z\\d-]+$, but got: " + quad[2])
+	}
+
+	// 5. Valid Káto roles:
+	for _, role := range strings.Split(quad[3], ",") {
+		if !contains(roles, role) {
+			return errors.New("Fourth quadruplet element must be a valid list of Káto roles, but got: " + quad[3])
+		}
+	}
+
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1 +1,24 @@
 package cli
+
+import "testing"
+
+func TestCheckQuadruplet(t *testing.T) {
+
+	types := []string{"m3.large"}
+
+	if err := CheckQuadruplet("3:m3.large:worker:worker,border", types, KatoRoles); err != nil {
+		t.Error(err)
+	}
+
+	for _, in := range []string{
+		"3:m3.large:worker",
+		"-1:m3.large:worker:worker",
+		"3:t2.nano:worker:worker",
+		"3:m3.large:Worker:worker",
+		"3:m3.large:worker:worker,edge",
+	} {
+		if err := CheckQuadruplet(in, types, KatoRoles); err == nil {
+			t.Errorf("CheckQuadruplet(%q): expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package dns
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Fake is an in-memory Provider meant for tests.
+type Fake struct {
+	mutex   sync.Mutex
+	Zones   map[string][]Record
+	Private map[string]string
+}
+
+//-----------------------------------------------------------------------------
+// func: NewFake
+//-----------------------------------------------------------------------------
+
+// NewFake returns an empty in-memory Provider.
+func NewFake() *Fake {
+	return &Fake{Zones: map[string][]Record{}, Private: map[string]string{}}
+}
+
+//-----------------------------------------------------------------------------
+// func: AddZone
+//-----------------------------------------------------------------------------
+
+// AddZone adds an empty zone unless it already exists.
+func (f *Fake) AddZone(zone string) error {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	zone = strings.TrimSuffix(zone, ".")
+	if _, ok := f.Zones[zone]; !ok {
+		f.Zones[zone] = []Record{}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: AddPrivateZone
+//-----------------------------------------------------------------------------
+
+// AddPrivateZone adds an empty zone and records its network and region.
+func (f *Fake) AddPrivateZone(zone, network, region string) error {
+
+	if err := f.AddZone(zone); err != nil {
+		return err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.Private[strings.TrimSuffix(zone, ".")] = network + "/" + region
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: DelZone
+//-----------------------------------------------------------------------------
+
+// DelZone deletes the zone and all its records.
+func (f *Fake) DelZone(zone string) error {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	zone = strings.TrimSuffix(zone, ".")
+	delete(f.Zones, zone)
+	delete(f.Private, zone)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: UpsertRecords
+//-----------------------------------------------------------------------------
+
+// UpsertRecords adds the records or replaces the ones with the same name,
+// type and set identifier.
+func (f *Fake) UpsertRecords(zone string, records []Record) error {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	list, err := f.zone(zone)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if i := find(list, r); i >= 0 {
+			list[i] = r
+		} else {
+			list = append(list, r)
+		}
+	}
+
+	f.Zones[strings.TrimSuffix(zone, ".")] = list
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: DeleteRecords
+//-----------------------------------------------------------------------------
+
+// DeleteRecords deletes the records with the same name, type and set
+// identifier.
+func (f *Fake) DeleteRecords(zone string, records []Record) error {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	list, err := f.zone(zone)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if i := find(list, r); i >= 0 {
+			list = append(list[:i], list[i+1:]...)
+		}
+	}
+
+	f.Zones[strings.TrimSuffix(zone, ".")] = list
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: ListRecords
+//-----------------------------------------------------------------------------
+
+// ListRecords returns the records of the zone sorted by name and type.
+func (f *Fake) ListRecords(zone string) ([]Record, error) {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	list, err := f.zone(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	records := append([]Record{}, list...)
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Type < records[j].Type
+	})
+
+	return records, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: zone
+//-----------------------------------------------------------------------------
+
+func (f *Fake) zone(zone string) ([]Record, error) {
+	list, ok := f.Zones[strings.TrimSuffix(zone, ".")]
+	if !ok {
+		return nil, errors.New("Ops! This zone does not exist: " + zone)
+	}
+	return list, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: find
+//-----------------------------------------------------------------------------
+
+func find(list []Record, r Record) int {
+	for i := range list {
+		if list[i].Name == r.Name && list[i].Type == r.Type &&
+			list[i].SetID == r.SetID {
+			return i
+		}
+	}
+	return -1
+}
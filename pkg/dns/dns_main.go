@@ -0,0 +1,235 @@
+package dns
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Record is a DNS resource record set. Name is relative to its zone and
+// a zero TTL means the provider default. Routing policies and aliases are
+// only honoured by the providers that support them.
+type Record struct {
+	Name        string   `json:"Name"`
+	Type        string   `json:"Type"`
+	TTL         int64    `json:"TTL"`
+	Data        []string `json:"Data"`
+	SetID       string   `json:"SetID,omitempty"`
+	Weight      *int64   `json:"Weight,omitempty"`
+	Region      string   `json:"Region,omitempty"`
+	HealthCheck string   `json:"HealthCheck,omitempty"`
+	Alias       *Alias   `json:"Alias,omitempty"`
+}
+
+// Alias points a record to a load balancer or another record set.
+type Alias struct {
+	DNSName        string `json:"DNSName"`
+	ZoneID         string `json:"ZoneID"`
+	EvaluateHealth bool   `json:"EvaluateHealth"`
+}
+
+// Provider manages DNS zones and records. Adding an existing zone and
+// deleting a missing zone or record are not errors.
+type Provider interface {
+	AddZone(zone string) error
+	DelZone(zone string) error
+	UpsertRecords(zone string, records []Record) error
+	DeleteRecords(zone string, records []Record) error
+	ListRecords(zone string) ([]Record, error)
+}
+
+// PrivateProvider is implemented by the providers that support private zones,
+// only resolvable from within a cloud network.
+type PrivateProvider interface {
+	Provider
+	AddPrivateZone(zone, network, region string) error
+}
+
+// Factory returns a Provider authenticated with the given API key.
+type Factory func(apiKey string) Provider
+
+var (
+	mutex     sync.Mutex
+	factories = map[string]Factory{}
+)
+
+//-----------------------------------------------------------------------------
+// func: Register
+//-----------------------------------------------------------------------------
+
+// Register makes a Provider available by name. It is meant to be called
+// from the init function of the provider package.
+func Register(name string, f Factory) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	factories[name] = f
+}
+
+//-----------------------------------------------------------------------------
+// func: New
+//-----------------------------------------------------------------------------
+
+// New returns the Provider registered under the given name.
+func New(name, apiKey string) (Provider, error) {
+
+	mutex.Lock()
+	f, ok := factories[name]
+	mutex.Unlock()
+
+	if !ok {
+		return nil, errors.New("Unknown DNS provider: " + name)
+	}
+
+	return f(apiKey), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Providers
+//-----------------------------------------------------------------------------
+
+// Providers returns the sorted names of all the registered providers.
+func Providers() (names []string) {
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for name := range factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: ParseRecord
+//-----------------------------------------------------------------------------
+
+// ParseRecord parses a name:type[:data[,data...]] record.
+func ParseRecord(record string) (Record, error) {
+
+	// Split into name:type:data
+	s := strings.SplitN(record, ":", 3)
+	if len(s) < 2 || s[0] == "" || s[1] == "" {
+		return Record{}, errors.New("Invalid record format: " + record)
+	}
+
+	r := Record{Name: s[0], Type: strings.ToUpper(s[1])}
+	if len(s) == 3 && s[2] != "" {
+		r.Data = strings.Split(s[2], ",")
+	}
+
+	return r, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: ParseRecords
+//-----------------------------------------------------------------------------
+
+// ParseRecords parses a list of name:type[:data[,data...]] records.
+func ParseRecords(records []string) ([]Record, error) {
+
+	list := []Record{}
+	for _, record := range records {
+		r, err := ParseRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+
+	return list, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: String
+//-----------------------------------------------------------------------------
+
+// String formats the record as name:type:data[,data...]
+func (r Record) String() string {
+	return r.Name + ":" + r.Type + ":" + strings.Join(r.Data, ",")
+}
+
+//-----------------------------------------------------------------------------
+// func: Routed
+//-----------------------------------------------------------------------------
+
+// Routed reports whether the record uses a routing policy or an alias.
+func (r Record) Routed() bool {
+	return r.SetID != "" || r.Weight != nil || r.Region != "" ||
+		r.HealthCheck != "" || r.Alias != nil
+}
+
+//-----------------------------------------------------------------------------
+// func: routing
+//-----------------------------------------------------------------------------
+
+func (r Record) routing() string {
+
+	var s []string
+	if r.SetID != "" {
+		s = append(s, "id="+r.SetID)
+	}
+	if r.Weight != nil {
+		s = append(s, "weight="+strconv.FormatInt(*r.Weight, 10))
+	}
+	if r.Region != "" {
+		s = append(s, "region="+r.Region)
+	}
+	if r.HealthCheck != "" {
+		s = append(s, "health-check="+r.HealthCheck)
+	}
+
+	return strings.Join(s, " ")
+}
+
+//-----------------------------------------------------------------------------
+// func: PrintRecords
+//-----------------------------------------------------------------------------
+
+// PrintRecords writes the records to w as a table or as JSON.
+func PrintRecords(w io.Writer, format string, records []Record) error {
+
+	switch format {
+
+	case "json":
+		out, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTYPE\tTTL\tDATA\tROUTING")
+		for _, r := range records {
+			ttl, data := "", strings.Join(r.Data, ",")
+			if r.TTL > 0 {
+				ttl = strconv.FormatInt(r.TTL, 10)
+			}
+			if r.Alias != nil {
+				data = "ALIAS " + r.Alias.DNSName
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+				r.Name, r.Type, ttl, data, r.routing())
+		}
+		return tw.Flush()
+	}
+}
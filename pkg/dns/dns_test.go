@@ -0,0 +1,107 @@
+package dns
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRecord(t *testing.T) {
+
+	for _, tc := range []struct {
+		in       string
+		expected Record
+	}{
+		{"foo:a:10.0.0.1", Record{Name: "foo", Type: "A", Data: []string{"10.0.0.1"}}},
+		{"foo:A:10.0.0.1,10.0.0.2", Record{Name: "foo", Type: "A", Data: []string{"10.0.0.1", "10.0.0.2"}}},
+		{"bar:CNAME", Record{Name: "bar", Type: "CNAME"}},
+	} {
+		r, err := ParseRecord(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(r, tc.expected) {
+			t.Errorf("ParseRecord(%q): expected %+v, got %+v", tc.in, tc.expected, r)
+		}
+	}
+
+	for _, in := range []string{"", "foo", ":A:1.2.3.4", "foo::1.2.3.4"} {
+		if _, err := ParseRecord(in); err == nil {
+			t.Errorf("ParseRecord(%q): expected an error", in)
+		}
+	}
+}
+
+func TestFake(t *testing.T) {
+
+	Register("fake", func(string) Provider { return NewFake() })
+	p, err := New("fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.UpsertRecords("example.com", []Record{{Name: "a", Type: "A"}}); err == nil {
+		t.Error("expected an error for a missing zone")
+	}
+
+	if err := p.AddZone("example.com."); err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Record{
+		{Name: "b", Type: "A", Data: []string{"10.0.0.2"}},
+		{Name: "a", Type: "A", Data: []string{"10.0.0.1"}},
+		{Name: "b", Type: "TXT", Data: []string{"foo"}},
+	}
+	if err := p.UpsertRecords("example.com", records); err != nil {
+		t.Fatal(err)
+	}
+
+	// Same name and type is replaced:
+	if err := p.UpsertRecords("example.com", []Record{{Name: "a", Type: "A", Data: []string{"10.0.0.9"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing records are ignored:
+	if err := p.DeleteRecords("example.com", []Record{{Name: "b", Type: "TXT"}, {Name: "c", Type: "A"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := p.ListRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Record{
+		{Name: "a", Type: "A", Data: []string{"10.0.0.9"}},
+		{Name: "b", Type: "A", Data: []string{"10.0.0.2"}},
+	}
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("expected %+v, got %+v", expected, list)
+	}
+}
+
+func TestPrintRecords(t *testing.T) {
+
+	records := []Record{{Name: "@", Type: "NS", TTL: 172800, Data: []string{"ns-1.", "ns-2."}}}
+
+	var buf bytes.Buffer
+	if err := PrintRecords(&buf, "table", records); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || strings.Join(strings.Fields(lines[1]), " ") != "@ NS 172800 ns-1.,ns-2." {
+		t.Errorf("unexpected table: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := PrintRecords(&buf, "json", records); err != nil {
+		t.Fatal(err)
+	}
+	list := []Record{}
+	if err := json.Unmarshal(buf.Bytes(), &list); err != nil || !reflect.DeepEqual(list, records) {
+		t.Errorf("unexpected json: %q", buf.String())
+	}
+}
@@ -7,9 +7,8 @@ package ec2
 import (
 
 	// Stdlib:
+	"encoding/base64"
 	"encoding/json"
-	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
@@ -17,89 +16,178 @@ import (
 
 	// Community:
 	log "github.com/Sirupsen/logrus"
+	"github.com/katosys/kato/pkg/dns"
 	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/pki"
 )
 
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Node certificate signed for a new instance, recorded once it is accepted.
+type nodeCert struct {
+	store     *pki.Store
+	name      string
+	hosts     []string
+	cert, key []byte
+}
+
 //-----------------------------------------------------------------------------
 // func: Add
 //-----------------------------------------------------------------------------
 
 // Add a new instance to the cluster.
-func (d *Data) Add() {
+func (d *Data) Add() error {
 
 	// Set current command:
 	d.command = "add"
 
 	// Load state from state file:
 	if err := d.loadState(); err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		return err
+	}
+
+	// Nothing in clear in the user data:
+	if err := d.requireKMS(); err != nil {
+		return err
+	}
+
+	// Locate the target zone:
+	z, err := d.availZone(d.Zone)
+	if err != nil {
+		return err
 	}
+	d.Zone = z.Name
 
 	// Retrieve the CoreOS AMI ID:
-	var err error
-	if d.AmiID, err = d.retrieveCoreOSAmiID(); err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	if d.AmiID == "" {
+		if d.AmiID, err = d.retrieveCoreOSAmiID(); err != nil {
+			return err
+		}
+	}
+
+	// Sign the node certificate, nothing is revoked until the node is accepted:
+	var nc *nodeCert
+	if d.PKI {
+		if nc, err = d.signNodeCert(z); err != nil {
+			return err
+		}
 	}
 
 	// Execute the udata|run pipeline:
-	out, err := kato.ExecutePipeline(d.forgeUdataCommand(), d.forgeRunCommand())
+	out, err := kato.ExecutePipeline(d.forgeUdataCommand(z, nc), d.forgeRunCommand(z))
 	if err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		return err
+	}
+
+	// Record the node certificate, superseding the one of a replaced node:
+	if nc != nil {
+		if err := d.recordNodeCert(nc); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Warning(err)
+		}
+	}
+
+	// Record the node in the state file:
+	if err := d.recordNode(out); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Warning(err)
 	}
 
 	// Publish DNS records:
 	if err := d.publishDNSRecords(d.Roles, out); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Warning(err)
 	}
+
+	return nil
 }
 
 //-----------------------------------------------------------------------------
-// func: retrieveCoreOSAmiID
+// func: signNodeCert
 //-----------------------------------------------------------------------------
 
-func (d *Data) retrieveCoreOSAmiID() (string, error) {
+// The node certificate is valid for the node name, its roles and, when known
+// in advance, its private IP.
+func (d *Data) signNodeCert(z *AvailZone) (*nodeCert, error) {
 
-	// Send the request:
-	res, err := http.Get("https://coreos.com/dist/aws/aws-" +
-		d.CoreOSChannel + ".json")
+	// Open the cluster PKI:
+	s, err := pki.OpenStore(d.ClusterID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Retrieve the data:
-	data, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return "", err
+	// Sign it:
+	var aliases []string
+	if ip := d.privateIP(z); ip != "" {
+		aliases = append(aliases, ip)
+	}
+	nc := &nodeCert{store: s, name: d.HostName + "-" + d.HostID}
+	nc.hosts = pki.NodeHosts(s.Domain, d.HostName, d.HostID, strings.Split(d.Roles, ","), aliases)
+	if nc.cert, nc.key, err = s.Sign(nc.name, "node", nc.hosts); err != nil {
+		return nil, err
 	}
 
-	// Close the handler:
-	if err = res.Body.Close(); err != nil {
-		return "", err
+	return nc, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: recordNodeCert
+//-----------------------------------------------------------------------------
+
+// Record the certificate of an accepted node. When it replaces the one of a
+// previous node the new revocation list is pushed to the cluster.
+func (d *Data) recordNodeCert(nc *nodeCert) error {
+	superseded, err := nc.store.Record(nc.name, "node", nc.hosts, nc.cert)
+	if err != nil || !superseded {
+		return err
 	}
+	return d.publishCRL(nc.store)
+}
 
-	// Decode JSON into Go values:
-	var jsonData map[string]interface{}
-	if err := json.Unmarshal(data, &jsonData); err != nil {
-		return "", err
+//-----------------------------------------------------------------------------
+// func: publishCRL
+//-----------------------------------------------------------------------------
+
+// Store the revocation list in etcd, kato-crl.timer refreshes it on the nodes.
+func (d *Data) publishCRL(s *pki.Store) error {
+	crl, err := s.CRL()
+	if err != nil {
+		return err
 	}
+	_, err = d.etcdctl("set /kato/pki/crl " + base64.StdEncoding.EncodeToString(crl))
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: recordNode
+//-----------------------------------------------------------------------------
 
-	// Store the AMI ID:
-	amis := jsonData[d.Region].(map[string]interface{})
-	amiID := amis["hvm"].(string)
+func (d *Data) recordNode(out []byte) error {
 
-	// Log this action:
-	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": amiID}).
-		Info("Latest CoreOS " + d.CoreOSChannel + " AMI located")
+	// Retrieve the instance data:
+	var dat map[string]string
+	if err := json.Unmarshal(out, &dat); err != nil {
+		return err
+	}
 
-	// Return the AMI ID:
-	return amiID, nil
+	// Save it to the state file:
+	return d.saveNode(Node{
+		HostName:     d.HostName,
+		HostID:       d.HostID,
+		Roles:        d.Roles,
+		Zone:         d.Zone,
+		InstanceType: d.InstanceType,
+		InstanceID:   dat["instanceID"],
+		InterfaceID:  dat["interfaceID"],
+		PrivateIP:    dat["internal"],
+		PublicIP:     dat["external"],
+	})
 }
 
 //-----------------------------------------------------------------------------
 // func: forgeUdataCommand
 //-----------------------------------------------------------------------------
 
-func (d *Data) forgeUdataCommand() *exec.Cmd {
+func (d *Data) forgeUdataCommand(z *AvailZone, nc *nodeCert) *exec.Cmd {
 
 	// Udata arguments bundle:
 	args := []string{"udata",
@@ -113,8 +201,6 @@ func (d *Data) forgeUdataCommand() *exec.Cmd {
 		"--domain", d.Domain,
 		"--ec2-region", d.Region,
 		"--dns-provider", d.DNSProvider,
-		"--dns-api-key", d.DNSApiKey,
-		"--etcd-token", d.EtcdToken,
 		"--calico-ip-pool", d.CalicoIPPool,
 		"--rexray-storage-driver", "ebs",
 		"--iaas-provider", "ec2",
@@ -122,9 +208,33 @@ func (d *Data) forgeUdataCommand() *exec.Cmd {
 		//"--gzip-udata",
 	}
 
+	// Etcd bootstrap, older states only have a token:
+	switch d.EtcdDiscovery {
+	case "static":
+	case "":
+		args = append(args, "--etcd-token", d.EtcdToken)
+	default:
+		args = append(args, "--etcd-discovery", d.EtcdDiscovery)
+	}
+	for _, ip := range d.QuorumIPs {
+		args = append(args, "--quorum-ip", ip)
+	}
+	if d.EtcdCluster != "" {
+		args = append(args, "--etcd-initial-cluster", d.EtcdCluster)
+	}
+	if d.EtcdTLS {
+		args = append(args, "--etcd-tls")
+	}
+	if ip := d.privateIP(z); ip != "" {
+		args = append(args, "--private-ip", ip)
+	}
+	if d.aliasELB() {
+		args = append(args, "--elb-alias")
+	}
+
 	// Append flags if present:
-	if d.SlackWebhook != "" {
-		args = append(args, "--slack-webhook", d.SlackWebhook)
+	if d.KmsKeyID != "" {
+		args = append(args, "--kms-key-id", d.KmsKeyID)
 	}
 	if d.CaCertPath != "" {
 		args = append(args, "--ca-cert-path", d.CaCertPath)
@@ -132,51 +242,106 @@ func (d *Data) forgeUdataCommand() *exec.Cmd {
 	for _, z := range d.StubZones {
 		args = append(args, "--stub-zone", z)
 	}
-	if d.SMTPURL != "" {
-		args = append(args, "--smtp-url", d.SMTPURL)
-	}
 	if d.AdminEmail != "" {
 		args = append(args, "--admin-email", d.AdminEmail)
 	}
 
-	// Forge the command and return:
-	return exec.Command("katoctl", args...)
+	// Secrets go through the environment, not the process list:
+	env := map[string]string{
+		"KATO_UDATA_DNS_API_KEY":   d.DNSApiKey,
+		"KATO_UDATA_SLACK_WEBHOOK": d.SlackWebhook,
+		"KATO_UDATA_SMTP_URL":      d.SMTPURL,
+	}
+	if nc != nil {
+		env["KATO_UDATA_NODE_CERT"] = string(nc.cert)
+		env["KATO_UDATA_NODE_KEY"] = string(nc.key)
+		env["KATO_UDATA_PKI_CA"] = string(nc.store.CertPEM())
+		if crl, err := nc.store.CRL(); err == nil {
+			env["KATO_UDATA_PKI_CRL"] = string(crl)
+		}
+	}
+
+	cmd := exec.Command("katoctl", args...)
+	cmd.Env = os.Environ()
+	for name, value := range env {
+		if value != "" {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+
+	return cmd
 }
 
 //-----------------------------------------------------------------------------
 // func: forgeRunCommand
 //-----------------------------------------------------------------------------
 
-func (d *Data) forgeRunCommand() *exec.Cmd {
+func (d *Data) forgeRunCommand(z *AvailZone) *exec.Cmd {
 
 	// Ec2 run arguments bundle:
 	args := []string{"ec2", "run",
 		"--tag-name", d.HostName + "-" + d.HostID + "." + d.Domain,
 		"--region", d.Region,
-		"--zone", d.Zone,
+		"--zone", z.Name,
 		"--ami-id", d.AmiID,
 		"--instance-type", d.InstanceType,
 		"--key-pair", d.KeyPair,
-		"--subnet-id", d.ExtSubnetID,
+		"--subnet-id", z.ExtSubnetID,
 		"--security-group-ids", strings.Join(d.securityGroupIDs(d.Roles), ","),
 		"--iam-role", "kato",
 		"--source-dest-check", "false",
 		"--public-ip", "true",
+		"--tag", clusterTag + "=" + d.ClusterID,
 	}
 
 	// Append flags if present:
-	if strings.Contains(d.Roles, "master") {
-		i, _ := strconv.Atoi(d.HostID)
-		args = append(args, "--private-ip", kato.OffsetIP(d.ExtSubnetCidr, 10+i))
+	if ip := d.privateIP(z); ip != "" {
+		args = append(args, "--private-ip", ip)
 	}
 	if strings.Contains(d.Roles, "worker") {
 		args = append(args, "--elb-name", d.ClusterID)
 	}
+	if d.DiskSize > 0 {
+		args = append(args, "--disk-size", strconv.FormatInt(d.DiskSize, 10))
+	}
+	for _, tag := range d.Tags {
+		args = append(args, "--tag", tag)
+	}
 
 	// Forge the command and return:
 	return exec.Command("katoctl", args...)
 }
 
+//-----------------------------------------------------------------------------
+// func: fixedIP
+//-----------------------------------------------------------------------------
+
+// Masters have fixed private IPs, and so do quorum nodes when etcd is
+// bootstrapped statically or its peer certificates need them. Other nodes
+// get theirs from DHCP.
+func (d *Data) fixedIP(z *AvailZone, roles string, id int) string {
+	switch {
+	case strings.Contains(roles, "master"):
+		return kato.OffsetIP(z.ExtSubnetCidr, 10+id)
+	case strings.Contains(roles, "quorum") && (d.EtcdDiscovery == "static" || d.EtcdTLS):
+		return kato.OffsetIP(z.ExtSubnetCidr, 4+id)
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// func: privateIP
+//-----------------------------------------------------------------------------
+
+// The private IP of the new node when known in advance: its fixed IP or the
+// one of the node it replaces.
+func (d *Data) privateIP(z *AvailZone) string {
+	if ip := d.fixedIP(z, d.Roles, atoi(d.HostID)); ip != "" {
+		return ip
+	}
+	return d.PrivateIP
+}
+
 //-----------------------------------------------------------------------------
 // func: securityGroupIDs
 //-----------------------------------------------------------------------------
@@ -209,50 +374,52 @@ func (d *Data) publishDNSRecords(roles string, out []byte) error {
 		return err
 	}
 
-	// For every role in this instance:
-	for _, role := range strings.Split(roles, ",") {
-
-		name := role + "-" + d.HostID
-
-		// Forge the internal record command:
-		cmdInt := exec.Command("katoctl", d.DNSProvider,
-			"--api-key", d.DNSApiKey,
-			"record", "add",
-			"--zone", "int."+d.Domain,
-			name+":A:"+dat["internal"])
+	// Get the DNS provider:
+	p, err := dns.New(d.DNSProvider, d.DNSApiKey)
+	if err != nil {
+		return err
+	}
 
-		// Execute the internal record command:
-		cmdInt.Stderr = os.Stderr
-		if err := cmdInt.Run(); err != nil {
+	// Route 53 aliases ext.<domain> to the ELB:
+	alias := d.aliasELB()
+	if alias {
+		if err := p.UpsertRecords("ext."+d.Domain, []dns.Record{{
+			Name: "@", Type: "A",
+			Alias: &dns.Alias{DNSName: d.DNSName, ZoneID: d.ELBZoneID},
+		}}); err != nil {
 			return err
 		}
+	}
 
-		// Forge the external record command:
-		cmdExt := exec.Command("katoctl", d.DNSProvider,
-			"--api-key", d.DNSApiKey,
-			"record", "add",
-			"--zone", "ext."+d.Domain,
-			name+":A:"+dat["external"])
-
-		// Execute the external record command:
-		cmdExt.Stderr = os.Stderr
-		if err := cmdExt.Run(); err != nil {
-			return err
-		}
+	// For every role in this instance:
+	for _, role := range strings.Split(roles, ",") {
 
-		// Forge the CNAME record command:
-		cmdCname := exec.Command("katoctl", d.DNSProvider,
-			"--api-key", d.DNSApiKey,
-			"record", "add",
-			"--zone", d.Domain,
-			name+":CNAME:"+name+".int."+d.Domain)
+		name := role + "-" + d.HostID
 
-		// Execute the CNAME record command:
-		cmdCname.Stderr = os.Stderr
-		if err := cmdCname.Run(); err != nil {
-			return err
+		// Internal, external and CNAME records:
+		for zone, record := range map[string]dns.Record{
+			"int." + d.Domain: {Name: name, Type: "A", Data: []string{dat["internal"]}},
+			"ext." + d.Domain: {Name: name, Type: "A", Data: []string{dat["external"]}},
+			d.Domain:          {Name: name, Type: "CNAME", Data: []string{name + ".int." + d.Domain}},
+		} {
+			if alias && zone == "ext."+d.Domain {
+				continue
+			}
+			if err := p.UpsertRecords(zone, []dns.Record{record}); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+//-----------------------------------------------------------------------------
+// func: aliasELB
+//-----------------------------------------------------------------------------
+
+// Route 53 publishes ext.<domain> as an alias of the ELB instead of one A
+// record per node. Older state files lack the ELB hosted zone ID.
+func (d *Data) aliasELB() bool {
+	return d.DNSProvider == "r53" && d.DNSName != "" && d.ELBZoneID != ""
+}
@@ -0,0 +1,235 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+)
+
+//-----------------------------------------------------------------------------
+// Package factored var statement:
+//-----------------------------------------------------------------------------
+
+var (
+
+	// CoreOS AMI feeds, the latest release and a given version:
+	coreosCurrentURL = "https://coreos.com/dist/aws/aws-%s.json"
+	coreosVersionURL = "https://%s.release.core-os.net/amd64-usr/%s/coreos_production_ami_all.json"
+
+	// The feed must answer quickly, the cache is used otherwise:
+	coreosFeedClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// Region to virtualization type to AMI ID.
+type amiCatalog map[string]map[string]string
+
+//-----------------------------------------------------------------------------
+// func: retrieveCoreOSAmiID
+//-----------------------------------------------------------------------------
+
+// Locate the CoreOS AMI of the cluster channel, version and region. Pinned
+// versions never change so their cached catalog is used first, the current
+// release is only read from cache when the feed is down.
+func (d *Data) retrieveCoreOSAmiID() (string, error) {
+
+	// Initializations:
+	version := d.CoreOSVersion
+	if version == "" {
+		version = "current"
+	}
+	cacheFile := os.Getenv("HOME") + "/.kato/coreos/" + d.CoreOSChannel + "-" + version + ".json"
+
+	// Read the catalog:
+	raw, err := readAmiCache(cacheFile)
+	if err != nil || version == "current" {
+		url := fmt.Sprintf(coreosVersionURL, d.CoreOSChannel, version)
+		if version == "current" {
+			url = fmt.Sprintf(coreosCurrentURL, d.CoreOSChannel)
+		}
+		fresh, ferr := fetchAmiCatalog(url)
+		switch {
+		case ferr == nil:
+			raw = fresh
+			if err := writeAmiCache(cacheFile, raw); err != nil {
+				log.WithField("cmd", "ec2:"+d.command).Warning(err)
+			}
+		case err == nil:
+			log.WithField("cmd", "ec2:"+d.command).
+				Warning(ferr.Error() + ", using the cached AMI catalog")
+		default:
+			return "", ferr
+		}
+	}
+
+	// Decode it:
+	catalog, err := decodeAmiCatalog(raw)
+	if err != nil {
+		return "", err
+	}
+
+	// Look the AMI up:
+	amiID, err := catalog.lookup(d.Region, "hvm")
+	if err != nil {
+		return "", errors.New("CoreOS " + d.CoreOSChannel + " " + version + ": " + err.Error())
+	}
+
+	// Log this action:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": amiID}).
+		Info("CoreOS " + d.CoreOSChannel + " " + version + " AMI located")
+
+	return amiID, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: fetchAmiCatalog
+//-----------------------------------------------------------------------------
+
+func fetchAmiCatalog(url string) ([]byte, error) {
+
+	// Send the request:
+	res, err := coreosFeedClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Check the response:
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New("GET " + url + ": " + res.Status)
+	}
+
+	// Retrieve the data, it must decode:
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decodeAmiCatalog(data); err != nil {
+		return nil, errors.New("GET " + url + ": " + err.Error())
+	}
+
+	return data, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: decodeAmiCatalog
+//-----------------------------------------------------------------------------
+
+// Decode both the aws-<channel>.json and the per-version ami_all.json feeds.
+func decodeAmiCatalog(raw []byte) (amiCatalog, error) {
+
+	catalog := amiCatalog{}
+
+	// Per-version feed, a list of regions:
+	var all struct {
+		Amis []map[string]string `json:"amis"`
+	}
+	if err := json.Unmarshal(raw, &all); err == nil && len(all.Amis) > 0 {
+		for _, a := range all.Amis {
+			region := a["name"]
+			delete(a, "name")
+			catalog[region] = a
+		}
+		return catalog, nil
+	}
+
+	// Current feed, regions are keys next to the release info:
+	var current map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &current); err != nil {
+		return nil, errors.New("invalid AMI catalog: " + err.Error())
+	}
+	for region, data := range current {
+		amis := map[string]string{}
+		if err := json.Unmarshal(data, &amis); err == nil && region != "release_info" {
+			catalog[region] = amis
+		}
+	}
+
+	if len(catalog) == 0 {
+		return nil, errors.New("invalid AMI catalog: no regions found")
+	}
+
+	return catalog, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: lookup
+//-----------------------------------------------------------------------------
+
+func (c amiCatalog) lookup(region, virt string) (string, error) {
+
+	amis, ok := c[region]
+	if !ok {
+		return "", errors.New("no AMI for region " + region)
+	}
+
+	amiID := amis[virt]
+	if !strings.HasPrefix(amiID, "ami-") {
+		return "", errors.New("no " + virt + " AMI for region " + region)
+	}
+
+	return amiID, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: readAmiCache
+//-----------------------------------------------------------------------------
+
+// Read a cached catalog and check it against its SHA-256 checksum.
+func readAmiCache(cacheFile string) ([]byte, error) {
+
+	// Read the catalog and its checksum:
+	raw, err := ioutil.ReadFile(cacheFile)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := ioutil.ReadFile(cacheFile + ".sha256")
+	if err != nil {
+		return nil, err
+	}
+
+	// Compare them:
+	actual := sha256.Sum256(raw)
+	if !bytes.Equal(bytes.TrimSpace(sum), []byte(hex.EncodeToString(actual[:]))) {
+		return nil, errors.New("Ops! checksum mismatch in " + cacheFile)
+	}
+
+	return raw, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: writeAmiCache
+//-----------------------------------------------------------------------------
+
+func writeAmiCache(cacheFile string, raw []byte) error {
+
+	// Create the cache directory:
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0700); err != nil {
+		return err
+	}
+
+	// Write the catalog and then its checksum:
+	sum := sha256.Sum256(raw)
+	if err := ioutil.WriteFile(cacheFile, raw, 0600); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cacheFile+".sha256", []byte(hex.EncodeToString(sum[:])+"\n"), 0600)
+}
@@ -0,0 +1,361 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/katosys/kato/pkg/kato"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Change needed to converge a node to its pool.
+type change struct {
+	Action   string // add | replace | remove
+	HostName string
+	HostID   string
+	Roles    string
+	From     string // current instance type
+	To       string // desired instance type
+	AmiID    string // defaults to the latest channel AMI
+	pool     NodePool
+
+	// Quorum replacements rejoin etcd with the same address:
+	PrivateIP   string
+	EtcdCluster string
+}
+
+//-----------------------------------------------------------------------------
+// func: Converge
+//-----------------------------------------------------------------------------
+
+// Converge adds, replaces and removes nodes until the cluster matches the
+// node pools of its spec.
+func (d *Data) Converge(specFile string) {
+
+	// Set current command:
+	d.command = "apply"
+
+	// Load state from state file:
+	if err := d.loadState(); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Load the desired node pools:
+	s, err := d.desiredSpec(specFile)
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Connect and authenticate to the API endpoints:
+	d.setupAPIEndpoints()
+
+	// Retrieve the actual nodes:
+	instances, err := d.describeClusterInstances()
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+	nodes := d.reconcileNodes(instances, nil)
+
+	// Diff them, the quorum size is baked into every node:
+	changes := diffNodes(s.NodePools, nodes)
+	if n := countPoolNodes(s.NodePools, "quorum"); n != d.QuorumCount {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(errors.New("Ops! the spec has " +
+			strconv.Itoa(n) + " quorum nodes but the cluster was deployed with " +
+			strconv.Itoa(d.QuorumCount) + ", the quorum size can't be changed"))
+	}
+
+	// Print the plan before acting:
+	if err := printChanges(os.Stdout, changes); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+	if d.DryRun {
+		return
+	}
+
+	// Quorum nodes are rolled with checks run from a border node:
+	for _, c := range changes {
+		if c.Action == "replace" && contains(strings.Split(c.Roles, ","), "quorum") {
+			if err := d.requireBorder(); err != nil {
+				log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+			}
+			break
+		}
+	}
+	d.leaving = &sync.Map{}
+
+	// New nodes render the new master count, the state keeps the old one
+	// until every change succeeds:
+	d.MasterCount = countPoolNodes(s.NodePools, "master")
+
+	// Record the spec:
+	if specFile != "" {
+		if err := kato.DumpSpec(s.withoutSecrets(), d.ClusterID); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
+	// Scale up in parallel, collecting the failures:
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+	fail := func(c change, err error) {
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": c.HostName + "-" + c.HostID}).
+			Error(err)
+		mu.Lock()
+		failed = append(failed, c.Action+" "+c.HostName+"-"+c.HostID)
+		mu.Unlock()
+	}
+	for _, c := range changes {
+		if c.Action == "add" {
+			wg.Add(1)
+			go func(c change) {
+				defer wg.Done()
+				if err := d.addNode(c); err != nil {
+					fail(c, err)
+				}
+			}(c)
+		}
+	}
+	wg.Wait()
+
+	// Roll and scale down one node at a time, stop at the first failure:
+	for _, c := range changes {
+		if len(failed) > 0 {
+			break
+		}
+		switch c.Action {
+		case "replace":
+			if err := d.replaceNode(c); err != nil {
+				fail(c, err)
+			}
+		case "remove":
+			if err := d.removeNode(c); err != nil {
+				fail(c, err)
+			}
+		}
+	}
+
+	// Report the failures:
+	if len(failed) > 0 {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(errors.New("Ops! " +
+			strconv.Itoa(len(failed)) + " changes failed: " + strings.Join(failed, ", ") +
+			", run apply again once fixed"))
+	}
+
+	// Record the new master count:
+	if err := d.updateState(func(st *State) { st.MasterCount = d.MasterCount }); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+		Info("Cluster converged with " + strconv.Itoa(len(changes)) + " changes")
+}
+
+//-----------------------------------------------------------------------------
+// func: desiredSpec
+//-----------------------------------------------------------------------------
+
+// The given spec file or, without one, the spec stored at deploy time.
+func (d *Data) desiredSpec(specFile string) (*spec, error) {
+
+	// Read the given spec:
+	if specFile != "" {
+		s, err := readSpec(specFile)
+		if err != nil {
+			return nil, err
+		}
+		if s.ClusterID != d.ClusterID {
+			return nil, errors.New("Ops! spec " + specFile + " describes cluster " + s.ClusterID)
+		}
+		return s, nil
+	}
+
+	// Read the stored spec:
+	raw, err := kato.ReadSpec(d.ClusterID)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("Ops! no spec stored for " + d.ClusterID + ", use --file")
+		}
+		return nil, err
+	}
+
+	return decodeSpec(raw)
+}
+
+//-----------------------------------------------------------------------------
+// func: addNode
+//-----------------------------------------------------------------------------
+
+// Add a node reusing Add() and its udata|run pipeline.
+func (d *Data) addNode(c change) error {
+
+	n := &Data{
+		leaving: d.leaving,
+		State: State{
+			ClusterID:   d.ClusterID,
+			Zone:        d.nodeZone(atoi(c.HostID)).Name,
+			MasterCount: d.MasterCount,
+		},
+		Instance: Instance{
+			Roles:        c.Roles,
+			HostName:     c.HostName,
+			HostID:       c.HostID,
+			InstanceType: c.To,
+			AmiID:        c.AmiID,
+			ClusterState: "existing",
+			DiskSize:     c.pool.DiskSize,
+			Tags:         c.pool.tags(),
+			PrivateIP:    c.PrivateIP,
+			EtcdCluster:  c.EtcdCluster,
+		},
+	}
+
+	return n.Add()
+}
+
+//-----------------------------------------------------------------------------
+// func: removeNode
+//-----------------------------------------------------------------------------
+
+// Remove a node reusing Remove(), which refuses to break the quorum.
+func (d *Data) removeNode(c change) error {
+	n := &Data{
+		leaving:  d.leaving,
+		State:    State{ClusterID: d.ClusterID},
+		Instance: Instance{HostName: c.HostName, HostID: c.HostID},
+	}
+	return n.Remove()
+}
+
+//-----------------------------------------------------------------------------
+// func: diffNodes
+//-----------------------------------------------------------------------------
+
+// Compare the node pools against the actual nodes. Nodes are <pool>-<id>
+// with ids 1 to count, any other named node is removed.
+func diffNodes(pools []NodePool, nodes []nodeStatus) (changes []change) {
+
+	// Index the live nodes:
+	live := map[string]nodeStatus{}
+	for _, n := range nodes {
+		if n.HostName != "" && n.State != "missing" &&
+			n.State != "shutting-down" && n.State != "terminated" {
+			live[n.HostName+"-"+n.HostID] = n
+		}
+	}
+
+	// Desired nodes:
+	desired := map[string]bool{}
+	for _, p := range pools {
+		roles := strings.Join(p.Roles, ",")
+		for i := 1; i <= p.Count; i++ {
+			id := strconv.Itoa(i)
+			desired[p.Name+"-"+id] = true
+			c := change{HostName: p.Name, HostID: id, Roles: roles, To: p.InstanceType, pool: p}
+			n, ok := live[p.Name+"-"+id]
+			switch {
+			case !ok:
+				c.Action = "add"
+			case n.InstanceType != p.InstanceType || !sameRoles(n.Roles, roles):
+				c.Action, c.From = "replace", n.InstanceType
+			default:
+				continue
+			}
+			changes = append(changes, c)
+		}
+	}
+
+	// Undesired nodes, highest ids first:
+	var extra []change
+	for name, n := range live {
+		if !desired[name] {
+			extra = append(extra, change{Action: "remove", HostName: n.HostName,
+				HostID: n.HostID, Roles: n.Roles, From: n.InstanceType})
+		}
+	}
+	sort.Slice(extra, func(a, b int) bool {
+		if extra[a].HostName != extra[b].HostName {
+			return extra[a].HostName < extra[b].HostName
+		}
+		return atoi(extra[a].HostID) > atoi(extra[b].HostID)
+	})
+
+	return append(changes, extra...)
+}
+
+//-----------------------------------------------------------------------------
+// func: sameRoles
+//-----------------------------------------------------------------------------
+
+func sameRoles(a, b string) bool {
+	x, y := strings.Split(a, ","), strings.Split(b, ",")
+	sort.Strings(x)
+	sort.Strings(y)
+	return strings.Join(x, ",") == strings.Join(y, ",")
+}
+
+//-----------------------------------------------------------------------------
+// func: countPoolNodes
+//-----------------------------------------------------------------------------
+
+func countPoolNodes(pools []NodePool, role string) (count int) {
+	for _, p := range pools {
+		if contains(p.Roles, role) {
+			count += p.Count
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: printChanges
+//-----------------------------------------------------------------------------
+
+func printChanges(w io.Writer, changes []change) error {
+
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "Nothing to do, the cluster matches its spec.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTION\tHOST\tROLES\tTYPE")
+	for _, c := range changes {
+		itype := c.To
+		switch c.Action {
+		case "replace":
+			itype = c.From + " -> " + c.To
+		case "remove":
+			itype = c.From
+		}
+		fmt.Fprintf(tw, "%s\t%s-%s\t%s\t%s\n", c.Action, c.HostName, c.HostID, c.Roles, itype)
+	}
+	return tw.Flush()
+}
+
+//-----------------------------------------------------------------------------
+// func: atoi
+//-----------------------------------------------------------------------------
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
@@ -7,6 +7,7 @@ package ec2
 import (
 
 	// Stdlib:
+	"errors"
 	"os"
 	"os/exec"
 	"strconv"
@@ -16,6 +17,7 @@ import (
 	// Community:
 	log "github.com/Sirupsen/logrus"
 	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/pki"
 )
 
 //-----------------------------------------------------------------------------
@@ -27,33 +29,70 @@ func (d *Data) Deploy() {
 
 	// Initializations:
 	d.command = "deploy"
-	wch := kato.NewWaitChan(3)
+	wch := kato.NewWaitChan(2)
 
 	// Count quorum and master nodes:
 	d.QuorumCount = kato.CountNodes(d.Quadruplets, "quorum")
 	d.MasterCount = kato.CountNodes(d.Quadruplets, "master")
 
+	// Static and TLS etcd members are known in advance:
+	if d.EtcdDiscovery == "static" || d.EtcdTLS {
+		d.QuorumIPs = d.staticQuorumIPs()
+	}
+
+	// Etcd certificate authority:
+	if d.EtcdTLS {
+		if err := d.setupEtcdCA(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
+	// Cluster PKI, kept if already there:
+	if d.PKI {
+		if _, err := pki.EnsureStore(d.ClusterID, d.Domain); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
 	// Setup the environment (I):
 	go d.setupEC2(wch)
-	go kato.CreateDNSZones(wch, d.DNSProvider, d.DNSApiKey, d.Domain)
-	go kato.NewEtcdToken(wch, d.QuorumCount, &d.EtcdToken)
+	go kato.NewEtcdDiscovery(wch, d.QuorumCount, d.EtcdToken, &d.EtcdDiscovery)
 
 	// Wait and check for errors:
 	if err := wch.WaitErr(); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
+	// Create the DNS zones, int is private to the VPC:
+	if d.DNSProvider != "none" {
+		wch.WaitGrp.Add(1)
+		go d.createDNSZones(wch)
+		if err := wch.WaitErr(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
 	// Dump state to file (II):
 	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
+	// Secrets go to their own encrypted file:
+	if err := kato.DumpSecrets(d.Secrets, d.ClusterID); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Store the spec alongside, without its secrets:
+	if d.spec != nil {
+		if err := kato.DumpSpec(d.spec.withoutSecrets(), d.ClusterID); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
 	// Deploy all the nodes (III):
-	for _, q := range d.Quadruplets {
+	for _, p := range d.nodePools() {
 		wch.WaitGrp.Add(1)
-		s := strings.Split(q, ":")
-		i, _ := strconv.Atoi(s[0])
-		go d.deployNodes(wch, i, s[1], s[2], s[3])
+		go d.deployNodes(wch, p)
 	}
 
 	// Wait for the nodes:
@@ -75,15 +114,30 @@ func (d *Data) setupEC2(wch *kato.WaitChan) {
 	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.Domain}).
 		Info("Setup the EC2 environment")
 
-	// Forge the setup command:
-	cmdSetup := exec.Command("katoctl", "ec2", "setup",
+	// Setup arguments bundle:
+	args := []string{"ec2", "setup",
 		"--cluster-id", d.ClusterID,
 		"--domain", d.Domain,
 		"--region", d.Region,
-		"--zone", d.Zone,
 		"--vpc-cidr-block", d.VpcCidrBlock,
-		"--internal-subnet-cidr", d.IntSubnetCidr,
-		"--external-subnet-cidr", d.ExtSubnetCidr)
+	}
+
+	// Nodes decrypt their secrets with this key:
+	if d.KmsKeyID != "" {
+		args = append(args, "--kms-key-id", d.KmsKeyID)
+	}
+
+	// Append the zones:
+	for _, z := range d.Zones {
+		args = append(args, "--zone", z.Name,
+			"--external-subnet-cidr", z.ExtSubnetCidr)
+		if z.IntSubnetCidr != "" {
+			args = append(args, "--internal-subnet-cidr", z.IntSubnetCidr)
+		}
+	}
+
+	// Forge the setup command:
+	cmdSetup := exec.Command("katoctl", args...)
 
 	// Execute the setup command:
 	cmdSetup.Stderr = os.Stderr
@@ -100,34 +154,66 @@ func (d *Data) setupEC2(wch *kato.WaitChan) {
 	}
 }
 
+//-----------------------------------------------------------------------------
+// func: createDNSZones
+//-----------------------------------------------------------------------------
+
+func (d *Data) createDNSZones(wch *kato.WaitChan) {
+
+	// Log this action:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.Domain}).
+		Info("Create the DNS zones")
+
+	// The int zone is private to the VPC unless told otherwise:
+	vpcID := d.VpcID
+	if d.PublicIntZone {
+		vpcID = ""
+	}
+
+	kato.CreateDNSZones(wch, d.DNSProvider, d.DNSApiKey, d.Domain, vpcID, d.Region)
+}
+
 //-----------------------------------------------------------------------------
 // func: deployNodes
 //-----------------------------------------------------------------------------
 
-func (d *Data) deployNodes(wch *kato.WaitChan, count int, itype, hostname, roles string) {
+func (d *Data) deployNodes(wch *kato.WaitChan, pool NodePool) {
 
 	// Decrement:
 	defer wch.WaitGrp.Done()
 	var wgInt sync.WaitGroup
 
 	log.WithField("cmd", "ec2:"+d.command).
-		Info("Deploying " + strconv.Itoa(count) + " " + hostname + " nodes")
+		Info("Deploying " + strconv.Itoa(pool.Count) + " " + pool.Name + " nodes")
 
-	for i := 1; i <= count; i++ {
+	for i := 1; i <= pool.Count; i++ {
 		wgInt.Add(1)
 
 		go func(id int) {
 			defer wgInt.Done()
 
-			// Forge the add command:
-			cmdAdd := exec.Command("katoctl", "ec2", "add",
+			// Add arguments bundle:
+			args := []string{"ec2", "add",
 				"--cluster-id", d.ClusterID,
 				"--cluster-state", "new",
-				"--roles", roles,
-				"--host-name", hostname,
+				"--roles", strings.Join(pool.Roles, ","),
+				"--host-name", pool.Name,
 				"--host-id", strconv.Itoa(id),
+				"--zone", d.nodeZone(id).Name,
 				"--ami-id", d.AmiID,
-				"--instance-type", itype)
+				"--instance-type", pool.InstanceType,
+			}
+
+			// Append the pool options:
+			if pool.DiskSize > 0 {
+				args = append(args, "--disk-size", strconv.FormatInt(pool.DiskSize, 10))
+			}
+			for _, tag := range pool.tags() {
+				args = append(args, "--tag", tag)
+			}
+
+			// Forge the add command:
+			cmdAdd := exec.Command("katoctl", args...)
 
 			// Execute the add command:
 			cmdAdd.Stderr = os.Stderr
@@ -141,3 +227,44 @@ func (d *Data) deployNodes(wch *kato.WaitChan, count int, itype, hostname, roles
 	// Wait:
 	wgInt.Wait()
 }
+
+//-----------------------------------------------------------------------------
+// func: setupEtcdCA
+//-----------------------------------------------------------------------------
+
+// Store the given etcd CA, or a new one if none is stored yet, next to the
+// cluster PKI in the state backend so that any host can add nodes.
+func (d *Data) setupEtcdCA() error {
+	if (d.EtcdCaCert == "") != (d.EtcdCaKey == "") {
+		return errors.New("Ops! --etcd-ca-cert and --etcd-ca-key go together")
+	}
+	_, err := pki.EnsureEtcdCA(d.ClusterID, d.EtcdCaCert, d.EtcdCaKey)
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: staticQuorumIPs
+//-----------------------------------------------------------------------------
+
+// Fixed private IPs of the quorum nodes, in host ID order.
+func (d *Data) staticQuorumIPs() (ips []string) {
+	for _, p := range d.nodePools() {
+		if contains(p.Roles, "quorum") {
+			for i := 1; i <= p.Count; i++ {
+				z := d.nodeZone(i)
+				ips = append(ips, d.fixedIP(&z, strings.Join(p.Roles, ","), i))
+			}
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: nodeZone
+//-----------------------------------------------------------------------------
+
+// Spread the nodes of every quadruplet round-robin across the zones, so that
+// quorum and master nodes survive the loss of one zone.
+func (d *Data) nodeZone(id int) AvailZone {
+	return d.Zones[(id-1)%len(d.Zones)]
+}
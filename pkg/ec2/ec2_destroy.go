@@ -0,0 +1,737 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"strings"
+	"time"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/katosys/kato/pkg/kato"
+)
+
+//-----------------------------------------------------------------------------
+// func: Destroy
+//-----------------------------------------------------------------------------
+
+// Destroy all the cluster resources recorded in the state file.
+func (d *Data) Destroy() {
+
+	// Set current command:
+	d.command = "destroy"
+
+	// Hold the state lock until done, no add or apply may race the teardown:
+	lock, err := kato.LockState(d.ClusterID)
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+	defer func() { _ = kato.UnlockState(lock) }()
+	d.locked = true
+
+	// Load state from state file:
+	if err := d.loadState(); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Connect and authenticate to the API endpoints:
+	d.setupAPIEndpoints()
+
+	// Delete the resources in dependency order:
+	for _, step := range []func() error{
+		d.terminateInstances,
+		d.deleteELB,
+		d.deleteNatGateways,
+		d.releaseElasticIPs,
+		d.deleteInternetGateway,
+		d.deleteSecurityGroups,
+		d.deleteSubnets,
+		d.deleteRouteTables,
+		d.deleteVPC,
+		d.deleteKMSPolicy,
+		d.deleteIAMSecurity,
+	} {
+		if err := step(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
+	// Delete the DNS zones:
+	if d.DNSProvider != "" && d.DNSProvider != "none" {
+		if d.dryRun("dns zone", d.Domain) {
+			return
+		}
+		wch := kato.NewWaitChan(1)
+		go kato.DeleteDNSZones(wch, d.DNSProvider, d.DNSApiKey, d.Domain)
+		if err := wch.WaitErr(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
+	// Remove the state file:
+	if !d.DryRun {
+		if err := kato.DeleteState(d.ClusterID); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: dryRun
+//-----------------------------------------------------------------------------
+
+func (d *Data) dryRun(kind, id string) bool {
+
+	// Log what would be deleted:
+	if d.DryRun {
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": id}).
+			Info("Dry run: would delete " + kind)
+	}
+
+	return d.DryRun
+}
+
+//-----------------------------------------------------------------------------
+// func: forget
+//-----------------------------------------------------------------------------
+
+// Clear a deleted resource ID and persist the state so that a re-run after
+// a partial failure skips what is already gone. Destroy holds the state lock
+// so nobody else writes it meanwhile.
+func (d *Data) forget(ids ...*string) error {
+	for _, id := range ids {
+		*id = ""
+	}
+	return kato.DumpState(d.State, d.ClusterID)
+}
+
+//-----------------------------------------------------------------------------
+// func: isAWSError
+//-----------------------------------------------------------------------------
+
+func isAWSError(err error, codes ...string) bool {
+	if awsErr, ok := err.(awserr.Error); ok {
+		for _, code := range codes {
+			if strings.Contains(awsErr.Code(), code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
+// func: retryOnDependency
+//-----------------------------------------------------------------------------
+
+// Retry while AWS is still releasing resources that depend on this one.
+func (d *Data) retryOnDependency(fn func() error) (err error) {
+	for i := 0; i < 30; i++ {
+		if err = fn(); !isAWSError(err, "DependencyViolation", "InUse") {
+			return err
+		}
+		time.Sleep(10 * time.Second)
+	}
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: terminateInstances
+//-----------------------------------------------------------------------------
+
+func (d *Data) terminateInstances() error {
+
+	// Return if no VPC:
+	if d.VpcID == "" {
+		return nil
+	}
+
+	// Forge the description request:
+	params := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: []*string{aws.String("pending"), aws.String("running"),
+					aws.String("stopping"), aws.String("stopped")},
+			},
+		},
+	}
+
+	// Instances recorded in the state, some may predate the cluster tag:
+	recorded := map[string]bool{}
+	for _, n := range d.Nodes {
+		recorded[n.InstanceID] = true
+	}
+
+	// Collect the IDs of the instances of this cluster only:
+	var ids []*string
+	if err := d.ec2.DescribeInstancesPages(params,
+		func(page *ec2.DescribeInstancesOutput, last bool) bool {
+			for _, r := range page.Reservations {
+				for _, i := range r.Instances {
+					if !hasTag(i.Tags, clusterTag, d.ClusterID) && !recorded[*i.InstanceId] {
+						log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *i.InstanceId}).
+							Warning("Instance not in this cluster, not terminating it")
+						continue
+					}
+					if !d.dryRun("instance", *i.InstanceId) {
+						ids = append(ids, i.InstanceId)
+					}
+				}
+			}
+			return true
+		}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Return if nothing to terminate:
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Send the termination request:
+	if _, err := d.ec2.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: ids,
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Wait until all instances are terminated:
+	log.WithField("cmd", "ec2:"+d.command).
+		Info("Waiting until instances are terminated")
+	if err := d.ec2.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{
+		InstanceIds: ids,
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteELB
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteELB() error {
+
+	// Return if not defined:
+	if d.DNSName == "" || d.dryRun("elb", d.ClusterID) {
+		return nil
+	}
+
+	// Send the ELB deletion request:
+	if _, err := d.elb.DeleteLoadBalancer(&elb.DeleteLoadBalancerInput{
+		LoadBalancerName: aws.String(d.ClusterID),
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+		Info("ELB deleted")
+
+	return d.forget(&d.DNSName, &d.ELBZoneID)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteNatGateways
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteNatGateways() error {
+	for i := range d.Zones {
+		if err := d.deleteNatGateway(&d.Zones[i].NatGatewayID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteNatGateway
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteNatGateway(id *string) error {
+
+	// Return if not defined:
+	if *id == "" || d.dryRun("nat gateway", *id) {
+		return nil
+	}
+
+	// Send the NAT gateway deletion request:
+	if _, err := d.ec2.DeleteNatGateway(&ec2.DeleteNatGatewayInput{
+		NatGatewayId: aws.String(*id),
+	}); err != nil && !isAWSError(err, "NotFound") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Wait until the NAT gateway is deleted:
+	log.WithField("cmd", "ec2:"+d.command).
+		Info("Waiting until NAT gateway is deleted")
+	for i := 0; i < 60; i++ {
+		resp, err := d.ec2.DescribeNatGateways(&ec2.DescribeNatGatewaysInput{
+			NatGatewayIds: []*string{aws.String(*id)},
+		})
+		if err != nil && !isAWSError(err, "NotFound") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+		if err != nil || len(resp.NatGateways) == 0 ||
+			*resp.NatGateways[0].State == ec2.NatGatewayStateDeleted {
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+		Info("NAT gateway deleted")
+
+	return d.forget(id)
+}
+
+//-----------------------------------------------------------------------------
+// func: releaseElasticIPs
+//-----------------------------------------------------------------------------
+
+func (d *Data) releaseElasticIPs() error {
+	for i := range d.Zones {
+		if err := d.releaseElasticIP(&d.Zones[i].AllocationID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: releaseElasticIP
+//-----------------------------------------------------------------------------
+
+func (d *Data) releaseElasticIP(id *string) error {
+
+	// Return if not defined:
+	if *id == "" || d.dryRun("elastic ip", *id) {
+		return nil
+	}
+
+	// Send the release request:
+	if err := d.retryOnDependency(func() error {
+		_, err := d.ec2.ReleaseAddress(&ec2.ReleaseAddressInput{
+			AllocationId: aws.String(*id),
+		})
+		return err
+	}); err != nil && !isAWSError(err, "NotFound") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+		Info("Elastic IP released")
+
+	return d.forget(id)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteInternetGateway
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteInternetGateway() error {
+
+	// Return if not defined:
+	if d.InetGatewayID == "" || d.dryRun("internet gateway", d.InetGatewayID) {
+		return nil
+	}
+
+	// Detach the internet gateway from the VPC:
+	if err := d.retryOnDependency(func() error {
+		_, err := d.ec2.DetachInternetGateway(&ec2.DetachInternetGatewayInput{
+			InternetGatewayId: aws.String(d.InetGatewayID),
+			VpcId:             aws.String(d.VpcID),
+		})
+		return err
+	}); err != nil && !isAWSError(err, "NotFound", "Gateway.NotAttached") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Delete the internet gateway:
+	if _, err := d.ec2.DeleteInternetGateway(&ec2.DeleteInternetGatewayInput{
+		InternetGatewayId: aws.String(d.InetGatewayID),
+	}); err != nil && !isAWSError(err, "NotFound") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.InetGatewayID}).
+		Info("Internet gateway deleted")
+
+	return d.forget(&d.InetGatewayID)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteSecurityGroups
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteSecurityGroups() error {
+
+	groups := []*string{&d.ELBSecGrp, &d.QuorumSecGrp,
+		&d.MasterSecGrp, &d.WorkerSecGrp, &d.BorderSecGrp}
+
+	// Revoke the rules first, groups reference each other:
+	for _, id := range groups {
+		if *id == "" || d.DryRun {
+			continue
+		}
+		if err := d.revokeSecurityGroupIngress(*id); err != nil {
+			return err
+		}
+	}
+
+	// Delete the groups:
+	for _, id := range groups {
+
+		if *id == "" || d.dryRun("security group", *id) {
+			continue
+		}
+
+		if err := d.retryOnDependency(func() error {
+			_, err := d.ec2.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+				GroupId: aws.String(*id),
+			})
+			return err
+		}); err != nil && !isAWSError(err, "NotFound") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+			Info("Security group deleted")
+
+		if err := d.forget(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: revokeSecurityGroupIngress
+//-----------------------------------------------------------------------------
+
+func (d *Data) revokeSecurityGroupIngress(id string) error {
+
+	// Send the description request:
+	resp, err := d.ec2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		GroupIds: []*string{aws.String(id)},
+	})
+	if err != nil {
+		if isAWSError(err, "NotFound") {
+			return nil
+		}
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Revoke all the ingress rules:
+	for _, grp := range resp.SecurityGroups {
+		if len(grp.IpPermissions) == 0 {
+			continue
+		}
+		if _, err := d.ec2.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+			GroupId:       grp.GroupId,
+			IpPermissions: grp.IpPermissions,
+		}); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteSubnets
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteSubnets() error {
+
+	// Collect the subnets of all the zones:
+	var subnets []*string
+	for i := range d.Zones {
+		subnets = append(subnets, &d.Zones[i].IntSubnetID, &d.Zones[i].ExtSubnetID)
+	}
+
+	// For each subnet:
+	for _, id := range subnets {
+
+		if *id == "" || d.dryRun("subnet", *id) {
+			continue
+		}
+
+		if err := d.retryOnDependency(func() error {
+			_, err := d.ec2.DeleteSubnet(&ec2.DeleteSubnetInput{
+				SubnetId: aws.String(*id),
+			})
+			return err
+		}); err != nil && !isAWSError(err, "NotFound") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+			Info("Subnet deleted")
+
+		if err := d.forget(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteRouteTables
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteRouteTables() error {
+
+	// The internal route tables, the first zone uses the main one:
+	for i := range d.Zones {
+		if err := d.deleteRouteTable(&d.Zones[i].IntRouteTableID); err != nil {
+			return err
+		}
+	}
+
+	// The external route table:
+	return d.deleteRouteTable(&d.RouteTableID)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteRouteTable
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteRouteTable(id *string) error {
+
+	// Return if not defined:
+	if *id == "" || d.dryRun("route table", *id) {
+		return nil
+	}
+
+	// Send the route table deletion request:
+	if err := d.retryOnDependency(func() error {
+		_, err := d.ec2.DeleteRouteTable(&ec2.DeleteRouteTableInput{
+			RouteTableId: aws.String(*id),
+		})
+		return err
+	}); err != nil && !isAWSError(err, "NotFound") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+		Info("Route table deleted")
+
+	return d.forget(id)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteVPC
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteVPC() error {
+
+	// Return if not defined:
+	if d.VpcID == "" || d.dryRun("vpc", d.VpcID) {
+		return nil
+	}
+
+	// Send the VPC deletion request:
+	if err := d.retryOnDependency(func() error {
+		_, err := d.ec2.DeleteVpc(&ec2.DeleteVpcInput{
+			VpcId: aws.String(d.VpcID),
+		})
+		return err
+	}); err != nil && !isAWSError(err, "NotFound") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.VpcID}).
+		Info("VPC deleted")
+
+	return d.forget(&d.VpcID, &d.MainRouteTableID)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteKMSPolicy
+//-----------------------------------------------------------------------------
+
+// The KMS policy belongs to this cluster, even when the role is kept. The key
+// itself is not managed by kato.
+func (d *Data) deleteKMSPolicy() error {
+
+	if d.KmsKeyID == "" || d.dryRun("iam role policy", kmsPolicyName(d.ClusterID)) {
+		return nil
+	}
+
+	// Delete the inline policy:
+	if _, err := d.iam.DeleteRolePolicy(&iam.DeleteRolePolicyInput{
+		PolicyName: aws.String(kmsPolicyName(d.ClusterID)),
+		RoleName:   aws.String("kato"),
+	}); err != nil && !isAWSError(err, "NoSuchEntity") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": kmsPolicyName(d.ClusterID)}).
+		Info("IAM role policy deleted")
+
+	return d.forget(&d.KmsKeyID)
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteIAMSecurity
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteIAMSecurity() error {
+
+	// The kato role and profile are shared by all the clusters in the account:
+	if !d.DeleteIAM {
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": "kato"}).
+			Info("Keeping the shared IAM role, profile and policy, see --delete-iam")
+		return nil
+	}
+
+	// Keep them while other instances still use the profile:
+	inUse, err := d.instanceProfileInUse()
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+	if inUse {
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": "kato"}).
+			Warning("IAM instance profile still in use, not deleting it")
+		return nil
+	}
+
+	if d.dryRun("iam role, profile and policy", "kato") {
+		return nil
+	}
+
+	// Remove the role from the instance profile:
+	if _, err := d.iam.RemoveRoleFromInstanceProfile(&iam.RemoveRoleFromInstanceProfileInput{
+		InstanceProfileName: aws.String("kato"),
+		RoleName:            aws.String("kato"),
+	}); err != nil && !isAWSError(err, "NoSuchEntity") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Delete the instance profile:
+	if _, err := d.iam.DeleteInstanceProfile(&iam.DeleteInstanceProfileInput{
+		InstanceProfileName: aws.String("kato"),
+	}); err != nil && !isAWSError(err, "NoSuchEntity") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Detach the policies from the role:
+	for _, policy := range []string{
+		"arn:aws:iam::aws:policy/AmazonS3FullAccess",
+		"arn:aws:iam::aws:policy/AmazonRoute53FullAccess",
+		d.RexrayPolicy,
+	} {
+		if policy == "" {
+			continue
+		}
+		if _, err := d.iam.DetachRolePolicy(&iam.DetachRolePolicyInput{
+			PolicyArn: aws.String(policy),
+			RoleName:  aws.String("kato"),
+		}); err != nil && !isAWSError(err, "NoSuchEntity") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+	}
+
+	// Delete the role:
+	if _, err := d.iam.DeleteRole(&iam.DeleteRoleInput{
+		RoleName: aws.String("kato"),
+	}); err != nil && !isAWSError(err, "NoSuchEntity") {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Delete the REX-Ray policy:
+	if d.RexrayPolicy != "" {
+		if _, err := d.iam.DeletePolicy(&iam.DeletePolicyInput{
+			PolicyArn: aws.String(d.RexrayPolicy),
+		}); err != nil && !isAWSError(err, "NoSuchEntity") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": "kato"}).
+		Info("IAM role, profile and policy deleted")
+
+	return d.forget(&d.KatoRoleID, &d.RexrayPolicy)
+}
+
+//-----------------------------------------------------------------------------
+// func: instanceProfileInUse
+//-----------------------------------------------------------------------------
+
+// Whether instances of other clusters still use the kato instance profile.
+// Only the current region is checked.
+func (d *Data) instanceProfileInUse() (bool, error) {
+
+	// Get the instance profile ARN:
+	resp, err := d.iam.GetInstanceProfile(&iam.GetInstanceProfileInput{
+		InstanceProfileName: aws.String("kato"),
+	})
+	if err != nil {
+		if isAWSError(err, "NoSuchEntity") {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// Forge the description request:
+	params := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("iam-instance-profile.arn"),
+				Values: []*string{resp.InstanceProfile.Arn},
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: []*string{aws.String("pending"), aws.String("running"),
+					aws.String("stopping"), aws.String("stopped")},
+			},
+		},
+	}
+
+	// The instances of this cluster are already terminated:
+	out, err := d.ec2.DescribeInstances(params)
+	if err != nil {
+		return false, err
+	}
+
+	return len(out.Reservations) > 0, nil
+}
@@ -5,6 +5,12 @@ package ec2
 //-----------------------------------------------------------------------------
 
 import (
+
+	// Stdlib:
+	"errors"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
 	"github.com/katosys/kato/pkg/cli"
 )
 
@@ -35,6 +41,15 @@ var (
 	Ec2Zones = []string{
 		"a", "b", "c", "d"}
 
+	// CoreOS versions are 'current' or <major>.<minor>.<patch>:
+	coreosVersionRegexp = "^(current|\\d+\\.\\d+\\.\\d+)$"
+
+	// Etcd discovery sources are 'public', 'static' or an URL:
+	etcdDiscoveryRegexp = "^(public|static|https?://.+)$"
+
+	// IAM policies need the full KMS key ARN:
+	kmsKeyRegexp = "^arn:aws:kms:[a-z0-9-]+:\\d{12}:key/[a-f0-9-]+$"
+
 	//------------------------
 	// ec2: top level command
 	//------------------------
@@ -50,7 +65,7 @@ var (
 
 	flEc2DeployClusterID = cli.RegexpMatch(cmdEc2Deploy.Flag("cluster-id",
 		"Cluster ID for later reference.").
-		Required().PlaceHolder("KATO_EC2_DEPLOY_CLUSTER_ID").
+		PlaceHolder("KATO_EC2_DEPLOY_CLUSTER_ID").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
 
 	flEc2DeployCoreOSChannel = cmdEc2Deploy.Flag("coreos-channel",
@@ -59,16 +74,43 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_COREOS_CHANNEL").
 		Enum("stable", "beta", "alpha")
 
+	flEc2DeployCoreOSVersion = cli.RegexpMatch(cmdEc2Deploy.Flag("coreos-version",
+		"CoreOS version [ current | <version> ]").
+		Default("current").PlaceHolder("KATO_EC2_DEPLOY_COREOS_VERSION").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_COREOS_VERSION"), coreosVersionRegexp)
+
 	flEc2DeployEtcdToken = cmdEc2Deploy.Flag("etcd-token",
 		"Etcd bootstrap token [ auto | <token> ]").
 		Default("auto").OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ETCD_TOKEN").
 		HintOptions("auto").String()
 
+	flEc2DeployEtcdDiscovery = cli.RegexpMatch(cmdEc2Deploy.Flag("etcd-discovery",
+		"Etcd discovery [ public | static | <url>/new | <url> ]").
+		Default("public").PlaceHolder("KATO_EC2_DEPLOY_ETCD_DISCOVERY").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ETCD_DISCOVERY"), etcdDiscoveryRegexp)
+
+	flEc2DeployEtcdTLS = cmdEc2Deploy.Flag("etcd-tls",
+		"Secure etcd peer and client traffic with TLS.").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ETCD_TLS").
+		Bool()
+
+	flEc2DeployEtcdCaCert = cmdEc2Deploy.Flag("etcd-ca-cert",
+		"Path to the etcd CA certificate, generated if not given.").
+		PlaceHolder("KATO_EC2_DEPLOY_ETCD_CA_CERT").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ETCD_CA_CERT").
+		ExistingFile()
+
+	flEc2DeployEtcdCaKey = cmdEc2Deploy.Flag("etcd-ca-key",
+		"Path to the etcd CA private key.").
+		PlaceHolder("KATO_EC2_DEPLOY_ETCD_CA_KEY").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ETCD_CA_KEY").
+		ExistingFile()
+
 	flEc2DeployDNSProvider = cmdEc2Deploy.Flag("dns-provider",
-		"DNS provider [ none | ns1 | r53 ]").
+		"DNS provider [ none | ns1 | r53 | rfc2136 ]").
 		Default("r53").PlaceHolder("KATO_EC2_DEPLOY_DNS_PROVIDER").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_DNS_PROVIDER").
-		Enum("none", "ns1", "r53")
+		Enum("none", "ns1", "r53", "rfc2136")
 
 	flEc2DeployDNSApiKey = cmdEc2Deploy.Flag("dns-api-key",
 		"DNS private API key.").
@@ -76,6 +118,21 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_DNS_API_KEY").
 		String()
 
+	flEc2DeployPublicIntZone = cmdEc2Deploy.Flag("public-int-zone",
+		"Create int.<domain> as a public zone instead of a VPC private one.").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_PUBLIC_INT_ZONE").
+		Bool()
+
+	flEc2DeployPKI = cmdEc2Deploy.Flag("pki",
+		"Issue every node a certificate from the cluster CA (see katoctl pki).").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_PKI").
+		Bool()
+
+	flEc2DeployKmsKeyID = cli.RegexpMatch(cmdEc2Deploy.Flag("kms-key-id",
+		"ARN of the KMS key sealing the node secrets, clear user data if not given.").
+		PlaceHolder("KATO_EC2_DEPLOY_KMS_KEY_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_KMS_KEY_ID"), kmsKeyRegexp)
+
 	flEc2DeployCaCertPath = cmdEc2Deploy.Flag("ca-cert-path",
 		"Path to CA certificate.").
 		PlaceHolder("KATO_EC2_DEPLOY_CA_CERT_PATH").
@@ -84,25 +141,25 @@ var (
 
 	flEc2DeployRegion = cmdEc2Deploy.Flag("region",
 		"Amazon EC2 region.").
-		Required().PlaceHolder("KATO_EC2_DEPLOY_REGION").
+		PlaceHolder("KATO_EC2_DEPLOY_REGION").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_REGION").
 		Enum(Ec2Regions...)
 
-	flEc2DeployZone = cmdEc2Deploy.Flag("zone",
-		"Amazon EC2 availability zone.").
+	flEc2DeployZones = cmdEc2Deploy.Flag("zone",
+		"Amazon EC2 availability zone (repeatable).").
 		Default("a").PlaceHolder("KATO_EC2_DEPLOY_ZONE").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ZONE").
-		Enum(Ec2Zones...)
+		Enums(Ec2Zones...)
 
 	flEc2DeployDomain = cmdEc2Deploy.Flag("domain",
 		"Used to identify the VPC.").
-		Required().PlaceHolder("KATO_EC2_DEPLOY_DOMAIN").
+		PlaceHolder("KATO_EC2_DEPLOY_DOMAIN").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_DOMAIN").
 		String()
 
 	flEc2DeployKeyPair = cmdEc2Deploy.Flag("key-pair",
 		"EC2 key pair.").
-		Required().PlaceHolder("KATO_EC2_DEPLOY_KEY_PAIR").
+		PlaceHolder("KATO_EC2_DEPLOY_KEY_PAIR").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_KEY_PAIR").
 		String()
 
@@ -118,16 +175,16 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_CALICO_IP_POOL").
 		String()
 
-	flEc2DeployIntSubnetCidr = cmdEc2Deploy.Flag("internal-subnet-cidr",
-		"CIDR for the internal subnet.").
+	flEc2DeployIntSubnetCidrs = cmdEc2Deploy.Flag("internal-subnet-cidr",
+		"CIDR for the internal subnet (one per zone).").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_INTERNAL_SUBNET_CIDR").
-		String()
+		Strings()
 
-	flEc2DeployExtSubnetCidr = cmdEc2Deploy.Flag("external-subnet-cidr",
-		"CIDR for the external subnet.").
+	flEc2DeployExtSubnetCidrs = cmdEc2Deploy.Flag("external-subnet-cidr",
+		"CIDR for the external subnet (one per zone).").
 		Default("10.0.0.0/24").
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_EXTERNAL_SUBNET_CIDR").
-		String()
+		Strings()
 
 	flEc2DeployStubZones = cmdEc2Deploy.Flag("stub-zone",
 		"Use different nameservers for given domains.").
@@ -152,8 +209,48 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_ADMIN_EMAIL"), "^[\\w-.+]+@[\\w-.+]+\\.[a-z]{2,4}$")
 
 	arEc2DeployQuadruplet = cli.Quadruplets(cmdEc2Deploy.Arg("quadruplet",
-		"<number_of_instances>:<instance_type>:<host_name>:<comma_separated_list_of_roles>").
-		Required(), Ec2Instances, cli.KatoRoles)
+		"<number_of_instances>:<instance_type>:<host_name>:<comma_separated_list_of_roles>"),
+		Ec2Instances, cli.KatoRoles)
+
+	flEc2DeployPlan = cmdEc2Deploy.Flag("plan",
+		"Print what would be deployed without touching AWS.").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_PLAN").
+		Bool()
+
+	flEc2DeployFile = cmdEc2Deploy.Flag("file",
+		"Deploy the cluster described in a YAML or JSON spec file.").
+		Short('f').PlaceHolder("KATO_EC2_DEPLOY_FILE").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_FILE").
+		ExistingFile()
+
+	flEc2DeployApply = cmdEc2Deploy.Flag("apply",
+		"Deploy exactly what is described in a plan file.").
+		PlaceHolder("KATO_EC2_DEPLOY_APPLY").
+		OverrideDefaultFromEnvar("KATO_EC2_DEPLOY_APPLY").
+		ExistingFile()
+
+	//---------------------------
+	// ec2 apply: nested command
+	//---------------------------
+
+	cmdEc2Apply = cmdEc2.Command("apply",
+		"Adds, replaces and removes nodes to match the cluster spec.")
+
+	flEc2ApplyClusterID = cli.RegexpMatch(cmdEc2Apply.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_EC2_APPLY_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_APPLY_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flEc2ApplyFile = cmdEc2Apply.Flag("file",
+		"Desired YAML or JSON spec file, defaults to the stored one.").
+		Short('f').PlaceHolder("KATO_EC2_APPLY_FILE").
+		OverrideDefaultFromEnvar("KATO_EC2_APPLY_FILE").
+		ExistingFile()
+
+	flEc2ApplyDryRun = cmdEc2Apply.Flag("dry-run",
+		"Print the changes without applying them.").
+		OverrideDefaultFromEnvar("KATO_EC2_APPLY_DRY_RUN").
+		Bool()
 
 	//---------------------------
 	// ec2 setup: nested command
@@ -179,11 +276,11 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_SETUP_REGION").
 		Enum(Ec2Regions...)
 
-	flEc2SetupZone = cmdEc2Setup.Flag("zone",
-		"EC2 availability zone.").
+	flEc2SetupZones = cmdEc2Setup.Flag("zone",
+		"EC2 availability zone (repeatable).").
 		Default("a").PlaceHolder("KATO_EC2_SETUP_ZONE").
 		OverrideDefaultFromEnvar("KATO_EC2_SETUP_ZONE").
-		Enum(Ec2Zones...)
+		Enums(Ec2Zones...)
 
 	flEc2SetupVpcCidrBlock = cmdEc2Setup.Flag("vpc-cidr-block",
 		"IPs to be used by the VPC.").
@@ -191,16 +288,21 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_SETUP_VPC_CIDR_BLOCK").
 		String()
 
-	flEc2SetupIntSubnetCidr = cmdEc2Setup.Flag("internal-subnet-cidr",
-		"CIDR for the internal subnet.").
+	flEc2SetupIntSubnetCidrs = cmdEc2Setup.Flag("internal-subnet-cidr",
+		"CIDR for the internal subnet (one per zone).").
 		OverrideDefaultFromEnvar("KATO_EC2_SETUP_INTERNAL_SUBNET_CIDR").
-		String()
+		Strings()
 
-	flEc2SetupExtSubnetCidr = cmdEc2Setup.Flag("external-subnet-cidr",
-		"CIDR for the external subnet.").
+	flEc2SetupExtSubnetCidrs = cmdEc2Setup.Flag("external-subnet-cidr",
+		"CIDR for the external subnet (one per zone).").
 		Default("10.0.0.0/24").
 		OverrideDefaultFromEnvar("KATO_EC2_SETUP_EXTERNAL_SUBNET_CIDR").
-		String()
+		Strings()
+
+	flEc2SetupKmsKeyID = cli.RegexpMatch(cmdEc2Setup.Flag("kms-key-id",
+		"ARN of the KMS key the nodes are allowed to decrypt with.").
+		PlaceHolder("KATO_EC2_SETUP_KMS_KEY_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_SETUP_KMS_KEY_ID"), kmsKeyRegexp)
 
 	//-------------------------
 	// ec2 add: nested command
@@ -244,12 +346,131 @@ var (
 		OverrideDefaultFromEnvar("KATO_EC2_ADD_INSTANCE_TYPE").
 		Enum(Ec2Instances...)
 
+	flEc2AddZone = cmdEc2Add.Flag("zone",
+		"EC2 availability zone, defaults to the first cluster zone.").
+		PlaceHolder("KATO_EC2_ADD_ZONE").
+		OverrideDefaultFromEnvar("KATO_EC2_ADD_ZONE").
+		Enum(Ec2Zones...)
+
 	flEc2AddClusterState = cmdEc2Add.Flag("cluster-state",
 		"Initial cluster state [ new | existing ]").
 		Default("existing").PlaceHolder("KATO_EC2_ADD_CLUSTER_STATE").
 		OverrideDefaultFromEnvar("KATO_EC2_ADD_CLUSTER_STATE").
 		HintOptions("new", "existing").String()
 
+	flEc2AddDiskSize = cmdEc2Add.Flag("disk-size",
+		"Root volume size in GiB, defaults to the AMI size.").
+		PlaceHolder("KATO_EC2_ADD_DISK_SIZE").
+		OverrideDefaultFromEnvar("KATO_EC2_ADD_DISK_SIZE").
+		Int64()
+
+	flEc2AddTags = cmdEc2Add.Flag("tag",
+		"Extra <key>=<value> instance tag (repeatable).").
+		PlaceHolder("KATO_EC2_ADD_TAG").
+		OverrideDefaultFromEnvar("KATO_EC2_ADD_TAG").
+		Strings()
+
+	//----------------------------
+	// ec2 remove: nested command
+	//----------------------------
+
+	cmdEc2Remove = cmdEc2.Command("remove",
+		"Removes an instance from an existing Káto cluster on EC2.")
+
+	flEc2RemoveClusterID = cli.RegexpMatch(cmdEc2Remove.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_EC2_REMOVE_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_REMOVE_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flEc2RemoveHostName = cmdEc2Remove.Flag("host-name",
+		"hostname = <host-name>-<host-id>").
+		Required().PlaceHolder("KATO_EC2_REMOVE_HOST_NAME").
+		OverrideDefaultFromEnvar("KATO_EC2_REMOVE_HOST_NAME").
+		String()
+
+	flEc2RemoveHostID = cmdEc2Remove.Flag("host-id",
+		"hostname = <host-name>-<host-id>").
+		Required().PlaceHolder("KATO_EC2_REMOVE_HOST_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_REMOVE_HOST_ID").
+		String()
+
+	//--------------------------
+	// ec2 list: nested command
+	//--------------------------
+
+	cmdEc2List = cmdEc2.Command("list",
+		"Lists the nodes of a Káto cluster on EC2.").Alias("status")
+
+	flEc2ListClusterID = cli.RegexpMatch(cmdEc2List.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_EC2_LIST_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_LIST_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flEc2ListOutput = cmdEc2List.Flag("output",
+		"Output format [ table | json | yaml ]").
+		Short('o').Default("table").PlaceHolder("KATO_EC2_LIST_OUTPUT").
+		OverrideDefaultFromEnvar("KATO_EC2_LIST_OUTPUT").
+		Enum("table", "json", "yaml")
+
+	//-----------------------------
+	// ec2 upgrade: nested command
+	//-----------------------------
+
+	cmdEc2Upgrade = cmdEc2.Command("upgrade",
+		"Replaces the nodes of a Káto cluster with the current CoreOS AMI.")
+
+	flEc2UpgradeClusterID = cli.RegexpMatch(cmdEc2Upgrade.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_EC2_UPGRADE_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_UPGRADE_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flEc2UpgradeAmiID = cmdEc2Upgrade.Flag("ami-id",
+		"Target AMI ID, defaults to the current channel AMI.").
+		PlaceHolder("KATO_EC2_UPGRADE_AMI_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_UPGRADE_AMI_ID").
+		String()
+
+	flEc2UpgradeCoreOSVersion = cli.RegexpMatch(cmdEc2Upgrade.Flag("coreos-version",
+		"Target CoreOS version [ current | <version> ], defaults to the cluster one.").
+		PlaceHolder("KATO_EC2_UPGRADE_COREOS_VERSION").
+		OverrideDefaultFromEnvar("KATO_EC2_UPGRADE_COREOS_VERSION"), coreosVersionRegexp)
+
+	flEc2UpgradeMaxUnavailable = cmdEc2Upgrade.Flag("max-unavailable",
+		"Worker and border nodes replaced at a time.").
+		Default("1").PlaceHolder("KATO_EC2_UPGRADE_MAX_UNAVAILABLE").
+		OverrideDefaultFromEnvar("KATO_EC2_UPGRADE_MAX_UNAVAILABLE").
+		Int()
+
+	flEc2UpgradePause = cmdEc2Upgrade.Flag("pause",
+		"Pause the running upgrade once its current batch is done.").
+		Bool()
+
+	flEc2UpgradeResume = cmdEc2Upgrade.Flag("resume",
+		"Resume a paused or failed upgrade.").
+		Bool()
+
+	//-----------------------------
+	// ec2 destroy: nested command
+	//-----------------------------
+
+	cmdEc2Destroy = cmdEc2.Command("destroy",
+		"Destroys a Káto cluster on EC2 using its state file.")
+
+	flEc2DestroyClusterID = cli.RegexpMatch(cmdEc2Destroy.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_EC2_DESTROY_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_EC2_DESTROY_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flEc2DestroyDryRun = cmdEc2Destroy.Flag("dry-run",
+		"Log what would be deleted without deleting anything.").
+		OverrideDefaultFromEnvar("KATO_EC2_DESTROY_DRY_RUN").
+		Bool()
+
+	flEc2DestroyDeleteIAM = cmdEc2Destroy.Flag("delete-iam",
+		"Also delete the account-wide kato IAM role, profile and policy.").
+		OverrideDefaultFromEnvar("KATO_EC2_DESTROY_DELETE_IAM").
+		Bool()
+
 	//-------------------------
 	// ec2 run: nested command
 	//-------------------------
@@ -326,8 +547,50 @@ var (
 	flEc2RunPrivateIP = cmdEc2Run.Flag("private-ip",
 		"The private IP address of the network interface.").
 		OverrideDefaultFromEnvar("KATO_EC2_RUN_PRIVATE_IP").String()
+
+	flEc2RunDiskSize = cmdEc2Run.Flag("disk-size",
+		"Root volume size in GiB, defaults to the AMI size.").
+		OverrideDefaultFromEnvar("KATO_EC2_RUN_DISK_SIZE").
+		Int64()
+
+	flEc2RunTags = cmdEc2Run.Flag("tag",
+		"Extra <key>=<value> instance tag (repeatable).").
+		OverrideDefaultFromEnvar("KATO_EC2_RUN_TAG").
+		Strings()
 )
 
+//-----------------------------------------------------------------------------
+// func: validateDeployFlags
+//-----------------------------------------------------------------------------
+
+// Deploy flags are only optional when applying a plan or a spec file.
+func validateDeployFlags() error {
+
+	// Return if applying a plan or a spec file:
+	if *flEc2DeployApply != "" || *flEc2DeployFile != "" {
+		return nil
+	}
+
+	// Check the required flags:
+	for _, f := range []struct{ flag, value string }{
+		{"--cluster-id", *flEc2DeployClusterID},
+		{"--region", *flEc2DeployRegion},
+		{"--domain", *flEc2DeployDomain},
+		{"--key-pair", *flEc2DeployKeyPair},
+	} {
+		if f.value == "" {
+			return errors.New("required flag " + f.flag + " not provided")
+		}
+	}
+
+	// Check the required arguments:
+	if len(*arEc2DeployQuadruplet) == 0 {
+		return errors.New("required argument 'quadruplet' not provided")
+	}
+
+	return nil
+}
+
 //-----------------------------------------------------------------------------
 // RunCmd:
 //-----------------------------------------------------------------------------
@@ -339,42 +602,87 @@ func RunCmd(cmd string) bool {
 
 	// katoctl ec2 deploy
 	case cmdEc2Deploy.FullCommand():
+		if err := validateDeployFlags(); err != nil {
+			cli.App.Fatalf("%s, try --help", err)
+		}
+		zones, err := newAvailZones(*flEc2DeployZones,
+			*flEc2DeployIntSubnetCidrs, *flEc2DeployExtSubnetCidrs)
+		if err != nil {
+			cli.App.Fatalf("%s, try --help", err)
+		}
+		d := Data{
+			State: State{
+				ClusterID:     *flEc2DeployClusterID,
+				CoreOSChannel: *flEc2DeployCoreOSChannel,
+				CoreOSVersion: *flEc2DeployCoreOSVersion,
+				KeyPair:       *flEc2DeployKeyPair,
+				EtcdToken:     *flEc2DeployEtcdToken,
+				EtcdDiscovery: *flEc2DeployEtcdDiscovery,
+				EtcdTLS:       *flEc2DeployEtcdTLS,
+				DNSProvider:   *flEc2DeployDNSProvider,
+				PublicIntZone: *flEc2DeployPublicIntZone,
+				CaCertPath:    *flEc2DeployCaCertPath,
+				PKI:           *flEc2DeployPKI,
+				KmsKeyID:      *flEc2DeployKmsKeyID,
+				Domain:        *flEc2DeployDomain,
+				Region:        *flEc2DeployRegion,
+				Zones:         zones,
+				VpcCidrBlock:  *flEc2DeployVpcCidrBlock,
+				CalicoIPPool:  *flEc2DeployCalicoIPPool,
+				StubZones:     *flEc2DeployStubZones,
+				AdminEmail:    *flEc2DeployAdminEmail,
+				Quadruplets:   *arEc2DeployQuadruplet,
+			},
+			Secrets: Secrets{
+				DNSApiKey:    *flEc2DeployDNSApiKey,
+				SlackWebhook: *flEc2DeploySlackWebhook,
+				SMTPURL:      *flEc2DeploySMTPURL,
+			},
+			EtcdCaCert: *flEc2DeployEtcdCaCert,
+			EtcdCaKey:  *flEc2DeployEtcdCaKey,
+		}
+		if *flEc2DeployFile != "" {
+			if err := d.useSpec(*flEc2DeployFile); err != nil {
+				cli.App.Fatalf("%s", err)
+			}
+		}
+		if err := d.requireKMS(); err != nil {
+			cli.App.Fatalf("%s, try --help", err)
+		}
+		switch {
+		case *flEc2DeployApply != "":
+			d.Apply(*flEc2DeployApply)
+		case *flEc2DeployPlan:
+			d.Plan()
+		default:
+			d.Deploy()
+		}
+
+	// katoctl ec2 apply
+	case cmdEc2Apply.FullCommand():
 		d := Data{
+			DryRun: *flEc2ApplyDryRun,
 			State: State{
-				ClusterID:       *flEc2DeployClusterID,
-				CoreOSChannel:   *flEc2DeployCoreOSChannel,
-				KeyPair:         *flEc2DeployKeyPair,
-				EtcdToken:       *flEc2DeployEtcdToken,
-				DNSProvider:     *flEc2DeployDNSProvider,
-				DNSApiKey:       *flEc2DeployDNSApiKey,
-				CaCertPath:      *flEc2DeployCaCertPath,
-				Domain:          *flEc2DeployDomain,
-				Region:          *flEc2DeployRegion,
-				Zone:            *flEc2DeployZone,
-				VpcCidrBlock:    *flEc2DeployVpcCidrBlock,
-				CalicoIPPool:    *flEc2DeployCalicoIPPool,
-				IntSubnetCidr:   *flEc2DeployIntSubnetCidr,
-				ExtSubnetCidr:   *flEc2DeployExtSubnetCidr,
-				StubZones:       *flEc2DeployStubZones,
-				SlackWebhook:    *flEc2DeploySlackWebhook,
-				SMTPURL:         *flEc2DeploySMTPURL,
-				AdminEmail:      *flEc2DeployAdminEmail,
-				Quadruplets:     *arEc2DeployQuadruplet,
+				ClusterID: *flEc2ApplyClusterID,
 			},
 		}
-		d.Deploy()
+		d.Converge(*flEc2ApplyFile)
 
 	// katoctl ec2 setup
 	case cmdEc2Setup.FullCommand():
+		zones, err := newAvailZones(*flEc2SetupZones,
+			*flEc2SetupIntSubnetCidrs, *flEc2SetupExtSubnetCidrs)
+		if err != nil {
+			cli.App.Fatalf("%s, try --help", err)
+		}
 		d := Data{
 			State: State{
-				ClusterID:     *flEc2SetupClusterID,
-				Domain:        *flEc2SetupDomain,
-				Region:        *flEc2SetupRegion,
-				Zone:          *flEc2SetupZone,
-				VpcCidrBlock:  *flEc2SetupVpcCidrBlock,
-				IntSubnetCidr: *flEc2SetupIntSubnetCidr,
-				ExtSubnetCidr: *flEc2SetupExtSubnetCidr,
+				ClusterID:    *flEc2SetupClusterID,
+				Domain:       *flEc2SetupDomain,
+				Region:       *flEc2SetupRegion,
+				Zones:        zones,
+				VpcCidrBlock: *flEc2SetupVpcCidrBlock,
+				KmsKeyID:     *flEc2SetupKmsKeyID,
 			},
 		}
 		d.Setup()
@@ -384,6 +692,7 @@ func RunCmd(cmd string) bool {
 		d := Data{
 			State: State{
 				ClusterID: *flEc2AddCluserID,
+				Zone:      *flEc2AddZone,
 			},
 			Instance: Instance{
 				Roles:        *flEc2AddRoles,
@@ -392,9 +701,70 @@ func RunCmd(cmd string) bool {
 				AmiID:        *flEc2AddAmiID,
 				InstanceType: *flEc2AddInsanceType,
 				ClusterState: *flEc2AddClusterState,
+				DiskSize:     *flEc2AddDiskSize,
+				Tags:         *flEc2AddTags,
+			},
+		}
+		if err := d.Add(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+
+	// katoctl ec2 remove
+	case cmdEc2Remove.FullCommand():
+		d := Data{
+			State: State{
+				ClusterID: *flEc2RemoveClusterID,
+			},
+			Instance: Instance{
+				HostName: *flEc2RemoveHostName,
+				HostID:   *flEc2RemoveHostID,
+			},
+		}
+		if err := d.Remove(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+
+	// katoctl ec2 list
+	case cmdEc2List.FullCommand():
+		d := Data{
+			Output: *flEc2ListOutput,
+			State: State{
+				ClusterID: *flEc2ListClusterID,
+			},
+		}
+		d.List()
+
+	// katoctl ec2 upgrade
+	case cmdEc2Upgrade.FullCommand():
+		if *flEc2UpgradeMaxUnavailable < 1 {
+			cli.App.Fatalf("--max-unavailable must be at least 1, try --help")
+		}
+		d := Data{
+			MaxUnavailable: *flEc2UpgradeMaxUnavailable,
+			State: State{
+				ClusterID:     *flEc2UpgradeClusterID,
+				CoreOSVersion: *flEc2UpgradeCoreOSVersion,
+			},
+			Instance: Instance{
+				AmiID: *flEc2UpgradeAmiID,
+			},
+		}
+		if *flEc2UpgradePause {
+			d.PauseUpgrade()
+		} else {
+			d.Upgrade(*flEc2UpgradeResume)
+		}
+
+	// katoctl ec2 destroy
+	case cmdEc2Destroy.FullCommand():
+		d := Data{
+			DryRun:    *flEc2DestroyDryRun,
+			DeleteIAM: *flEc2DestroyDeleteIAM,
+			State: State{
+				ClusterID: *flEc2DestroyClusterID,
 			},
 		}
-		d.Add()
+		d.Destroy()
 
 	// katoctl ec2 run
 	case cmdEc2Run.FullCommand():
@@ -415,6 +785,8 @@ func RunCmd(cmd string) bool {
 				AmiID:        *flEc2RunAmiID,
 				ELBName:      *flEc2RunELBName,
 				PrivateIP:    *flEc2RunPrivateIP,
+				DiskSize:     *flEc2RunDiskSize,
+				Tags:         *flEc2RunTags,
 			},
 		}
 		d.Run()
@@ -0,0 +1,272 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/ajeddeloh/yaml"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Reconciled node data.
+type nodeStatus struct {
+	HostName     string `json:"HostName" yaml:"HostName"`
+	HostID       string `json:"HostID" yaml:"HostID"`
+	Roles        string `json:"Roles" yaml:"Roles"`
+	InstanceType string `json:"InstanceType" yaml:"InstanceType"`
+	InstanceID   string `json:"InstanceID" yaml:"InstanceID"`
+	AmiID        string `json:"AmiID" yaml:"AmiID"`
+	State        string `json:"State" yaml:"State"`
+	PrivateIP    string `json:"PrivateIP" yaml:"PrivateIP"`
+	PublicIP     string `json:"PublicIP" yaml:"PublicIP"`
+	ELB          string `json:"ELB" yaml:"ELB"`
+}
+
+//-----------------------------------------------------------------------------
+// func: List
+//-----------------------------------------------------------------------------
+
+// List the cluster nodes and their current status.
+func (d *Data) List() {
+
+	// Set current command:
+	d.command = "list"
+
+	// Load state from state file:
+	if err := d.loadState(); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Connect and authenticate to the API endpoints:
+	d.setupAPIEndpoints()
+
+	// Retrieve the cluster instances:
+	instances, err := d.describeClusterInstances()
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Retrieve the ELB health:
+	health, err := d.describeELBHealth()
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Warning(err)
+	}
+
+	// Reconcile and print:
+	nodes := d.reconcileNodes(instances, health)
+	if err := printNodes(os.Stdout, d.Output, nodes); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: describeClusterInstances
+//-----------------------------------------------------------------------------
+
+func (d *Data) describeClusterInstances() ([]*ec2.Instance, error) {
+
+	// Forge the description request:
+	params := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
+		},
+	}
+
+	// Collect the instances:
+	var instances []*ec2.Instance
+	if err := d.ec2.DescribeInstancesPages(params,
+		func(page *ec2.DescribeInstancesOutput, last bool) bool {
+			for _, r := range page.Reservations {
+				instances = append(instances, r.Instances...)
+			}
+			return true
+		}); err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: describeELBHealth
+//-----------------------------------------------------------------------------
+
+func (d *Data) describeELBHealth() (map[string]string, error) {
+
+	// Send the health request:
+	resp, err := d.elb.DescribeInstanceHealth(&elb.DescribeInstanceHealthInput{
+		LoadBalancerName: aws.String(d.ClusterID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Map instance IDs to their ELB state:
+	health := map[string]string{}
+	for _, s := range resp.InstanceStates {
+		health[*s.InstanceId] = *s.State
+	}
+
+	return health, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: reconcileNodes
+//-----------------------------------------------------------------------------
+
+// Merge the state file inventory with what AWS reports. Nodes recorded but
+// gone are 'missing', instances found but never recorded are listed too.
+func (d *Data) reconcileNodes(instances []*ec2.Instance, health map[string]string) []nodeStatus {
+
+	// Index the instances:
+	byID := map[string]*ec2.Instance{}
+	for _, i := range instances {
+		if *i.State.Name != ec2.InstanceStateNameTerminated {
+			byID[*i.InstanceId] = i
+		}
+	}
+
+	var nodes []nodeStatus
+	seen := map[string]bool{}
+
+	// Recorded nodes:
+	for _, n := range d.Nodes {
+		ns := nodeStatus{
+			HostName:     n.HostName,
+			HostID:       n.HostID,
+			Roles:        n.Roles,
+			InstanceType: n.InstanceType,
+			InstanceID:   n.InstanceID,
+			State:        "missing",
+			PrivateIP:    n.PrivateIP,
+			PublicIP:     n.PublicIP,
+		}
+		if i, ok := byID[n.InstanceID]; ok {
+			ns = d.instanceStatus(ns, i)
+			seen[n.InstanceID] = true
+		}
+		ns.ELB = health[n.InstanceID]
+		nodes = append(nodes, ns)
+	}
+
+	// Unrecorded instances:
+	for id, i := range byID {
+		if seen[id] {
+			continue
+		}
+		ns := nodeStatus{InstanceID: id, Roles: d.rolesFromSecGrps(i.SecurityGroups)}
+		for _, t := range i.Tags {
+			if *t.Key == "Name" {
+				host := strings.SplitN(*t.Value, ".", 2)[0]
+				if p := strings.LastIndex(host, "-"); p > 0 {
+					ns.HostName, ns.HostID = host[:p], host[p+1:]
+				}
+			}
+		}
+		ns = d.instanceStatus(ns, i)
+		ns.ELB = health[id]
+		nodes = append(nodes, ns)
+	}
+
+	// Sort by host name and ID:
+	sort.Slice(nodes, func(a, b int) bool {
+		if nodes[a].HostName != nodes[b].HostName {
+			return nodes[a].HostName < nodes[b].HostName
+		}
+		return nodes[a].HostID < nodes[b].HostID
+	})
+
+	return nodes
+}
+
+//-----------------------------------------------------------------------------
+// func: instanceStatus
+//-----------------------------------------------------------------------------
+
+func (d *Data) instanceStatus(ns nodeStatus, i *ec2.Instance) nodeStatus {
+	ns.InstanceType = aws.StringValue(i.InstanceType)
+	ns.AmiID = aws.StringValue(i.ImageId)
+	ns.State = aws.StringValue(i.State.Name)
+	ns.PrivateIP = aws.StringValue(i.PrivateIpAddress)
+	ns.PublicIP = aws.StringValue(i.PublicIpAddress)
+	return ns
+}
+
+//-----------------------------------------------------------------------------
+// func: rolesFromSecGrps
+//-----------------------------------------------------------------------------
+
+func (d *Data) rolesFromSecGrps(groups []*ec2.GroupIdentifier) string {
+	var roles []string
+	for _, grp := range groups {
+		switch *grp.GroupId {
+		case d.QuorumSecGrp:
+			roles = append(roles, "quorum")
+		case d.MasterSecGrp:
+			roles = append(roles, "master")
+		case d.WorkerSecGrp:
+			roles = append(roles, "worker")
+		case d.BorderSecGrp:
+			roles = append(roles, "border")
+		}
+	}
+	return strings.Join(roles, ",")
+}
+
+//-----------------------------------------------------------------------------
+// func: printNodes
+//-----------------------------------------------------------------------------
+
+func printNodes(w io.Writer, format string, nodes []nodeStatus) error {
+
+	switch format {
+
+	case "json":
+		out, err := json.MarshalIndent(nodes, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+
+	case "yaml":
+		out, err := yaml.Marshal(nodes)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "HOST\tROLES\tTYPE\tINSTANCE\tSTATE\tPRIVATE IP\tPUBLIC IP\tELB")
+		for _, n := range nodes {
+			fmt.Fprintf(tw, "%s-%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				n.HostName, n.HostID, n.Roles, n.InstanceType, n.InstanceID,
+				n.State, n.PrivateIP, n.PublicIP, n.ELB)
+		}
+		return tw.Flush()
+	}
+}
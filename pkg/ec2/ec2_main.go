@@ -8,7 +8,9 @@ import (
 
 	// Stdlib:
 	"encoding/json"
+	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	// Community:
@@ -26,6 +28,9 @@ import (
 // Typedefs:
 //-----------------------------------------------------------------------------
 
+// Tag set on every instance with the ID of its cluster:
+const clusterTag = "KatoClusterID"
+
 // AWS API endpoints:
 type svc struct {
 	ec2 *ec2.EC2
@@ -35,71 +40,171 @@ type svc struct {
 
 // Instance data.
 type Instance struct {
-	AmiID        string `json:"AmiID"`        // deploy | add | run
-	HostName     string `json:"HostName"`     //        | add |
-	HostID       string `json:"HostID"`       //        | add |
-	Roles        string `json:"Roles"`        //        | add |
-	ClusterState string `json:"ClusterState"` //        | add |
-	InstanceType string `json:"InstanceType"` //        | add | run
-	SrcDstCheck  string `json:"SrcDstCheck"`  //        |     | run
-	InstanceID   string `json:"InstanceID"`   //        |     | run
-	SubnetID     string `json:"SubnetID"`     //        |     | run
-	SecGrpIDs    string `json:"SecGrpIDs"`    //        |     | run
-	PublicIP     string `json:"PublicIP"`     //        |     | run
-	PrivateIP    string `json:"PrivateIP"`    //        |     | run
-	IAMRole      string `json:"IAMRole"`      //        |     | run
-	InterfaceID  string `json:"InterfaceID"`  //        |     | run
-	ELBName      string `json:"ELBName"`      //        |     | run
-	TagName      string `json:"TagName"`      //        |     | run
+	AmiID        string   `json:"AmiID"`        // deploy | add | run
+	HostName     string   `json:"HostName"`     //        | add |
+	HostID       string   `json:"HostID"`       //        | add |
+	Roles        string   `json:"Roles"`        //        | add |
+	ClusterState string   `json:"ClusterState"` //        | add |
+	InstanceType string   `json:"InstanceType"` //        | add | run
+	SrcDstCheck  string   `json:"SrcDstCheck"`  //        |     | run
+	InstanceID   string   `json:"InstanceID"`   //        |     | run
+	SubnetID     string   `json:"SubnetID"`     //        |     | run
+	SecGrpIDs    string   `json:"SecGrpIDs"`    //        |     | run
+	PublicIP     string   `json:"PublicIP"`     //        |     | run
+	PrivateIP    string   `json:"PrivateIP"`    //        |     | run
+	IAMRole      string   `json:"IAMRole"`      //        |     | run
+	InterfaceID  string   `json:"InterfaceID"`  //        |     | run
+	ELBName      string   `json:"ELBName"`      //        |     | run
+	TagName      string   `json:"TagName"`      //        |     | run
+	DiskSize     int64    `json:"DiskSize"`     //        | add | run
+	Tags         []string `json:"Tags"`         //        | add | run
+	EtcdCluster  string   `json:"-"`            //        | add |
 }
 
 // State data.
 type State struct {
-	Quadruplets      []string `json:"-"`                // deploy |       | add |
-	StubZones        []string `json:"StubZones"`        // deploy |       | add |
-	QuorumCount      int      `json:"QuorumCount"`      // deploy |       | add |
-	MasterCount      int      `json:"MasterCount"`      // deploy |       | add |
-	CoreOSChannel    string   `json:"CoreOSChannel"`    // deploy |       | add |
-	EtcdToken        string   `json:"EtcdToken"`        // deploy |       | add |
-	DNSProvider      string   `json:"DNSProvider"`      // deploy |       | add |
-	DNSApiKey        string   `json:"DNSApiKey"`        // deploy |       | add |
-	SlackWebhook     string   `json:"SlackWebhook:"`    // deploy |       | add |
-	SMTPURL          string   `json:"SMTPURL:"`         // deploy |       | add |
-	AdminEmail       string   `json:"AdminEmail:"`      // deploy |       | add |
-	CaCertPath       string   `json:"CaCertPath"`       // deploy |       | add |
-	CalicoIPPool     string   `json:"CalicoIPPool"`     // deploy |       |     |
-	Domain           string   `json:"Domain"`           // deploy | setup | add |
-	ClusterID        string   `json:"ClusterID"`        // deploy | setup | add |
-	Region           string   `json:"Region"`           // deploy | setup | add | run
-	Zone             string   `json:"Zone"`             // deploy | setup | add | run
-	VpcCidrBlock     string   `json:"VpcCidrBlock"`     // deploy | setup |     |
-	IntSubnetCidr    string   `json:"IntSubnetCidr"`    // deploy | setup |     |
-	ExtSubnetCidr    string   `json:"ExtSubnetCidr"`    // deploy | setup |     |
-	AllocationID     string   `json:"AllocationID"`     //        | setup |     | run
-	VpcID            string   `json:"VpcID"`            //        | setup |     |
-	MainRouteTableID string   `json:"MainRouteTableID"` //        | setup |     |
-	InetGatewayID    string   `json:"InetGatewayID"`    //        | setup |     |
-	NatGatewayID     string   `json:"NatGatewayID"`     //        | setup |     |
-	RouteTableID     string   `json:"RouteTableID"`     //        | setup |     |
-	KatoRoleID       string   `json:"KatoRoleID"`       //        | setup |     |
-	RexrayPolicy     string   `json:"RexrayPolicy"`     //        | setup |     |
-	QuorumSecGrp     string   `json:"QuorumSecGrp"`     //        | setup |     |
-	MasterSecGrp     string   `json:"MasterSecGrp"`     //        | setup |     |
-	WorkerSecGrp     string   `json:"WorkerSecGrp"`     //        | setup |     |
-	BorderSecGrp     string   `json:"BorderSecGrp"`     //        | setup |     |
-	ELBSecGrp        string   `json:"ELBSecGrp"`        //        | setup |     |
-	IntSubnetID      string   `json:"IntSubnetID"`      //        | setup |     |
-	ExtSubnetID      string   `json:"ExtSubnetID"`      //        | setup |     |
-	DNSName          string   `json:"DNSName"`          //        | setup |     |
-	KeyPair          string   `json:"KeyPair"`          //        |       | add | run
+	Quadruplets      []string    `json:"-"`                   // deploy |       | add |
+	NodePools        []NodePool  `json:"-"`                   // deploy |       |     |
+	StubZones        []string    `json:"StubZones"`           // deploy |       | add |
+	QuorumCount      int         `json:"QuorumCount"`         // deploy |       | add |
+	MasterCount      int         `json:"MasterCount"`         // deploy |       | add |
+	CoreOSChannel    string      `json:"CoreOSChannel"`       // deploy |       | add |
+	CoreOSVersion    string      `json:"CoreOSVersion"`       // deploy |       | add |
+	EtcdToken        string      `json:"EtcdToken"`           // deploy |       | add |
+	EtcdDiscovery    string      `json:"EtcdDiscovery"`       // deploy |       | add |
+	QuorumIPs        []string    `json:"QuorumIPs,omitempty"` // deploy |       | add |
+	EtcdTLS          bool        `json:"EtcdTLS"`             // deploy |       | add |
+	DNSProvider      string      `json:"DNSProvider"`         // deploy |       | add |
+	PublicIntZone    bool        `json:"PublicIntZone"`       // deploy |       |     |
+	AdminEmail       string      `json:"AdminEmail:"`         // deploy |       | add |
+	CaCertPath       string      `json:"CaCertPath"`          // deploy |       | add |
+	PKI              bool        `json:"PKI"`                 // deploy |       | add |
+	KmsKeyID         string      `json:"KmsKeyID,omitempty"`  // deploy | setup | add |
+	CalicoIPPool     string      `json:"CalicoIPPool"`        // deploy |       |     |
+	Domain           string      `json:"Domain"`              // deploy | setup | add |
+	ClusterID        string      `json:"ClusterID"`           // deploy | setup | add |
+	Region           string      `json:"Region"`              // deploy | setup | add | run
+	Zone             string      `json:"Zone"`                //        |       | add | run
+	Zones            []AvailZone `json:"Zones"`               // deploy | setup | add |
+	VpcCidrBlock     string      `json:"VpcCidrBlock"`        // deploy | setup |     |
+	AllocationID     string      `json:"AllocationID"`        //        |       |     | run
+	VpcID            string      `json:"VpcID"`               //        | setup |     |
+	MainRouteTableID string      `json:"MainRouteTableID"`    //        | setup |     |
+	InetGatewayID    string      `json:"InetGatewayID"`       //        | setup |     |
+	RouteTableID     string      `json:"RouteTableID"`        //        | setup |     |
+	KatoRoleID       string      `json:"KatoRoleID"`          //        | setup |     |
+	RexrayPolicy     string      `json:"RexrayPolicy"`        //        | setup |     |
+	QuorumSecGrp     string      `json:"QuorumSecGrp"`        //        | setup |     |
+	MasterSecGrp     string      `json:"MasterSecGrp"`        //        | setup |     |
+	WorkerSecGrp     string      `json:"WorkerSecGrp"`        //        | setup |     |
+	BorderSecGrp     string      `json:"BorderSecGrp"`        //        | setup |     |
+	ELBSecGrp        string      `json:"ELBSecGrp"`           //        | setup |     |
+	DNSName          string      `json:"DNSName"`             //        | setup |     |
+	ELBZoneID        string      `json:"ELBZoneID"`           //        | setup |     |
+	KeyPair          string      `json:"KeyPair"`             //        |       | add | run
+	Nodes            []Node      `json:"Nodes"`               //        |       | add |
+	Upgrading        *Upgrade    `json:"Upgrading,omitempty"` // upgrade
+}
+
+// Secrets data, kept apart from the state and encrypted at rest.
+type Secrets struct {
+	DNSApiKey    string `json:"DNSApiKey,omitempty"`    // deploy | add
+	SlackWebhook string `json:"SlackWebhook,omitempty"` // deploy | add
+	SMTPURL      string `json:"SMTPURL,omitempty"`      // deploy | add
+}
+
+// Secrets as older state files kept them, in clear.
+type legacySecrets struct {
+	DNSApiKey    string `json:"DNSApiKey"`
+	SlackWebhook string `json:"SlackWebhook:"`
+	SMTPURL      string `json:"SMTPURL:"`
+}
+
+// Rolling upgrade in progress.
+type Upgrade struct {
+	AmiID  string `json:"AmiID"`
+	Paused bool   `json:"Paused"`
+}
+
+// Node pool data, a quadruplet plus the per-pool options of a cluster spec.
+type NodePool struct {
+	Name         string            `json:"name" yaml:"name"`
+	Count        int               `json:"count" yaml:"count"`
+	InstanceType string            `json:"instanceType" yaml:"instanceType"`
+	Roles        []string          `json:"roles" yaml:"roles"`
+	DiskSize     int64             `json:"diskSize,omitempty" yaml:"diskSize,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// Availability zone network data. The internal subnet of the first zone is
+// routed by the VPC main route table, the others get their own.
+type AvailZone struct {
+	Name            string `json:"Name"`
+	IntSubnetCidr   string `json:"IntSubnetCidr"`
+	ExtSubnetCidr   string `json:"ExtSubnetCidr"`
+	IntSubnetID     string `json:"IntSubnetID"`
+	ExtSubnetID     string `json:"ExtSubnetID"`
+	IntRouteTableID string `json:"IntRouteTableID"`
+	AllocationID    string `json:"AllocationID"`
+	NatGatewayID    string `json:"NatGatewayID"`
+}
+
+// Node inventory data.
+type Node struct {
+	HostName     string `json:"HostName"`
+	HostID       string `json:"HostID"`
+	Roles        string `json:"Roles"`
+	Zone         string `json:"Zone"`
+	InstanceType string `json:"InstanceType"`
+	InstanceID   string `json:"InstanceID"`
+	InterfaceID  string `json:"InterfaceID"`
+	PrivateIP    string `json:"PrivateIP"`
+	PublicIP     string `json:"PublicIP"`
+}
+
+// Drift between the requested and the actual configuration.
+type drift struct {
+	Resource string `json:"Resource"`
+	ID       string `json:"ID"`
+	Field    string `json:"Field"`
+	Expected string `json:"Expected"`
+	Actual   string `json:"Actual"`
 }
 
 // Data struct for EC2 endpoints, instance and state data.
 type Data struct {
-	command string
+	command        string
+	mu             sync.Mutex
+	drifts         []drift
+	spec           *spec
+	locked         bool
+	leaving        *sync.Map
+	DryRun         bool   // destroy | apply
+	DeleteIAM      bool   // destroy
+	Output         string // list
+	MaxUnavailable int    // upgrade
+	EtcdCaCert     string // deploy
+	EtcdCaKey      string // deploy
 	svc
 	Instance
 	State
+	Secrets
+}
+
+//-----------------------------------------------------------------------------
+// func: requireKMS
+//-----------------------------------------------------------------------------
+
+// Secrets and private keys only reach the nodes sealed with KMS, never in
+// clear in the user data readable from the metadata service.
+func (d *Data) requireKMS() error {
+	if d.KmsKeyID != "" {
+		return nil
+	}
+	if d.DNSApiKey != "" || d.SMTPURL != "" || d.SlackWebhook != "" || d.EtcdTLS || d.PKI {
+		return errors.New("Ops! --kms-key-id is required with secrets, --etcd-tls or --pki")
+	}
+	return nil
 }
 
 //-----------------------------------------------------------------------------
@@ -115,8 +220,14 @@ func (d *Data) loadState() error {
 	}
 
 	// Decode the loaded JSON data:
-	dat := State{}
-	if err := json.Unmarshal(raw, &dat); err != nil {
+	dat, err := decodeState(raw)
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Merge the zones by name, mergo does not merge slices:
+	if err := mergeZones(&d.Zones, dat.Zones); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Error(err)
 		return err
 	}
@@ -127,9 +238,257 @@ func (d *Data) loadState() error {
 		return err
 	}
 
+	// Merge the stored secrets, flags take precedence:
+	if err := d.loadSecrets(raw); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: loadSecrets
+//-----------------------------------------------------------------------------
+
+// Read the encrypted secrets. Secrets found in clear in older state files
+// are moved to the secrets file and dropped from the state file.
+func (d *Data) loadSecrets(raw []byte) error {
+
+	// Stored secrets:
+	stored := Secrets{}
+	if err := kato.ReadSecrets(d.ClusterID, &stored); err != nil {
+		return err
+	}
+
+	// Legacy secrets:
+	legacy := legacySecrets{}
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return err
+	}
+	if legacy != (legacySecrets{}) {
+		if err := mergo.Merge(&stored, Secrets(legacy)); err != nil {
+			return err
+		}
+		if err := kato.DumpSecrets(stored, d.ClusterID); err != nil {
+			return err
+		}
+		if err := d.updateState(func(*State) {}); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+			Info("Secrets moved out of the state file")
+	}
+
+	return mergo.Merge(&d.Secrets, stored)
+}
+
+//-----------------------------------------------------------------------------
+// func: decodeState
+//-----------------------------------------------------------------------------
+
+// Decode a state file. Single-zone state files kept the zone network data at
+// the top level, move it to the zones list.
+func decodeState(raw []byte) (State, error) {
+
+	// Decode the state:
+	dat := State{}
+	if err := json.Unmarshal(raw, &dat); err != nil {
+		return dat, err
+	}
+
+	// Return if there is nothing to migrate:
+	if len(dat.Zones) > 0 || dat.Zone == "" {
+		return dat, nil
+	}
+
+	// Decode the top level zone data:
+	zone := AvailZone{}
+	if err := json.Unmarshal(raw, &zone); err != nil {
+		return dat, err
+	}
+
+	// Migrate it:
+	zone.Name = dat.Zone
+	dat.Zones = []AvailZone{zone}
+	dat.AllocationID = ""
+
+	return dat, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: mergeZones
+//-----------------------------------------------------------------------------
+
+// Fill the requested zones with the data recorded in the state file. Recorded
+// zones which are no longer requested are kept so their resources are known.
+func mergeZones(dst *[]AvailZone, src []AvailZone) error {
+
+	for _, z := range src {
+		found := false
+		for i := range *dst {
+			if (*dst)[i].Name == z.Name {
+				if err := mergo.Merge(&(*dst)[i], z); err != nil {
+					return err
+				}
+				found = true
+			}
+		}
+		if !found {
+			*dst = append(*dst, z)
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: newAvailZones
+//-----------------------------------------------------------------------------
+
+// Pair every zone with its subnet CIDRs, given in the same order.
+func newAvailZones(names, intCidrs, extCidrs []string) ([]AvailZone, error) {
+
+	// Check the CIDR counts:
+	if len(extCidrs) != len(names) {
+		return nil, errors.New("one --external-subnet-cidr per --zone is required")
+	}
+	if len(intCidrs) != 0 && len(intCidrs) != len(names) {
+		return nil, errors.New("one --internal-subnet-cidr per --zone is required")
+	}
+
+	// Forge the zones:
+	zones := []AvailZone{}
+	for i, name := range names {
+		for _, z := range zones {
+			if z.Name == name {
+				return nil, errors.New("zone " + name + " given more than once")
+			}
+		}
+		z := AvailZone{Name: name, ExtSubnetCidr: extCidrs[i]}
+		if len(intCidrs) > 0 {
+			z.IntSubnetCidr = intCidrs[i]
+		}
+		zones = append(zones, z)
+	}
+
+	return zones, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: availZone
+//-----------------------------------------------------------------------------
+
+// Return the named zone, the first one if no name is given.
+func (d *Data) availZone(name string) (*AvailZone, error) {
+
+	if len(d.Zones) == 0 {
+		return nil, errors.New("Ops! no zones found in the state file")
+	}
+
+	if name == "" {
+		return &d.Zones[0], nil
+	}
+
+	for i := range d.Zones {
+		if d.Zones[i].Name == name {
+			return &d.Zones[i], nil
+		}
+	}
+
+	return nil, errors.New("Ops! zone " + name + " is not part of the cluster")
+}
+
+//-----------------------------------------------------------------------------
+// func: updateState
+//-----------------------------------------------------------------------------
+
+// Read-modify-write the state file while holding its lock, so that parallel
+// katoctl processes don't clobber each other.
+func (d *Data) updateState(fn func(*State)) error {
+
+	// Held for the whole command:
+	if d.locked {
+		return d.rewriteState(fn)
+	}
+
+	// Acquire the state lock:
+	lock, err := kato.LockState(d.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	// Rewrite the state:
+	if err := d.rewriteState(fn); err != nil {
+		_ = kato.UnlockState(lock)
+		return err
+	}
+
+	return kato.UnlockState(lock)
+}
+
+//-----------------------------------------------------------------------------
+// func: rewriteState
+//-----------------------------------------------------------------------------
+
+// Read-modify-write the state file, called with its lock held.
+func (d *Data) rewriteState(fn func(*State)) error {
+
+	// Read the latest state from file:
+	raw, err := kato.ReadState(d.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	// Decode the loaded JSON data:
+	dat, err := decodeState(raw)
+	if err != nil {
+		return err
+	}
+
+	// Modify and dump the state:
+	fn(&dat)
+	if err := kato.DumpState(dat, d.ClusterID); err != nil {
+		return err
+	}
+
+	// Keep the in-memory inventory in sync:
+	d.Nodes = dat.Nodes
+
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: saveNode
+//-----------------------------------------------------------------------------
+
+func (d *Data) saveNode(node Node) error {
+	return d.updateState(func(s *State) {
+		for i, n := range s.Nodes {
+			if n.HostName == node.HostName && n.HostID == node.HostID {
+				s.Nodes[i] = node
+				return
+			}
+		}
+		s.Nodes = append(s.Nodes, node)
+	})
+}
+
+//-----------------------------------------------------------------------------
+// func: forgetNode
+//-----------------------------------------------------------------------------
+
+func (d *Data) forgetNode(hostName, hostID string) error {
+	return d.updateState(func(s *State) {
+		for i, n := range s.Nodes {
+			if n.HostName == hostName && n.HostID == hostID {
+				s.Nodes = append(s.Nodes[:i], s.Nodes[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
 //-----------------------------------------------------------------------------
 // func: tag
 //-----------------------------------------------------------------------------
@@ -165,3 +524,16 @@ func (d *Data) tag(resource, key, value string) error {
 
 	return nil
 }
+
+//-----------------------------------------------------------------------------
+// func: hasTag
+//-----------------------------------------------------------------------------
+
+func hasTag(tags []*ec2.Tag, key, value string) bool {
+	for _, t := range tags {
+		if aws.StringValue(t.Key) == key && aws.StringValue(t.Value) == value {
+			return true
+		}
+	}
+	return false
+}
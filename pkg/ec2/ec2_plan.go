@@ -0,0 +1,279 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Deployment plan, secrets are left out and taken from flags on apply.
+type plan struct {
+	Quadruplets []string            `json:"Quadruplets"`
+	NodePools   []NodePool          `json:"NodePools,omitempty"`
+	AmiID       string              `json:"AmiID"`
+	State       State               `json:"State"`
+	Network     []string            `json:"Network"`
+	Firewall    map[string][]string `json:"Firewall"`
+	Listeners   []string            `json:"Listeners"`
+	Nodes       []planNode          `json:"Nodes"`
+	Records     []string            `json:"Records"`
+}
+
+// Planned node data.
+type planNode struct {
+	HostName     string   `json:"HostName"`
+	Roles        string   `json:"Roles"`
+	Zone         string   `json:"Zone"`
+	InstanceType string   `json:"InstanceType"`
+	AmiID        string   `json:"AmiID"`
+	PrivateIP    string   `json:"PrivateIP"`
+	DiskSize     int64    `json:"DiskSize,omitempty"`
+	Tags         []string `json:"Tags,omitempty"`
+}
+
+//-----------------------------------------------------------------------------
+// func: Plan
+//-----------------------------------------------------------------------------
+
+// Plan prints what Deploy would create without any mutating AWS call.
+func (d *Data) Plan() {
+
+	// Set current command:
+	d.command = "plan"
+
+	// Load state from state file (if any):
+	if err := d.loadState(); err != nil {
+		if !os.IsNotExist(err) {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
+	// Retrieve the CoreOS AMI ID:
+	if d.AmiID == "" {
+		var err error
+		if d.AmiID, err = d.retrieveCoreOSAmiID(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
+	// Forge the plan:
+	p := d.forgePlan()
+
+	// Print the plan:
+	out, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+	fmt.Println(string(out))
+}
+
+//-----------------------------------------------------------------------------
+// func: Apply
+//-----------------------------------------------------------------------------
+
+// Apply deploys exactly what was planned in the given plan file.
+func (d *Data) Apply(planFile string) {
+
+	// Set current command:
+	d.command = "apply"
+
+	// Read the plan:
+	raw, err := ioutil.ReadFile(planFile)
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Decode the plan:
+	p := plan{}
+	if err := json.Unmarshal(raw, &p); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Secrets come from flags, everything else from the plan:
+	d.State = p.State
+	d.Quadruplets = p.Quadruplets
+	d.NodePools = p.NodePools
+	d.AmiID = p.AmiID
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": planFile}).
+		Info("Applying plan for cluster " + d.ClusterID)
+
+	d.Deploy()
+}
+
+//-----------------------------------------------------------------------------
+// func: forgePlan
+//-----------------------------------------------------------------------------
+
+func (d *Data) forgePlan() *plan {
+
+	p := &plan{
+		Quadruplets: d.Quadruplets,
+		NodePools:   d.NodePools,
+		AmiID:       d.AmiID,
+		State:       d.State,
+		Firewall:    map[string][]string{},
+	}
+
+	// Network:
+	p.Network = []string{planResource("vpc", d.VpcID, d.VpcCidrBlock)}
+	for _, z := range d.Zones {
+		p.Network = append(p.Network,
+			planResource("external subnet", z.ExtSubnetID, z.ExtSubnetCidr+" "+d.Region+z.Name))
+		if z.IntSubnetCidr != "" {
+			p.Network = append(p.Network,
+				planResource("internal subnet", z.IntSubnetID, z.IntSubnetCidr+" "+d.Region+z.Name),
+				planResource("nat gateway", z.NatGatewayID, "0.0.0.0/0 from internal subnet "+d.Region+z.Name))
+		}
+	}
+	p.Network = append(p.Network,
+		planResource("internet gateway", d.InetGatewayID, "0.0.0.0/0 from external subnet"),
+		planResource("elb", d.DNSName, d.ClusterID))
+
+	// Placeholders for the security groups that don't exist yet:
+	groups := map[string]*string{"quorum": &d.QuorumSecGrp, "master": &d.MasterSecGrp,
+		"worker": &d.WorkerSecGrp, "border": &d.BorderSecGrp, "elb": &d.ELBSecGrp}
+	names := map[string]string{}
+	for name, id := range groups {
+		if *id == "" {
+			*id = "<" + name + ">"
+			defer func(id *string) { *id = "" }(id)
+		}
+		names[*id] = name
+	}
+
+	// Firewall:
+	for name, rules := range map[string][]*ec2.IpPermission{
+		"quorum": d.firewallQuorumRules(),
+		"master": d.firewallMasterRules(),
+		"worker": d.firewallWorkerRules(),
+		"border": d.firewallBorderRules(),
+		"elb":    d.firewallELBRules(),
+	} {
+		for _, r := range rules {
+			p.Firewall[name] = append(p.Firewall[name], planRule(r, names))
+		}
+	}
+
+	// ELB listeners:
+	for _, l := range elbListeners() {
+		p.Listeners = append(p.Listeners, fmt.Sprintf("%s:%d -> %s:%d",
+			*l.Protocol, *l.LoadBalancerPort, *l.InstanceProtocol, *l.InstancePort))
+	}
+
+	// DNS zones:
+	if d.DNSProvider != "none" {
+		for _, z := range []string{d.Domain, "int." + d.Domain, "ext." + d.Domain} {
+			if z == "int."+d.Domain && d.DNSProvider == "r53" && !d.PublicIntZone {
+				z += " PRIVATE"
+			}
+			p.Records = append(p.Records, z+" ZONE")
+		}
+	}
+
+	// Route 53 aliases ext.<domain> to the ELB:
+	if d.DNSProvider == "r53" {
+		p.Records = append(p.Records, "ext."+d.Domain+" A ALIAS <elb>")
+	}
+
+	// Nodes and their DNS records:
+	for _, pool := range d.nodePools() {
+		for i := 1; i <= pool.Count; i++ {
+
+			z := d.nodeZone(i)
+			n := planNode{
+				HostName:     pool.Name + "-" + strconv.Itoa(i) + "." + d.Domain,
+				Roles:        strings.Join(pool.Roles, ","),
+				Zone:         z.Name,
+				InstanceType: pool.InstanceType,
+				AmiID:        d.AmiID,
+				PrivateIP:    "<dhcp>",
+				DiskSize:     pool.DiskSize,
+				Tags:         pool.tags(),
+			}
+			if ip := d.fixedIP(&z, n.Roles, i); ip != "" {
+				n.PrivateIP = ip
+			}
+			p.Nodes = append(p.Nodes, n)
+
+			if d.DNSProvider == "none" {
+				continue
+			}
+
+			for _, role := range pool.Roles {
+				name := role + "-" + strconv.Itoa(i)
+				p.Records = append(p.Records,
+					name+".int."+d.Domain+" A "+n.PrivateIP,
+					name+"."+d.Domain+" CNAME "+name+".int."+d.Domain)
+				if d.DNSProvider != "r53" {
+					p.Records = append(p.Records, name+".ext."+d.Domain+" A <public-ip>")
+				}
+			}
+		}
+	}
+
+	return p
+}
+
+//-----------------------------------------------------------------------------
+// func: planResource
+//-----------------------------------------------------------------------------
+
+func planResource(kind, id, desc string) string {
+	if id != "" {
+		return "reuse " + kind + " " + id + " (" + desc + ")"
+	}
+	return "create " + kind + " (" + desc + ")"
+}
+
+//-----------------------------------------------------------------------------
+// func: planRule
+//-----------------------------------------------------------------------------
+
+func planRule(r *ec2.IpPermission, names map[string]string) string {
+
+	// Protocol and ports:
+	rule := "all"
+	if proto := aws.StringValue(r.IpProtocol); proto != "-1" {
+		rule = proto + "/" + strconv.FormatInt(aws.Int64Value(r.FromPort), 10)
+		if aws.Int64Value(r.ToPort) != aws.Int64Value(r.FromPort) {
+			rule += "-" + strconv.FormatInt(aws.Int64Value(r.ToPort), 10)
+		}
+	}
+
+	// Sources:
+	var from []string
+	for _, ip := range r.IpRanges {
+		from = append(from, aws.StringValue(ip.CidrIp))
+	}
+	for _, g := range r.UserIdGroupPairs {
+		if name, ok := names[aws.StringValue(g.GroupId)]; ok {
+			from = append(from, name)
+		} else {
+			from = append(from, aws.StringValue(g.GroupId))
+		}
+	}
+	if len(from) == 0 {
+		return rule
+	}
+
+	return rule + " from " + strings.Join(from, ",")
+}
@@ -0,0 +1,304 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"errors"
+	"strconv"
+	"strings"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/katosys/kato/pkg/dns"
+	"github.com/katosys/kato/pkg/pki"
+)
+
+//-----------------------------------------------------------------------------
+// func: Remove
+//-----------------------------------------------------------------------------
+
+// Remove an instance from the cluster.
+func (d *Data) Remove() error {
+
+	// Set current command:
+	d.command = "remove"
+
+	// Load state from state file:
+	if err := d.loadState(); err != nil {
+		return err
+	}
+
+	// Connect and authenticate to the API endpoints:
+	d.setupAPIEndpoints()
+
+	// Locate the instance:
+	if err := d.retrieveInstance(); err != nil {
+		return err
+	}
+
+	// Refuse to break the quorum:
+	if strings.Contains(d.Roles, "quorum") {
+		if err := d.checkQuorum(); err != nil {
+			return err
+		}
+	}
+
+	// Deregister from ELB:
+	if strings.Contains(d.Roles, "worker") {
+		d.ELBName = d.ClusterID
+		if err := d.deregisterFromELB(); err != nil {
+			return err
+		}
+	}
+
+	// Terminate the instance:
+	if err := d.terminateInstance(); err != nil {
+		return err
+	}
+
+	// Forget the node in the state file:
+	if err := d.forgetNode(d.HostName, d.HostID); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Warning(err)
+	}
+
+	// Delete DNS records:
+	if err := d.deleteDNSRecords(d.Roles); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Warning(err)
+	}
+
+	// Revoke the node certificate:
+	if d.PKI {
+		if err := d.revokeNodeCert(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Warning(err)
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: revokeNodeCert
+//-----------------------------------------------------------------------------
+
+func (d *Data) revokeNodeCert() error {
+	s, err := pki.OpenStore(d.ClusterID)
+	if err != nil {
+		return err
+	}
+	if err := s.Revoke(d.HostName + "-" + d.HostID); err != nil {
+		return err
+	}
+	return d.publishCRL(s)
+}
+
+//-----------------------------------------------------------------------------
+// func: retrieveInstance
+//-----------------------------------------------------------------------------
+
+func (d *Data) retrieveInstance() error {
+
+	// Forge the description request:
+	d.TagName = d.HostName + "-" + d.HostID + "." + d.Domain
+	params := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
+			{
+				Name:   aws.String("tag:Name"),
+				Values: []*string{aws.String(d.TagName)},
+			},
+			{
+				Name: aws.String("instance-state-name"),
+				Values: []*string{aws.String("pending"), aws.String("running"),
+					aws.String("stopping"), aws.String("stopped")},
+			},
+		},
+	}
+
+	// Send the description request:
+	resp, err := d.ec2.DescribeInstances(params)
+	if err != nil {
+		return err
+	}
+
+	// Return if not found:
+	if len(resp.Reservations) == 0 || len(resp.Reservations[0].Instances) == 0 {
+		return errors.New("Ops! instance " + d.TagName + " not found")
+	}
+
+	// Store the instance data:
+	i := resp.Reservations[0].Instances[0]
+	d.InstanceID = *i.InstanceId
+	d.PrivateIP = aws.StringValue(i.PrivateIpAddress)
+	d.PublicIP = aws.StringValue(i.PublicIpAddress)
+
+	// Derive the roles from the security groups:
+	d.Roles = d.rolesFromSecGrps(i.SecurityGroups)
+
+	// Log this action:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.InstanceID}).
+		Info("Instance " + d.TagName + " located with roles " + d.Roles)
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: checkQuorum
+//-----------------------------------------------------------------------------
+
+func (d *Data) checkQuorum() error {
+
+	// Forge the description request:
+	params := &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance.group-id"),
+				Values: []*string{aws.String(d.QuorumSecGrp)},
+			},
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: []*string{aws.String("pending"), aws.String("running")},
+			},
+		},
+	}
+
+	// Count the live quorum members:
+	live := 0
+	if err := d.ec2.DescribeInstancesPages(params,
+		func(page *ec2.DescribeInstancesOutput, last bool) bool {
+			for _, r := range page.Reservations {
+				live += len(r.Instances)
+			}
+			return true
+		}); err != nil {
+		return err
+	}
+
+	// Refuse if the survivors can't keep a majority:
+	if !keepsMajority(d.QuorumCount, live) {
+		return errors.New("Ops! removing " + d.TagName + " leaves " +
+			strconv.Itoa(live-1) + " of " + strconv.Itoa(d.QuorumCount) +
+			" quorum nodes, no etcd/ZooKeeper majority")
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: keepsMajority
+//-----------------------------------------------------------------------------
+
+// True if an ensemble of size members keeps a majority once one of the live
+// members is removed.
+func keepsMajority(size, live int) bool {
+	return live-1 >= size/2+1
+}
+
+//-----------------------------------------------------------------------------
+// func: deregisterFromELB
+//-----------------------------------------------------------------------------
+
+func (d *Data) deregisterFromELB() error {
+
+	// Forge the deregister request:
+	params := &elb.DeregisterInstancesFromLoadBalancerInput{
+		Instances: []*elb.Instance{
+			{
+				InstanceId: aws.String(d.InstanceID),
+			},
+		},
+		LoadBalancerName: aws.String(d.ELBName),
+	}
+
+	// Send the deregister request:
+	if _, err := d.elb.DeregisterInstancesFromLoadBalancer(params); err != nil {
+		return err
+	}
+
+	// Wait for the connections to drain:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.InstanceID}).
+		Info("Waiting until instance is drained from ELB")
+	if err := d.elb.WaitUntilInstanceDeregistered(&elb.DescribeInstanceHealthInput{
+		Instances:        params.Instances,
+		LoadBalancerName: params.LoadBalancerName,
+	}); err != nil {
+		return err
+	}
+
+	// Log this action:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ELBName}).
+		Info("Instance deregistered from ELB")
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: terminateInstance
+//-----------------------------------------------------------------------------
+
+func (d *Data) terminateInstance() error {
+
+	// Send the termination request:
+	ids := []*string{aws.String(d.InstanceID)}
+	if _, err := d.ec2.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: ids,
+	}); err != nil {
+		return err
+	}
+
+	// Wait until the instance is terminated:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.InstanceID}).
+		Info("Waiting until instance is terminated")
+	if err := d.ec2.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{
+		InstanceIds: ids,
+	}); err != nil {
+		return err
+	}
+
+	// Log this action:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.InstanceID}).
+		Info("Instance terminated")
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: deleteDNSRecords
+//-----------------------------------------------------------------------------
+
+func (d *Data) deleteDNSRecords(roles string) error {
+
+	// Get the DNS provider:
+	p, err := dns.New(d.DNSProvider, d.DNSApiKey)
+	if err != nil {
+		return err
+	}
+
+	// For every role in this instance:
+	for _, role := range strings.Split(roles, ",") {
+
+		name := role + "-" + d.HostID
+
+		// Records published by publishDNSRecords:
+		for zone, record := range map[string]dns.Record{
+			"int." + d.Domain: {Name: name, Type: "A", Data: []string{d.PrivateIP}},
+			"ext." + d.Domain: {Name: name, Type: "A", Data: []string{d.PublicIP}},
+			d.Domain:          {Name: name, Type: "CNAME", Data: []string{name + ".int." + d.Domain}},
+		} {
+			if err := p.DeleteRecords(zone, []dns.Record{record}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
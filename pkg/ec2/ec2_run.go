@@ -9,6 +9,7 @@ import (
 	// Stdlib:
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -84,6 +85,16 @@ func (d *Data) runInstance(udata []byte) error {
 	// Variables:
 	var resp *ec2.Reservation
 	var err error
+	var tags [][]string
+
+	// Check the extra tags before launching:
+	for _, tag := range d.Tags {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return errors.New("Invalid tag " + tag + ", expected <key>=<value>")
+		}
+		tags = append(tags, kv)
+	}
 
 	// Forge the instance request:
 	params := &ec2.RunInstancesInput{
@@ -102,6 +113,20 @@ func (d *Data) runInstance(udata []byte) error {
 		},
 	}
 
+	// Resize the CoreOS root volume:
+	if d.DiskSize > 0 {
+		params.BlockDeviceMappings = []*ec2.BlockDeviceMapping{
+			{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs: &ec2.EbsBlockDevice{
+					VolumeSize:          aws.Int64(d.DiskSize),
+					VolumeType:          aws.String("gp2"),
+					DeleteOnTermination: aws.Bool(true),
+				},
+			},
+		}
+	}
+
 	// Send the instance request:
 	for i := 0; i < 5; i++ {
 		resp, err = d.ec2.RunInstances(params)
@@ -130,6 +155,13 @@ func (d *Data) runInstance(udata []byte) error {
 		return err
 	}
 
+	// Extra tags:
+	for _, kv := range tags {
+		if err := d.tag(d.InstanceID, kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+
 	// Pretty-print to stderr:
 	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.TagName}).
 		Info("New EC2 instance tagged")
@@ -212,9 +244,11 @@ func (d *Data) modifyInstanceAttribute() error {
 func (d *Data) setupElasticIP() error {
 
 	// Allocate an elastic IP address:
-	if err := d.allocateElasticIP(); err != nil {
+	id, err := d.allocateAddress()
+	if err != nil {
 		return err
 	}
+	d.AllocationID = id
 
 	// Associate the elastic IP:
 	if err := d.associateElasticIP(); err != nil {
@@ -292,7 +326,10 @@ func (d *Data) registerWithELB() error {
 func (d *Data) stdoutIPs() error {
 
 	// Map to store the output:
-	m := make(map[string]string)
+	m := map[string]string{
+		"instanceID":  d.InstanceID,
+		"interfaceID": d.InterfaceID,
+	}
 
 	// Forge the describe request:
 	params := &ec2.DescribeNetworkInterfacesInput{
@@ -7,6 +7,8 @@ package ec2
 import (
 
 	// Stdlib:
+	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 	"sync"
@@ -46,6 +48,11 @@ func (d *Data) Setup() {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
+	// Private DNS zones need VPC DNS hostnames:
+	if err := d.enableDNSHostnames(); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
 	// Setup a wait group:
 	var wg sync.WaitGroup
 
@@ -65,6 +72,44 @@ func (d *Data) Setup() {
 	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
+
+	// Print the drift report (if any):
+	if len(d.drifts) > 0 {
+		out, err := json.MarshalIndent(d.drifts, "", "  ")
+		if err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+		fmt.Println(string(out))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: checkDrift
+//-----------------------------------------------------------------------------
+
+// Record a difference between the requested and the actual configuration.
+func (d *Data) checkDrift(resource, id, field, expected, actual string) {
+
+	// Return if there is nothing to compare or no difference:
+	if expected == "" || expected == actual {
+		return
+	}
+
+	// Store the drift:
+	d.mu.Lock()
+	d.drifts = append(d.drifts, drift{
+		Resource: resource,
+		ID:       id,
+		Field:    field,
+		Expected: expected,
+		Actual:   actual,
+	})
+	d.mu.Unlock()
+
+	// Log the drift:
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": id,
+		"field": field, "expected": expected, "actual": actual}).
+		Warning("Drift detected on " + resource)
 }
 
 //-----------------------------------------------------------------------------
@@ -89,10 +134,55 @@ func (d *Data) setupAPIEndpoints() {
 
 func (d *Data) createVPC() error {
 
-	// Return if already defined:
+	// Verify the defined VPC:
 	if d.VpcID != "" {
+
+		// Send the description request:
+		resp, err := d.ec2.DescribeVpcs(&ec2.DescribeVpcsInput{
+			VpcIds: []*string{aws.String(d.VpcID)},
+		})
+		if err != nil && !isAWSError(err, "NotFound") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+
+		// Use it if it still exists:
+		if err == nil && len(resp.Vpcs) > 0 {
+			d.checkDrift("vpc", d.VpcID, "CidrBlock",
+				d.VpcCidrBlock, *resp.Vpcs[0].CidrBlock)
+			log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.VpcID}).
+				Info("Using defined VPC")
+			return nil
+		}
+
+		// Forget it otherwise:
+		d.checkDrift("vpc", d.VpcID, "State", "available", "missing")
+		d.VpcID = ""
+	}
+
+	// Look for an existing VPC:
+	resp, err := d.ec2.DescribeVpcs(&ec2.DescribeVpcsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("tag:Name"),
+				Values: []*string{aws.String(d.Domain)},
+			},
+			{
+				Name:   aws.String("cidr"),
+				Values: []*string{aws.String(d.VpcCidrBlock)},
+			},
+		},
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Use it if found:
+	if len(resp.Vpcs) > 0 {
+		d.VpcID = *resp.Vpcs[0].VpcId
 		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.VpcID}).
-			Info("Using defined VPC")
+			Info("Using existing VPC")
 		return nil
 	}
 
@@ -103,14 +193,14 @@ func (d *Data) createVPC() error {
 	}
 
 	// Send the VPC request:
-	resp, err := d.ec2.CreateVpc(params)
+	vpc, err := d.ec2.CreateVpc(params)
 	if err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Error(err)
 		return err
 	}
 
 	// Store the VPC ID:
-	d.VpcID = *resp.Vpc.VpcId
+	d.VpcID = *vpc.Vpc.VpcId
 	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.VpcID}).
 		Info("New EC2 VPC created")
 
@@ -130,6 +220,27 @@ func (d *Data) createVPC() error {
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: enableDNSHostnames
+//-----------------------------------------------------------------------------
+
+func (d *Data) enableDNSHostnames() error {
+
+	// Send the attribute request:
+	if _, err := d.ec2.ModifyVpcAttribute(&ec2.ModifyVpcAttributeInput{
+		VpcId:              aws.String(d.VpcID),
+		EnableDnsHostnames: &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.VpcID}).
+		Info("VPC DNS hostnames enabled")
+
+	return nil
+}
+
 //-----------------------------------------------------------------------------
 // func: setupVPCNetwork
 //-----------------------------------------------------------------------------
@@ -144,18 +255,8 @@ func (d *Data) setupVPCNetwork(wg *sync.WaitGroup) {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
-	// Create the external and internal subnets:
-	if err := d.createSubnets(); err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
-	}
-
 	// Create a route table (ext):
-	if err := d.createRouteTable(); err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
-	}
-
-	// Associate the route table to the external subnet:
-	if err := d.associateRouteTable(); err != nil {
+	if err := d.createRouteTable("external", &d.RouteTableID); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
@@ -174,22 +275,70 @@ func (d *Data) setupVPCNetwork(wg *sync.WaitGroup) {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
+	// Setup the zones in parallel:
+	var wgZones sync.WaitGroup
+	for i := range d.Zones {
+		wgZones.Add(1)
+		go d.setupZoneNetwork(&wgZones, i)
+	}
+	wgZones.Wait()
+}
+
+//-----------------------------------------------------------------------------
+// func: setupZoneNetwork
+//-----------------------------------------------------------------------------
+
+func (d *Data) setupZoneNetwork(wg *sync.WaitGroup, i int) {
+
+	// Decrement:
+	defer wg.Done()
+	z := &d.Zones[i]
+
+	// Create the external and internal subnets:
+	if err := d.createSubnets(z); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Associate the route table to the external subnet:
+	if err := d.associateRouteTable(d.RouteTableID, z.ExtSubnetID); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Return if there is no internal subnet:
+	if z.IntSubnetCidr == "" {
+		return
+	}
+
 	// Allocate a new elastic IP:
-	if err := d.allocateElasticIP(); err != nil {
+	if err := d.allocateElasticIP(z); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
-	if d.IntSubnetCidr != "" {
+	// Create a NAT gateway:
+	if err := d.createNatGateway(z); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
 
-		// Create a NAT gateway:
-		if err := d.createNatGateway(); err != nil {
+	// The first zone is routed by the main route table:
+	routeTableID := d.MainRouteTableID
+	if i > 0 {
+
+		// Create a route table (int):
+		if err := d.createRouteTable("internal-"+z.Name, &z.IntRouteTableID); err != nil {
 			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 		}
 
-		// Create a default route via NAT GW (int):
-		if err := d.createNatGatewayRoute(); err != nil {
+		// Associate the route table to the internal subnet:
+		if err := d.associateRouteTable(z.IntRouteTableID, z.IntSubnetID); err != nil {
 			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 		}
+
+		routeTableID = z.IntRouteTableID
+	}
+
+	// Create a default route via NAT GW (int):
+	if err := d.createNatGatewayRoute(routeTableID, z.NatGatewayID); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 }
 
@@ -239,26 +388,57 @@ func (d *Data) retrieveMainRouteTableID() error {
 // func: createSubnets
 //-----------------------------------------------------------------------------
 
-func (d *Data) createSubnets() error {
+func (d *Data) createSubnets(z *AvailZone) error {
 
 	// Map to iterate:
 	nets := map[string]map[string]string{
 		"internal": {
-			"SubnetCidr": d.IntSubnetCidr, "SubnetID": d.IntSubnetID},
+			"SubnetCidr": z.IntSubnetCidr, "SubnetID": z.IntSubnetID},
 		"external": {
-			"SubnetCidr": d.ExtSubnetCidr, "SubnetID": d.ExtSubnetID},
+			"SubnetCidr": z.ExtSubnetCidr, "SubnetID": z.ExtSubnetID},
 	}
 
 	// For each subnet:
 	for k, v := range nets {
 
-		if v["SubnetCidr"] != "" && v["SubnetID"] == "" {
+		// Verify the defined subnet:
+		if v["SubnetID"] != "" {
+			subnet, err := d.describeSubnet("subnet-id", v["SubnetID"])
+			if err != nil {
+				return err
+			}
+			if subnet != nil {
+				d.checkDrift(k+" subnet", v["SubnetID"], "CidrBlock",
+					v["SubnetCidr"], *subnet.CidrBlock)
+				d.checkDrift(k+" subnet", v["SubnetID"], "AvailabilityZone",
+					d.Region+z.Name, *subnet.AvailabilityZone)
+				log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": v["SubnetID"]}).
+					Info("Using defined " + k + " subnet")
+				continue
+			}
+			d.checkDrift(k+" subnet", v["SubnetID"], "State", "available", "missing")
+			v["SubnetID"] = ""
+		}
+
+		if v["SubnetCidr"] != "" {
+
+			// Look for an existing subnet:
+			subnet, err := d.describeSubnet("cidr-block", v["SubnetCidr"])
+			if err != nil {
+				return err
+			}
+			if subnet != nil {
+				v["SubnetID"] = *subnet.SubnetId
+				log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": v["SubnetID"]}).
+					Info("Using existing " + k + " subnet")
+				continue
+			}
 
 			// Forge the subnet request:
 			params := &ec2.CreateSubnetInput{
 				CidrBlock:        aws.String(v["SubnetCidr"]),
 				VpcId:            aws.String(d.VpcID),
-				AvailabilityZone: aws.String(d.Region + d.Zone),
+				AvailabilityZone: aws.String(d.Region + z.Name),
 			}
 
 			// Send the subnet request:
@@ -274,30 +454,83 @@ func (d *Data) createSubnets() error {
 				Info("New " + k + " subnet")
 
 			// Tag the subnet:
-			if err = d.tag(v["SubnetID"], "Name", k); err != nil {
+			if err = d.tag(v["SubnetID"], "Name", k+"-"+z.Name); err != nil {
 				return err
 			}
 		}
 	}
 
 	// Store subnet IDs:
-	d.IntSubnetID = nets["internal"]["SubnetID"]
-	d.ExtSubnetID = nets["external"]["SubnetID"]
+	z.IntSubnetID = nets["internal"]["SubnetID"]
+	z.ExtSubnetID = nets["external"]["SubnetID"]
 
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: describeSubnet
+//-----------------------------------------------------------------------------
+
+func (d *Data) describeSubnet(filter, value string) (*ec2.Subnet, error) {
+
+	// Send the description request:
+	resp, err := d.ec2.DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
+			{
+				Name:   aws.String(filter),
+				Values: []*string{aws.String(value)},
+			},
+		},
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return nil, err
+	}
+
+	// Return the subnet (if any):
+	if len(resp.Subnets) == 0 {
+		return nil, nil
+	}
+
+	return resp.Subnets[0], nil
+}
+
 //-----------------------------------------------------------------------------
 // func: createRouteTable
 //-----------------------------------------------------------------------------
 
-func (d *Data) createRouteTable() error {
+func (d *Data) createRouteTable(name string, id *string) error {
+
+	// Verify the defined route table:
+	if *id != "" {
+		rt, err := d.describeRouteTable("route-table-id", *id)
+		if err != nil {
+			return err
+		}
+		if rt != nil {
+			log.WithFields(
+				log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+				Info("Using defined " + name + " route table")
+			return nil
+		}
+		d.checkDrift("route table", *id, "State", "available", "missing")
+		*id = ""
+	}
 
-	// Return if already defined:
-	if d.RouteTableID != "" {
+	// Look for an existing route table:
+	rt, err := d.describeRouteTable("tag:Name", name)
+	if err != nil {
+		return err
+	}
+	if rt != nil {
+		*id = *rt.RouteTableId
 		log.WithFields(
-			log.Fields{"cmd": "ec2:" + d.command, "id": d.RouteTableID}).
-			Info("Using defined route table")
+			log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+			Info("Using existing " + name + " route table")
 		return nil
 	}
 
@@ -314,23 +547,98 @@ func (d *Data) createRouteTable() error {
 	}
 
 	// Store the route table ID:
-	d.RouteTableID = *resp.RouteTable.RouteTableId
-	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.RouteTableID}).
-		Info("New route table added")
+	*id = *resp.RouteTable.RouteTableId
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+		Info("New " + name + " route table added")
+
+	// Tag the route table:
+	if err = d.tag(*id, "Name", name); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: describeRouteTable
+//-----------------------------------------------------------------------------
+
+func (d *Data) describeRouteTable(filter, value string) (*ec2.RouteTable, error) {
+
+	// Send the description request:
+	resp, err := d.ec2.DescribeRouteTables(&ec2.DescribeRouteTablesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
+			{
+				Name:   aws.String(filter),
+				Values: []*string{aws.String(value)},
+			},
+		},
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return nil, err
+	}
+
+	// Return the route table (if any):
+	if len(resp.RouteTables) == 0 {
+		return nil, nil
+	}
+
+	return resp.RouteTables[0], nil
+}
+
 //-----------------------------------------------------------------------------
 // func: associateRouteTable
 //-----------------------------------------------------------------------------
 
-func (d *Data) associateRouteTable() error {
+func (d *Data) associateRouteTable(routeTableID, subnetID string) error {
+
+	// Look for an existing association:
+	rt, err := d.describeRouteTable("association.subnet-id", subnetID)
+	if err != nil {
+		return err
+	}
+
+	if rt != nil {
+
+		// Return if already associated:
+		if *rt.RouteTableId == routeTableID {
+			log.WithFields(log.Fields{
+				"cmd": "ec2:" + d.command, "id": routeTableID}).
+				Info("Using existing route table association")
+			return nil
+		}
+
+		// Replace the wrong association:
+		d.checkDrift("subnet", subnetID, "RouteTableId",
+			routeTableID, *rt.RouteTableId)
+		for _, a := range rt.Associations {
+			if aws.StringValue(a.SubnetId) == subnetID {
+				if _, err := d.ec2.ReplaceRouteTableAssociation(
+					&ec2.ReplaceRouteTableAssociationInput{
+						AssociationId: a.RouteTableAssociationId,
+						RouteTableId:  aws.String(routeTableID),
+					}); err != nil {
+					log.WithField("cmd", "ec2:"+d.command).Error(err)
+					return err
+				}
+			}
+		}
+
+		log.WithFields(log.Fields{
+			"cmd": "ec2:" + d.command, "id": routeTableID}).
+			Info("Route table association replaced")
+		return nil
+	}
 
 	// Forge the association request:
 	params := &ec2.AssociateRouteTableInput{
-		RouteTableId: aws.String(d.RouteTableID),
-		SubnetId:     aws.String(d.ExtSubnetID),
+		RouteTableId: aws.String(routeTableID),
+		SubnetId:     aws.String(subnetID),
 	}
 
 	// Send the association request:
@@ -353,11 +661,43 @@ func (d *Data) associateRouteTable() error {
 
 func (d *Data) createInternetGateway() error {
 
-	// Return if already defined:
+	// Verify the defined internet gateway:
 	if d.InetGatewayID != "" {
+		resp, err := d.ec2.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
+			InternetGatewayIds: []*string{aws.String(d.InetGatewayID)},
+		})
+		if err != nil && !isAWSError(err, "NotFound") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+		if err == nil && len(resp.InternetGateways) > 0 {
+			log.WithFields(log.Fields{
+				"cmd": "ec2:" + d.command, "id": d.InetGatewayID}).
+				Info("Using defined internet gateway")
+			return nil
+		}
+		d.checkDrift("internet gateway", d.InetGatewayID, "State", "available", "missing")
+		d.InetGatewayID = ""
+	}
+
+	// Look for an attached internet gateway:
+	igws, err := d.ec2.DescribeInternetGateways(&ec2.DescribeInternetGatewaysInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("attachment.vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
+		},
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+	if len(igws.InternetGateways) > 0 {
+		d.InetGatewayID = *igws.InternetGateways[0].InternetGatewayId
 		log.WithFields(log.Fields{
 			"cmd": "ec2:" + d.command, "id": d.InetGatewayID}).
-			Info("Using defined internet gateway")
+			Info("Using existing internet gateway")
 		return nil
 	}
 
@@ -431,8 +771,7 @@ func (d *Data) createInternetGatewayRoute() error {
 	}
 
 	// Send the route request:
-	if _, err := d.ec2.CreateRoute(params); err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Error(err)
+	if err := d.ensureRoute(params, d.InetGatewayID); err != nil {
 		return err
 	}
 
@@ -442,20 +781,131 @@ func (d *Data) createInternetGatewayRoute() error {
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: ensureRoute
+//-----------------------------------------------------------------------------
+
+// Create the route unless it already exists. A route to the same destination
+// but through another target (or a blackhole) is drift and gets replaced.
+func (d *Data) ensureRoute(params *ec2.CreateRouteInput, target string) error {
+
+	// Retrieve the route table:
+	rt, err := d.describeRouteTable("route-table-id", *params.RouteTableId)
+	if err != nil {
+		return err
+	}
+
+	// Look for an existing route:
+	if rt != nil {
+		for _, r := range rt.Routes {
+
+			if aws.StringValue(r.DestinationCidrBlock) != *params.DestinationCidrBlock {
+				continue
+			}
+
+			actual := aws.StringValue(r.GatewayId) + aws.StringValue(r.NatGatewayId)
+			if actual == target && aws.StringValue(r.State) == ec2.RouteStateActive {
+				log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *params.RouteTableId}).
+					Info("Using existing route to " + *params.DestinationCidrBlock)
+				return nil
+			}
+
+			// Replace the drifted route:
+			d.checkDrift("route table", *params.RouteTableId,
+				*params.DestinationCidrBlock, target,
+				actual+" ("+aws.StringValue(r.State)+")")
+			if _, err := d.ec2.ReplaceRoute(&ec2.ReplaceRouteInput{
+				DestinationCidrBlock: params.DestinationCidrBlock,
+				RouteTableId:         params.RouteTableId,
+				GatewayId:            params.GatewayId,
+				NatGatewayId:         params.NatGatewayId,
+			}); err != nil {
+				log.WithField("cmd", "ec2:"+d.command).Error(err)
+				return err
+			}
+			return nil
+		}
+	}
+
+	// Send the route request:
+	if _, err := d.ec2.CreateRoute(params); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	return nil
+}
+
 //-----------------------------------------------------------------------------
 // func: allocateElasticIP
 //-----------------------------------------------------------------------------
 
-func (d *Data) allocateElasticIP() error {
+func (d *Data) allocateElasticIP(z *AvailZone) error {
 
-	// Return if already defined:
-	if d.AllocationID != "" {
-		log.WithFields(
-			log.Fields{"cmd": "ec2:" + d.command, "id": d.AllocationID}).
-			Info("Using defined elastic IP")
-		return nil
+	// Verify the defined elastic IP:
+	if z.AllocationID != "" {
+		resp, err := d.ec2.DescribeAddresses(&ec2.DescribeAddressesInput{
+			AllocationIds: []*string{aws.String(z.AllocationID)},
+		})
+		if err != nil && !isAWSError(err, "NotFound") {
+			log.WithField("cmd", "ec2:"+d.command).Error(err)
+			return err
+		}
+		if err == nil && len(resp.Addresses) > 0 {
+			log.WithFields(
+				log.Fields{"cmd": "ec2:" + d.command, "id": z.AllocationID}).
+				Info("Using defined elastic IP")
+			return nil
+		}
+		d.checkDrift("elastic ip", z.AllocationID, "State", "allocated", "missing")
+		z.AllocationID = ""
 	}
 
+	// Adopt the elastic IP of an existing NAT gateway:
+	if z.NatGatewayID == "" {
+		nat, err := d.describeNatGateway(&ec2.DescribeNatGatewaysInput{
+			Filter: []*ec2.Filter{
+				{
+					Name:   aws.String("subnet-id"),
+					Values: []*string{aws.String(z.ExtSubnetID)},
+				},
+				{
+					Name:   aws.String("state"),
+					Values: []*string{aws.String("pending"), aws.String("available")},
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if nat != nil && len(nat.NatGatewayAddresses) > 0 {
+			z.NatGatewayID = *nat.NatGatewayId
+			z.AllocationID = *nat.NatGatewayAddresses[0].AllocationId
+			log.WithFields(
+				log.Fields{"cmd": "ec2:" + d.command, "id": z.AllocationID}).
+				Info("Using existing elastic IP")
+			return nil
+		}
+	}
+
+	// Allocate a new one:
+	id, err := d.allocateAddress()
+	if err != nil {
+		return err
+	}
+
+	// Store the EIP ID:
+	z.AllocationID = id
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: allocateAddress
+//-----------------------------------------------------------------------------
+
+func (d *Data) allocateAddress() (string, error) {
+
 	// Forge the allocation request:
 	params := &ec2.AllocateAddressInput{
 		Domain: aws.String("vpc"),
@@ -465,28 +915,48 @@ func (d *Data) allocateElasticIP() error {
 	resp, err := d.ec2.AllocateAddress(params)
 	if err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Error(err)
-		return err
+		return "", err
 	}
 
-	// Store the EIP ID:
-	d.AllocationID = *resp.AllocationId
-	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.AllocationID}).
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *resp.AllocationId}).
 		Info("New elastic IP allocated")
 
-	return nil
+	return *resp.AllocationId, nil
 }
 
 //-----------------------------------------------------------------------------
 // func: createNatGateway
 //-----------------------------------------------------------------------------
 
-func (d *Data) createNatGateway() error {
+func (d *Data) createNatGateway(z *AvailZone) error {
+
+	// Verify the defined NAT gateway:
+	if z.NatGatewayID != "" {
+		nat, err := d.describeNatGateway(&ec2.DescribeNatGatewaysInput{
+			NatGatewayIds: []*string{aws.String(z.NatGatewayID)},
+		})
+		if err != nil {
+			return err
+		}
+		if nat != nil && (*nat.State == ec2.NatGatewayStatePending ||
+			*nat.State == ec2.NatGatewayStateAvailable) {
+			log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": z.NatGatewayID}).
+				Info("Using defined NAT gateway")
+			return d.waitNatGateway(z.NatGatewayID)
+		}
+		state := "missing"
+		if nat != nil {
+			state = *nat.State
+		}
+		d.checkDrift("nat gateway", z.NatGatewayID, "State", "available", state)
+		z.NatGatewayID = ""
+	}
 
 	// Forge the NAT gateway request:
 	params := &ec2.CreateNatGatewayInput{
-		AllocationId: aws.String(d.AllocationID),
-		SubnetId:     aws.String(d.ExtSubnetID),
-		ClientToken:  aws.String(d.Domain),
+		AllocationId: aws.String(z.AllocationID),
+		SubnetId:     aws.String(z.ExtSubnetID),
+		ClientToken:  aws.String(d.Domain + "-" + z.Name),
 	}
 
 	// Send the NAT gateway request:
@@ -497,15 +967,24 @@ func (d *Data) createNatGateway() error {
 	}
 
 	// Store the NAT gateway ID:
-	d.NatGatewayID = *resp.NatGateway.NatGatewayId
-	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.NatGatewayID}).
+	z.NatGatewayID = *resp.NatGateway.NatGatewayId
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": z.NatGatewayID}).
 		Info("New NAT gateway requested")
 
+	return d.waitNatGateway(z.NatGatewayID)
+}
+
+//-----------------------------------------------------------------------------
+// func: waitNatGateway
+//-----------------------------------------------------------------------------
+
+func (d *Data) waitNatGateway(id string) error {
+
 	// Wait until the NAT gateway is available:
-	log.WithField("cmd", "ec2:"+d.command).
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": id}).
 		Info("Waiting until NAT gateway is available")
 	if err := d.ec2.WaitUntilNatGatewayAvailable(&ec2.DescribeNatGatewaysInput{
-		NatGatewayIds: []*string{aws.String(d.NatGatewayID)},
+		NatGatewayIds: []*string{aws.String(id)},
 	}); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Error(err)
 		return err
@@ -514,22 +993,45 @@ func (d *Data) createNatGateway() error {
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: describeNatGateway
+//-----------------------------------------------------------------------------
+
+func (d *Data) describeNatGateway(params *ec2.DescribeNatGatewaysInput) (*ec2.NatGateway, error) {
+
+	// Send the description request:
+	resp, err := d.ec2.DescribeNatGateways(params)
+	if err != nil {
+		if isAWSError(err, "NotFound") {
+			return nil, nil
+		}
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return nil, err
+	}
+
+	// Return the NAT gateway (if any):
+	if len(resp.NatGateways) == 0 {
+		return nil, nil
+	}
+
+	return resp.NatGateways[0], nil
+}
+
 //-----------------------------------------------------------------------------
 // func: createNatGatewayRoute
 //-----------------------------------------------------------------------------
 
-func (d *Data) createNatGatewayRoute() error {
+func (d *Data) createNatGatewayRoute(routeTableID, natGatewayID string) error {
 
 	// Forge the route request:
 	params := &ec2.CreateRouteInput{
 		DestinationCidrBlock: aws.String("0.0.0.0/0"),
-		RouteTableId:         aws.String(d.MainRouteTableID),
-		NatGatewayId:         aws.String(d.NatGatewayID),
+		RouteTableId:         aws.String(routeTableID),
+		NatGatewayId:         aws.String(natGatewayID),
 	}
 
 	// Send the route request:
-	if _, err := d.ec2.CreateRoute(params); err != nil {
-		log.WithField("cmd", "ec2:"+d.command).Error(err)
+	if err := d.ensureRoute(params, natGatewayID); err != nil {
 		return err
 	}
 
@@ -572,12 +1074,63 @@ func (d *Data) setupIAMSecurity(wg *sync.WaitGroup) {
 		}
 	}
 
+	// Allow the nodes to decrypt their secrets:
+	if d.KmsKeyID != "" {
+		if err := d.putKMSPolicy(); err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+	}
+
 	// Add IAM role to instance profile:
 	if err := d.addIAMRoleToInstanceProfile(); err != nil {
 		os.Exit(1)
 	}
 }
 
+//-----------------------------------------------------------------------------
+// func: putKMSPolicy
+//-----------------------------------------------------------------------------
+
+// Inline policy of the kato role, one per cluster, granting kms:Decrypt on
+// the key sealing the node secrets.
+func (d *Data) putKMSPolicy() error {
+
+	// KMS IAM policy:
+	policy := `{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Effect": "Allow",
+            "Action": "kms:Decrypt",
+            "Resource": "` + d.KmsKeyID + `"
+        }
+    ]
+}`
+
+	// Send the policy request:
+	if _, err := d.iam.PutRolePolicy(&iam.PutRolePolicyInput{
+		PolicyDocument: aws.String(policy),
+		PolicyName:     aws.String(kmsPolicyName(d.ClusterID)),
+		RoleName:       aws.String("kato"),
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "role": "kato"}).
+		Info("Policy " + kmsPolicyName(d.ClusterID) + " attached")
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: kmsPolicyName
+//-----------------------------------------------------------------------------
+
+func kmsPolicyName(clusterID string) string {
+	return "kato-kms-" + clusterID
+}
+
 //-----------------------------------------------------------------------------
 // func: createRexrayPolicy
 //-----------------------------------------------------------------------------
@@ -602,9 +1155,9 @@ func (d *Data) createRexrayPolicy() error {
 	// Check whether the policy exists:
 	for _, v := range listRsp.Policies {
 		if *v.PolicyName == "REX-Ray" {
-			d.RexrayPolicy = *listRsp.Policies[0].Arn
-			log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *listRsp.
-				Policies[0].PolicyId}).Info("Using existing REX-Ray security policy")
+			d.RexrayPolicy = *v.Arn
+			log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *v.PolicyId}).
+				Info("Using existing REX-Ray security policy")
 			return nil
 		}
 	}
@@ -723,6 +1276,14 @@ func (d *Data) createIAMRole() error {
 	if err != nil {
 		if reqErr, ok := err.(awserr.RequestFailure); ok {
 			if reqErr.StatusCode() == 409 {
+				role, err := d.iam.GetRole(&iam.GetRoleInput{RoleName: aws.String("kato")})
+				if err != nil {
+					log.WithField("cmd", "ec2:"+d.command).Error(err)
+					return err
+				}
+				d.KatoRoleID = *role.Role.RoleId
+				log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.KatoRoleID}).
+					Info("Using existing kato IAM role")
 				return nil
 			}
 		}
@@ -859,10 +1420,30 @@ func (d *Data) setupEC2Firewall(wg *sync.WaitGroup) {
 
 func (d *Data) createSecurityGroup(name string, id *string) error {
 
-	// Return if already defined:
+	// Verify the defined group:
 	if *id != "" {
+		grp, err := d.describeSecurityGroup("group-id", *id)
+		if err != nil {
+			return err
+		}
+		if grp != nil {
+			log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
+				Info("Using defined " + name + " security group")
+			return nil
+		}
+		d.checkDrift(name+" security group", *id, "State", "available", "missing")
+		*id = ""
+	}
+
+	// Look for an existing group:
+	grp, err := d.describeSecurityGroup("group-name", name)
+	if err != nil {
+		return err
+	}
+	if grp != nil {
+		*id = *grp.GroupId
 		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": *id}).
-			Info("Using defined " + name + " security group")
+			Info("Using existing " + name + " security group")
 		return nil
 	}
 
@@ -894,34 +1475,74 @@ func (d *Data) createSecurityGroup(name string, id *string) error {
 }
 
 //-----------------------------------------------------------------------------
-// func: firewallQuorum
+// func: describeSecurityGroup
 //-----------------------------------------------------------------------------
 
-func (d *Data) firewallQuorum() error {
+func (d *Data) describeSecurityGroup(filter, value string) (*ec2.SecurityGroup, error) {
 
-	// Forge the rule request:
-	params := &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(d.QuorumSecGrp),
-		IpPermissions: []*ec2.IpPermission{
+	// Send the description request:
+	resp, err := d.ec2.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(d.VpcID)},
+			},
 			{
-				IpProtocol: aws.String("-1"),
-				UserIdGroupPairs: []*ec2.UserIdGroupPair{
-					{
-						GroupId: aws.String(d.QuorumSecGrp),
-					},
-					{
-						GroupId: aws.String(d.MasterSecGrp),
-					},
-					{
-						GroupId: aws.String(d.WorkerSecGrp),
-					},
-					{
-						GroupId: aws.String(d.BorderSecGrp),
-					},
+				Name:   aws.String(filter),
+				Values: []*string{aws.String(value)},
+			},
+		},
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return nil, err
+	}
+
+	// Return the group (if any):
+	if len(resp.SecurityGroups) == 0 {
+		return nil, nil
+	}
+
+	return resp.SecurityGroups[0], nil
+}
+
+//-----------------------------------------------------------------------------
+// func: firewallQuorumRules
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallQuorumRules() []*ec2.IpPermission {
+	return []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("-1"),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{
+				{
+					GroupId: aws.String(d.QuorumSecGrp),
+				},
+				{
+					GroupId: aws.String(d.MasterSecGrp),
+				},
+				{
+					GroupId: aws.String(d.WorkerSecGrp),
+				},
+				{
+					GroupId: aws.String(d.BorderSecGrp),
 				},
 			},
 		},
 	}
+}
+
+//-----------------------------------------------------------------------------
+// func: firewallQuorum
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallQuorum() error {
+
+	// Forge the rule request:
+	params := &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(d.QuorumSecGrp),
+		IpPermissions: d.firewallQuorumRules(),
+	}
 
 	// Send the rule request:
 	if _, err := d.ec2.AuthorizeSecurityGroupIngress(params); err != nil {
@@ -941,6 +1562,32 @@ func (d *Data) firewallQuorum() error {
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: firewallMasterRules
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallMasterRules() []*ec2.IpPermission {
+	return []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("-1"),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{
+				{
+					GroupId: aws.String(d.QuorumSecGrp),
+				},
+				{
+					GroupId: aws.String(d.MasterSecGrp),
+				},
+				{
+					GroupId: aws.String(d.WorkerSecGrp),
+				},
+				{
+					GroupId: aws.String(d.BorderSecGrp),
+				},
+			},
+		},
+	}
+}
+
 //-----------------------------------------------------------------------------
 // func: firewallMaster
 //-----------------------------------------------------------------------------
@@ -949,26 +1596,8 @@ func (d *Data) firewallMaster() error {
 
 	// Forge the rule request:
 	params := &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(d.MasterSecGrp),
-		IpPermissions: []*ec2.IpPermission{
-			{
-				IpProtocol: aws.String("-1"),
-				UserIdGroupPairs: []*ec2.UserIdGroupPair{
-					{
-						GroupId: aws.String(d.QuorumSecGrp),
-					},
-					{
-						GroupId: aws.String(d.MasterSecGrp),
-					},
-					{
-						GroupId: aws.String(d.WorkerSecGrp),
-					},
-					{
-						GroupId: aws.String(d.BorderSecGrp),
-					},
-				},
-			},
-		},
+		GroupId:       aws.String(d.MasterSecGrp),
+		IpPermissions: d.firewallMasterRules(),
 	}
 
 	// Send the rule request:
@@ -990,54 +1619,62 @@ func (d *Data) firewallMaster() error {
 }
 
 //-----------------------------------------------------------------------------
-// func: firewallWorker
+// func: firewallWorkerRules
 //-----------------------------------------------------------------------------
 
-func (d *Data) firewallWorker() error {
-
-	// Forge the rule request:
-	params := &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(d.WorkerSecGrp),
-		IpPermissions: []*ec2.IpPermission{
-			{
-				IpProtocol: aws.String("-1"),
-				UserIdGroupPairs: []*ec2.UserIdGroupPair{
-					{
-						GroupId: aws.String(d.QuorumSecGrp),
-					},
-					{
-						GroupId: aws.String(d.MasterSecGrp),
-					},
-					{
-						GroupId: aws.String(d.WorkerSecGrp),
-					},
-					{
-						GroupId: aws.String(d.BorderSecGrp),
-					},
+func (d *Data) firewallWorkerRules() []*ec2.IpPermission {
+	return []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("-1"),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{
+				{
+					GroupId: aws.String(d.QuorumSecGrp),
+				},
+				{
+					GroupId: aws.String(d.MasterSecGrp),
+				},
+				{
+					GroupId: aws.String(d.WorkerSecGrp),
+				},
+				{
+					GroupId: aws.String(d.BorderSecGrp),
 				},
 			},
-			{
-				FromPort:   aws.Int64(80),
-				ToPort:     aws.Int64(80),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(80),
+			ToPort:     aws.Int64(80),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
-			{
-				FromPort:   aws.Int64(443),
-				ToPort:     aws.Int64(443),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(443),
+			ToPort:     aws.Int64(443),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
 		},
 	}
+}
+
+//-----------------------------------------------------------------------------
+// func: firewallWorker
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallWorker() error {
+
+	// Forge the rule request:
+	params := &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(d.WorkerSecGrp),
+		IpPermissions: d.firewallWorkerRules(),
+	}
 
 	// Send the rule request:
 	if _, err := d.ec2.AuthorizeSecurityGroupIngress(params); err != nil {
@@ -1058,74 +1695,82 @@ func (d *Data) firewallWorker() error {
 }
 
 //-----------------------------------------------------------------------------
-// func: firewallBorder
+// func: firewallBorderRules
 //-----------------------------------------------------------------------------
 
-func (d *Data) firewallBorder() error {
-
-	// Forge the rule request:
-	params := &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(d.BorderSecGrp),
-		IpPermissions: []*ec2.IpPermission{
-			{
-				IpProtocol: aws.String("-1"),
-				UserIdGroupPairs: []*ec2.UserIdGroupPair{
-					{
-						GroupId: aws.String(d.QuorumSecGrp),
-					},
-					{
-						GroupId: aws.String(d.MasterSecGrp),
-					},
-					{
-						GroupId: aws.String(d.WorkerSecGrp),
-					},
-					{
-						GroupId: aws.String(d.BorderSecGrp),
-					},
+func (d *Data) firewallBorderRules() []*ec2.IpPermission {
+	return []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("-1"),
+			UserIdGroupPairs: []*ec2.UserIdGroupPair{
+				{
+					GroupId: aws.String(d.QuorumSecGrp),
+				},
+				{
+					GroupId: aws.String(d.MasterSecGrp),
+				},
+				{
+					GroupId: aws.String(d.WorkerSecGrp),
+				},
+				{
+					GroupId: aws.String(d.BorderSecGrp),
 				},
 			},
-			{
-				FromPort:   aws.Int64(22),
-				ToPort:     aws.Int64(22),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(22),
+			ToPort:     aws.Int64(22),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
-			{
-				FromPort:   aws.Int64(80),
-				ToPort:     aws.Int64(80),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(80),
+			ToPort:     aws.Int64(80),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
-			{
-				FromPort:   aws.Int64(443),
-				ToPort:     aws.Int64(443),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(443),
+			ToPort:     aws.Int64(443),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
-			{
-				FromPort:   aws.Int64(18443),
-				ToPort:     aws.Int64(18443),
-				IpProtocol: aws.String("udp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(18443),
+			ToPort:     aws.Int64(18443),
+			IpProtocol: aws.String("udp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
 		},
 	}
+}
+
+//-----------------------------------------------------------------------------
+// func: firewallBorder
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallBorder() error {
+
+	// Forge the rule request:
+	params := &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(d.BorderSecGrp),
+		IpPermissions: d.firewallBorderRules(),
+	}
 
 	// Send the rule request:
 	if _, err := d.ec2.AuthorizeSecurityGroupIngress(params); err != nil {
@@ -1164,41 +1809,80 @@ func (d *Data) setupEC2Balancer(wg *sync.WaitGroup) {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 
+	// Balance across all the zones:
+	if err := d.enableCrossZoneELB(); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
 	// Setup the ELB firewall:
 	if err := d.firewallELB(); err != nil {
 		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
 	}
 }
 
+//-----------------------------------------------------------------------------
+// func: elbListeners
+//-----------------------------------------------------------------------------
+
+func elbListeners() []*elb.Listener {
+	return []*elb.Listener{
+		{
+			InstancePort:     aws.Int64(80),
+			LoadBalancerPort: aws.Int64(80),
+			Protocol:         aws.String("TCP"),
+			InstanceProtocol: aws.String("TCP"),
+		},
+		{
+			InstancePort:     aws.Int64(443),
+			LoadBalancerPort: aws.Int64(443),
+			Protocol:         aws.String("TCP"),
+			InstanceProtocol: aws.String("TCP"),
+		},
+	}
+}
+
 //-----------------------------------------------------------------------------
 // func: createELB
 //-----------------------------------------------------------------------------
 
 func (d *Data) createELB() error {
 
+	// The external subnets of all the zones:
+	var subnets []*string
+	for _, z := range d.Zones {
+		subnets = append(subnets, aws.String(z.ExtSubnetID))
+	}
+
+	// Look for an existing ELB:
+	lbs, err := d.elb.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String(d.ClusterID)},
+	})
+	if err != nil && !isAWSError(err, elb.ErrCodeAccessPointNotFoundException) {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	// Use it if found:
+	if err == nil && len(lbs.LoadBalancerDescriptions) > 0 {
+		lb := lbs.LoadBalancerDescriptions[0]
+		d.DNSName = *lb.DNSName
+		d.ELBZoneID = aws.StringValue(lb.CanonicalHostedZoneNameID)
+		d.checkDrift("elb", d.ClusterID, "SecurityGroups",
+			d.ELBSecGrp, strings.Join(aws.StringValueSlice(lb.SecurityGroups), ","))
+		log.WithFields(log.Fields{
+			"cmd": "ec2:" + d.command, "id": d.DNSName}).
+			Info("Using existing ELB")
+		return d.attachELBSubnets(lb, subnets)
+	}
+
 	// Forge the ELB creation request:
 	params := &elb.CreateLoadBalancerInput{
-		Listeners: []*elb.Listener{
-			{
-				InstancePort:     aws.Int64(80),
-				LoadBalancerPort: aws.Int64(80),
-				Protocol:         aws.String("TCP"),
-				InstanceProtocol: aws.String("TCP"),
-			},
-			{
-				InstancePort:     aws.Int64(443),
-				LoadBalancerPort: aws.Int64(443),
-				Protocol:         aws.String("TCP"),
-				InstanceProtocol: aws.String("TCP"),
-			},
-		},
+		Listeners:        elbListeners(),
 		LoadBalancerName: aws.String(d.ClusterID),
 		SecurityGroups: []*string{
 			aws.String(d.ELBSecGrp),
 		},
-		Subnets: []*string{
-			aws.String(d.ExtSubnetID),
-		},
+		Subnets: subnets,
 		Tags: []*elb.Tag{
 			{
 				Key:   aws.String("Name"),
@@ -1220,44 +1904,135 @@ func (d *Data) createELB() error {
 		"cmd": "ec2:" + d.command, "id": d.DNSName}).
 		Info("New ELB DNS name created")
 
+	// Store the ELB hosted zone ID, needed by alias records:
+	lbs, err = d.elb.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{
+		LoadBalancerNames: []*string{aws.String(d.ClusterID)},
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+	if len(lbs.LoadBalancerDescriptions) > 0 {
+		d.ELBZoneID = aws.StringValue(lbs.LoadBalancerDescriptions[0].CanonicalHostedZoneNameID)
+	}
+
 	return nil
 }
 
 //-----------------------------------------------------------------------------
-// func: firewallELB
+// func: attachELBSubnets
 //-----------------------------------------------------------------------------
 
-func (d *Data) firewallELB() error {
+// Attach the external subnets of newly added zones to an existing ELB.
+func (d *Data) attachELBSubnets(lb *elb.LoadBalancerDescription, subnets []*string) error {
 
-	// Forge the rule request:
-	params := &ec2.AuthorizeSecurityGroupIngressInput{
-		GroupId: aws.String(d.ELBSecGrp),
-		IpPermissions: []*ec2.IpPermission{
-			{
-				IpProtocol: aws.String("-1"),
+	// Find the missing subnets:
+	var missing []*string
+	for _, id := range aws.StringValueSlice(subnets) {
+		found := false
+		for _, s := range aws.StringValueSlice(lb.Subnets) {
+			found = found || s == id
+		}
+		if !found {
+			d.checkDrift("elb", d.ClusterID, "Subnets", id,
+				strings.Join(aws.StringValueSlice(lb.Subnets), ","))
+			missing = append(missing, aws.String(id))
+		}
+	}
+
+	// Return if there is nothing to attach:
+	if len(missing) == 0 {
+		return nil
+	}
+
+	// Send the attach request:
+	if _, err := d.elb.AttachLoadBalancerToSubnets(&elb.AttachLoadBalancerToSubnetsInput{
+		LoadBalancerName: aws.String(d.ClusterID),
+		Subnets:          missing,
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+		Info("ELB attached to " + strings.Join(aws.StringValueSlice(missing), ","))
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: enableCrossZoneELB
+//-----------------------------------------------------------------------------
+
+func (d *Data) enableCrossZoneELB() error {
+
+	// Return if single zone:
+	if len(d.Zones) < 2 {
+		return nil
+	}
+
+	// Send the attributes request:
+	if _, err := d.elb.ModifyLoadBalancerAttributes(&elb.ModifyLoadBalancerAttributesInput{
+		LoadBalancerName: aws.String(d.ClusterID),
+		LoadBalancerAttributes: &elb.LoadBalancerAttributes{
+			CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{
+				Enabled: aws.Bool(true),
 			},
-			{
-				FromPort:   aws.Int64(80),
-				ToPort:     aws.Int64(80),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+	}); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Error(err)
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+		Info("ELB cross-zone load balancing enabled")
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: firewallELBRules
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallELBRules() []*ec2.IpPermission {
+	return []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("-1"),
+		},
+		{
+			FromPort:   aws.Int64(80),
+			ToPort:     aws.Int64(80),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
-			{
-				FromPort:   aws.Int64(443),
-				ToPort:     aws.Int64(443),
-				IpProtocol: aws.String("tcp"),
-				IpRanges: []*ec2.IpRange{
-					{
-						CidrIp: aws.String("0.0.0.0/0"),
-					},
+		},
+		{
+			FromPort:   aws.Int64(443),
+			ToPort:     aws.Int64(443),
+			IpProtocol: aws.String("tcp"),
+			IpRanges: []*ec2.IpRange{
+				{
+					CidrIp: aws.String("0.0.0.0/0"),
 				},
 			},
 		},
 	}
+}
+
+//-----------------------------------------------------------------------------
+// func: firewallELB
+//-----------------------------------------------------------------------------
+
+func (d *Data) firewallELB() error {
+
+	// Forge the rule request:
+	params := &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(d.ELBSecGrp),
+		IpPermissions: d.firewallELBRules(),
+	}
 
 	// Send the rule request:
 	if _, err := d.ec2.AuthorizeSecurityGroupIngress(params); err != nil {
@@ -0,0 +1,405 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Community:
+	"github.com/ajeddeloh/yaml"
+	"github.com/imdario/mergo"
+	"github.com/katosys/kato/pkg/cli"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Versioned cluster spec, the declarative form of the deploy flags. JSON is
+// valid YAML so both formats are accepted.
+type spec struct {
+	Version       string     `yaml:"version"`
+	ClusterID     string     `yaml:"clusterID"`
+	Domain        string     `yaml:"domain"`
+	Region        string     `yaml:"region"`
+	KeyPair       string     `yaml:"keyPair"`
+	CoreOSChannel string     `yaml:"coreosChannel,omitempty"`
+	CoreOSVersion string     `yaml:"coreosVersion,omitempty"`
+	EtcdToken     string     `yaml:"etcdToken,omitempty"`
+	EtcdDiscovery string     `yaml:"etcdDiscovery,omitempty"`
+	EtcdTLS       bool       `yaml:"etcdTLS,omitempty"`
+	EtcdCaCert    string     `yaml:"etcdCaCert,omitempty"`
+	EtcdCaKey     string     `yaml:"etcdCaKey,omitempty"`
+	CaCertPath    string     `yaml:"caCertPath,omitempty"`
+	PKI           bool       `yaml:"pki,omitempty"`
+	KmsKeyID      string     `yaml:"kmsKeyID,omitempty"`
+	VpcCidrBlock  string     `yaml:"vpcCidrBlock,omitempty"`
+	CalicoIPPool  string     `yaml:"calicoIPPool,omitempty"`
+	StubZones     []string   `yaml:"stubZones,omitempty"`
+	Zones         []specZone `yaml:"zones,omitempty"`
+	DNS           specDNS    `yaml:"dns,omitempty"`
+	Alerting      specAlert  `yaml:"alerting,omitempty"`
+	NodePools     []NodePool `yaml:"nodePools"`
+}
+
+// Availability zone and its subnets.
+type specZone struct {
+	Name               string `yaml:"name"`
+	InternalSubnetCidr string `yaml:"internalSubnetCidr,omitempty"`
+	ExternalSubnetCidr string `yaml:"externalSubnetCidr"`
+}
+
+// DNS provider settings.
+type specDNS struct {
+	Provider      string `yaml:"provider,omitempty"`
+	APIKey        string `yaml:"apiKey,omitempty"`
+	PublicIntZone bool   `yaml:"publicIntZone,omitempty"`
+}
+
+// Alerting settings.
+type specAlert struct {
+	SlackWebhook string `yaml:"slackWebhook,omitempty"`
+	SMTPURL      string `yaml:"smtpURL,omitempty"`
+	AdminEmail   string `yaml:"adminEmail,omitempty"`
+}
+
+// Current spec version:
+const specVersion = "v1"
+
+//-----------------------------------------------------------------------------
+// func: useSpec
+//-----------------------------------------------------------------------------
+
+// Replace the flag driven state with the one described by a spec file.
+// Secrets given as flags take precedence over the ones in the spec.
+func (d *Data) useSpec(specFile string) error {
+
+	// Read the spec:
+	s, err := readSpec(specFile)
+	if err != nil {
+		return err
+	}
+
+	// Forge the state:
+	st, err := s.state()
+	if err != nil {
+		return errors.New(specFile + ": " + err.Error())
+	}
+
+	// Keep the secrets given as flags:
+	if err := mergo.Merge(&d.Secrets, s.secrets()); err != nil {
+		return err
+	}
+
+	d.State = st
+	d.EtcdCaCert, d.EtcdCaKey = s.EtcdCaCert, s.EtcdCaKey
+	d.spec = s
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: readSpec
+//-----------------------------------------------------------------------------
+
+// Read, default and validate a cluster spec file.
+func readSpec(specFile string) (*spec, error) {
+
+	// Read the file:
+	raw, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode it:
+	s, err := decodeSpec(raw)
+	if err != nil {
+		return nil, errors.New(specFile + ": " + err.Error())
+	}
+
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: decodeSpec
+//-----------------------------------------------------------------------------
+
+func decodeSpec(raw []byte) (*spec, error) {
+
+	// Decode it, unknown fields are most likely typos:
+	s := &spec{}
+	if err := yaml.UnmarshalStrict(raw, s); err != nil {
+		return nil, err
+	}
+
+	// Fill in the defaults and validate:
+	s.setDefaults()
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: setDefaults
+//-----------------------------------------------------------------------------
+
+// Same defaults as the deploy flags.
+func (s *spec) setDefaults() {
+
+	for _, f := range []struct {
+		value *string
+		def   string
+	}{
+		{&s.CoreOSChannel, "stable"},
+		{&s.CoreOSVersion, "current"},
+		{&s.EtcdToken, "auto"},
+		{&s.EtcdDiscovery, "public"},
+		{&s.VpcCidrBlock, "10.0.0.0/16"},
+		{&s.CalicoIPPool, "10.128.0.0/21"},
+		{&s.DNS.Provider, "r53"},
+	} {
+		if *f.value == "" {
+			*f.value = f.def
+		}
+	}
+
+	if len(s.Zones) == 0 {
+		s.Zones = []specZone{{Name: "a", ExternalSubnetCidr: "10.0.0.0/24"}}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: validate
+//-----------------------------------------------------------------------------
+
+func (s *spec) validate() error {
+
+	// Version:
+	if s.Version != specVersion {
+		return errors.New("unsupported spec version '" + s.Version + "', expected " + specVersion)
+	}
+
+	// Required fields:
+	for _, f := range []struct{ field, value string }{
+		{"clusterID", s.ClusterID},
+		{"domain", s.Domain},
+		{"region", s.Region},
+		{"keyPair", s.KeyPair},
+	} {
+		if f.value == "" {
+			return errors.New("required field " + f.field + " not provided")
+		}
+	}
+
+	// Same checks as the deploy flags:
+	for _, f := range []struct{ field, value, regexp string }{
+		{"clusterID", s.ClusterID, "^[a-zA-Z0-9-]+$"},
+		{"coreosVersion", s.CoreOSVersion, coreosVersionRegexp},
+		{"etcdDiscovery", s.EtcdDiscovery, etcdDiscoveryRegexp},
+		{"kmsKeyID", s.KmsKeyID, kmsKeyRegexp},
+		{"alerting.smtpURL", s.Alerting.SMTPURL, "^smtp://(.+):(.+)@(.+):(\\d+)$"},
+		{"alerting.adminEmail", s.Alerting.AdminEmail, "^[\\w-.+]+@[\\w-.+]+\\.[a-z]{2,4}$"},
+	} {
+		if match, _ := regexp.MatchString(f.regexp, f.value); f.value != "" && !match {
+			return errors.New(f.field + " must match: " + f.regexp)
+		}
+	}
+
+	for _, f := range []struct {
+		field, value string
+		enum         []string
+	}{
+		{"region", s.Region, Ec2Regions},
+		{"coreosChannel", s.CoreOSChannel, []string{"stable", "beta", "alpha"}},
+		{"dns.provider", s.DNS.Provider, []string{"none", "ns1", "r53", "rfc2136"}},
+	} {
+		if !contains(f.enum, f.value) {
+			return errors.New(f.field + " must be one of [ " + strings.Join(f.enum, " | ") +
+				" ], but got: " + f.value)
+		}
+	}
+
+	if (s.EtcdCaCert == "") != (s.EtcdCaKey == "") || (s.EtcdCaCert != "" && !s.EtcdTLS) {
+		return errors.New("etcdCaCert and etcdCaKey go together with etcdTLS")
+	}
+
+	for _, z := range s.Zones {
+		if !contains(Ec2Zones, z.Name) {
+			return errors.New("zone must be one of [ " + strings.Join(Ec2Zones, " | ") +
+				" ], but got: " + z.Name)
+		}
+		if z.ExternalSubnetCidr == "" {
+			return errors.New("zone " + z.Name + ": externalSubnetCidr not provided")
+		}
+	}
+
+	// Node pools:
+	if len(s.NodePools) == 0 {
+		return errors.New("at least one node pool is required")
+	}
+
+	names := map[string]bool{}
+	for _, p := range s.NodePools {
+		if names[p.Name] {
+			return errors.New("node pool " + p.Name + " given more than once")
+		}
+		names[p.Name] = true
+		if err := cli.CheckQuadruplet(p.quadruplet(), Ec2Instances, cli.KatoRoles); err != nil {
+			return errors.New("node pool " + p.Name + ": " + err.Error())
+		}
+		if p.DiskSize < 0 {
+			return errors.New("node pool " + p.Name + ": diskSize must be a positive integer")
+		}
+		for k := range p.Tags {
+			if k == "" || k == "Name" {
+				return errors.New("node pool " + p.Name + ": invalid tag key '" + k + "'")
+			}
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: state
+//-----------------------------------------------------------------------------
+
+// Forge the deploy state described by the spec.
+func (s *spec) state() (State, error) {
+
+	// Zones and their subnets:
+	var names, intCidrs, extCidrs []string
+	for _, z := range s.Zones {
+		names = append(names, z.Name)
+		extCidrs = append(extCidrs, z.ExternalSubnetCidr)
+		if z.InternalSubnetCidr != "" {
+			intCidrs = append(intCidrs, z.InternalSubnetCidr)
+		}
+	}
+	zones, err := newAvailZones(names, intCidrs, extCidrs)
+	if err != nil {
+		return State{}, err
+	}
+
+	st := State{
+		ClusterID:     s.ClusterID,
+		CoreOSChannel: s.CoreOSChannel,
+		CoreOSVersion: s.CoreOSVersion,
+		KeyPair:       s.KeyPair,
+		EtcdToken:     s.EtcdToken,
+		EtcdDiscovery: s.EtcdDiscovery,
+		EtcdTLS:       s.EtcdTLS,
+		DNSProvider:   s.DNS.Provider,
+		PublicIntZone: s.DNS.PublicIntZone,
+		CaCertPath:    s.CaCertPath,
+		PKI:           s.PKI,
+		KmsKeyID:      s.KmsKeyID,
+		Domain:        s.Domain,
+		Region:        s.Region,
+		Zones:         zones,
+		VpcCidrBlock:  s.VpcCidrBlock,
+		CalicoIPPool:  s.CalicoIPPool,
+		StubZones:     s.StubZones,
+		AdminEmail:    s.Alerting.AdminEmail,
+		NodePools:     s.NodePools,
+	}
+
+	for _, p := range s.NodePools {
+		st.Quadruplets = append(st.Quadruplets, p.quadruplet())
+	}
+
+	return st, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: secrets
+//-----------------------------------------------------------------------------
+
+func (s *spec) secrets() Secrets {
+	return Secrets{
+		DNSApiKey:    s.DNS.APIKey,
+		SlackWebhook: s.Alerting.SlackWebhook,
+		SMTPURL:      s.Alerting.SMTPURL,
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: withoutSecrets
+//-----------------------------------------------------------------------------
+
+// A copy of the spec safe to store in clear next to the state file.
+func (s *spec) withoutSecrets() *spec {
+	c := *s
+	c.DNS.APIKey, c.Alerting.SlackWebhook, c.Alerting.SMTPURL = "", "", ""
+	return &c
+}
+
+//-----------------------------------------------------------------------------
+// func: quadruplet
+//-----------------------------------------------------------------------------
+
+func (p NodePool) quadruplet() string {
+	return strconv.Itoa(p.Count) + ":" + p.InstanceType + ":" +
+		p.Name + ":" + strings.Join(p.Roles, ",")
+}
+
+//-----------------------------------------------------------------------------
+// func: nodePools
+//-----------------------------------------------------------------------------
+
+// Node pools come from the spec file or, without per-pool options, from the
+// quadruplets.
+func (d *Data) nodePools() (pools []NodePool) {
+
+	if len(d.NodePools) > 0 {
+		return d.NodePools
+	}
+
+	for _, q := range d.Quadruplets {
+		s := strings.Split(q, ":")
+		count, _ := strconv.Atoi(s[0])
+		pools = append(pools, NodePool{
+			Name:         s[2],
+			Count:        count,
+			InstanceType: s[1],
+			Roles:        strings.Split(s[3], ","),
+		})
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: tags
+//-----------------------------------------------------------------------------
+
+// Pool tags as sorted <key>=<value> pairs.
+func (p NodePool) tags() (list []string) {
+	for k, v := range p.Tags {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: contains
+//-----------------------------------------------------------------------------
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
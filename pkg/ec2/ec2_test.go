@@ -1 +1,802 @@
 package ec2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/katosys/kato/pkg/dns"
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/pki"
+)
+
+func TestKeepsMajority(t *testing.T) {
+
+	for _, tc := range []struct {
+		size, live int
+		expected   bool
+	}{
+		{3, 3, true},
+		{3, 2, false},
+		{1, 1, false},
+		{5, 5, true},
+		{5, 4, true},
+		{5, 3, false},
+		{4, 4, true},
+		{4, 3, false},
+	} {
+		if got := keepsMajority(tc.size, tc.live); got != tc.expected {
+			t.Errorf("keepsMajority(%d, %d): expected %v, got %v",
+				tc.size, tc.live, tc.expected, got)
+		}
+	}
+}
+
+func TestSaveAndForgetNode(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	d := &Data{State: State{ClusterID: "test", VpcID: "vpc-1"}}
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, n := range []Node{
+		{HostName: "worker", HostID: "1", InstanceID: "i-1"},
+		{HostName: "worker", HostID: "2", InstanceID: "i-2"},
+		{HostName: "worker", HostID: "1", InstanceID: "i-3"},
+	} {
+		if err := d.saveNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(d.Nodes) != 2 || d.Nodes[0].InstanceID != "i-3" {
+		t.Fatalf("expected worker-1 to be replaced, got %+v", d.Nodes)
+	}
+
+	if err := d.forgetNode("worker", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := State{}
+	raw, _ := kato.ReadState(d.ClusterID)
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.VpcID != "vpc-1" || len(s.Nodes) != 1 || s.Nodes[0].HostID != "2" {
+		t.Fatalf("unexpected state: %+v", s)
+	}
+}
+
+func TestDestroyLocked(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	d := &Data{State: State{ClusterID: "test", VpcID: "vpc-1", KmsKeyID: "key-1"}}
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Destroy holds the lock, its own writes go through:
+	lock, err := kato.LockState(d.ClusterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.locked = true
+	if err := d.forget(&d.VpcID); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.saveNode(Node{HostName: "worker", HostID: "1", InstanceID: "i-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := kato.UnlockState(lock); err != nil {
+		t.Fatal(err)
+	}
+
+	s := State{}
+	raw, _ := kato.ReadState(d.ClusterID)
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.VpcID != "" || s.KmsKeyID != "key-1" || len(s.Nodes) != 1 {
+		t.Fatalf("unexpected state: %+v", s)
+	}
+}
+
+func TestApplyMasterCount(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	if err := kato.DumpState(State{ClusterID: "test", MasterCount: 1}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nodes added by apply render the count of the spec:
+	n := &Data{State: State{ClusterID: "test", MasterCount: 3}}
+	if err := n.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if n.MasterCount != 3 {
+		t.Fatalf("expected 3 masters, got %d", n.MasterCount)
+	}
+
+	// The state keeps the old count until apply succeeds:
+	o := &Data{State: State{ClusterID: "test"}}
+	if err := o.loadState(); err != nil {
+		t.Fatal(err)
+	}
+	if o.MasterCount != 1 {
+		t.Fatalf("expected 1 master, got %d", o.MasterCount)
+	}
+}
+
+func TestReconcileNodes(t *testing.T) {
+
+	d := &Data{State: State{
+		WorkerSecGrp: "sg-w",
+		BorderSecGrp: "sg-b",
+		Nodes: []Node{
+			{HostName: "worker", HostID: "1", Roles: "worker", InstanceID: "i-1"},
+			{HostName: "worker", HostID: "2", Roles: "worker", InstanceID: "i-2"},
+		},
+	}}
+
+	instances := []*ec2.Instance{
+		{
+			InstanceId:       aws.String("i-1"),
+			InstanceType:     aws.String("m4.large"),
+			State:            &ec2.InstanceState{Name: aws.String("running")},
+			PrivateIpAddress: aws.String("10.0.1.5"),
+		},
+		{
+			InstanceId:     aws.String("i-9"),
+			InstanceType:   aws.String("t2.small"),
+			State:          &ec2.InstanceState{Name: aws.String("running")},
+			SecurityGroups: []*ec2.GroupIdentifier{{GroupId: aws.String("sg-b")}},
+			Tags:           []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("border-1.example.com")}},
+		},
+		{
+			InstanceId: aws.String("i-0"),
+			State:      &ec2.InstanceState{Name: aws.String("terminated")},
+		},
+	}
+
+	nodes := d.reconcileNodes(instances, map[string]string{"i-1": "InService"})
+
+	expected := []nodeStatus{
+		{HostName: "border", HostID: "1", Roles: "border", InstanceType: "t2.small", InstanceID: "i-9", State: "running"},
+		{HostName: "worker", HostID: "1", Roles: "worker", InstanceType: "m4.large", InstanceID: "i-1", State: "running", PrivateIP: "10.0.1.5", ELB: "InService"},
+		{HostName: "worker", HostID: "2", Roles: "worker", InstanceID: "i-2", State: "missing"},
+	}
+
+	if !reflect.DeepEqual(nodes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, nodes)
+	}
+}
+
+func TestPrintNodes(t *testing.T) {
+
+	nodes := []nodeStatus{{HostName: "worker", HostID: "1", State: "running"}}
+
+	var buf bytes.Buffer
+	if err := printNodes(&buf, "table", nodes); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || fmt.Sprint(strings.Fields(lines[1])) != "[worker-1 running]" {
+		t.Errorf("unexpected table: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := printNodes(&buf, "yaml", nodes); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "- HostName: worker\n  HostID: \"1\"\n") {
+		t.Errorf("unexpected yaml: %q", buf.String())
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+
+	d := &Data{}
+	d.checkDrift("vpc", "vpc-1", "CidrBlock", "10.0.0.0/16", "10.0.0.0/16")
+	d.checkDrift("vpc", "vpc-1", "CidrBlock", "", "10.0.0.0/16")
+	d.checkDrift("vpc", "vpc-1", "CidrBlock", "10.1.0.0/16", "10.0.0.0/16")
+
+	expected := []drift{{Resource: "vpc", ID: "vpc-1", Field: "CidrBlock",
+		Expected: "10.1.0.0/16", Actual: "10.0.0.0/16"}}
+
+	if !reflect.DeepEqual(d.drifts, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, d.drifts)
+	}
+}
+
+func TestNewAvailZones(t *testing.T) {
+
+	zones, err := newAvailZones([]string{"a", "b"},
+		[]string{"10.0.128.0/18", "10.0.192.0/18"},
+		[]string{"10.0.0.0/18", "10.0.64.0/18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []AvailZone{
+		{Name: "a", IntSubnetCidr: "10.0.128.0/18", ExtSubnetCidr: "10.0.0.0/18"},
+		{Name: "b", IntSubnetCidr: "10.0.192.0/18", ExtSubnetCidr: "10.0.64.0/18"},
+	}
+	if !reflect.DeepEqual(zones, expected) {
+		t.Errorf("expected %+v, got %+v", expected, zones)
+	}
+
+	for _, tc := range []struct{ names, intCidrs, extCidrs []string }{
+		{[]string{"a", "b"}, nil, []string{"10.0.0.0/24"}},
+		{[]string{"a", "b"}, []string{"10.0.1.0/24"}, []string{"10.0.0.0/24", "10.0.2.0/24"}},
+		{[]string{"a", "a"}, nil, []string{"10.0.0.0/24", "10.0.2.0/24"}},
+	} {
+		if _, err := newAvailZones(tc.names, tc.intCidrs, tc.extCidrs); err == nil {
+			t.Errorf("expected an error for %+v", tc)
+		}
+	}
+}
+
+func TestDecodeState(t *testing.T) {
+
+	// Single-zone state files are migrated:
+	dat, err := decodeState([]byte(`{"Zone": "a", "ExtSubnetCidr": "10.0.0.0/24",
+		"ExtSubnetID": "subnet-1", "NatGatewayID": "nat-1", "AllocationID": "eipalloc-1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []AvailZone{{Name: "a", ExtSubnetCidr: "10.0.0.0/24",
+		ExtSubnetID: "subnet-1", NatGatewayID: "nat-1", AllocationID: "eipalloc-1"}}
+	if !reflect.DeepEqual(dat.Zones, expected) || dat.AllocationID != "" {
+		t.Fatalf("expected %+v, got %+v", expected, dat.Zones)
+	}
+
+	// Requested zones are filled with the recorded IDs:
+	zones := []AvailZone{{Name: "a", ExtSubnetCidr: "10.0.0.0/24"}, {Name: "b"}}
+	if err := mergeZones(&zones, dat.Zones); err != nil {
+		t.Fatal(err)
+	}
+	if zones[0].ExtSubnetID != "subnet-1" || zones[1].ExtSubnetID != "" {
+		t.Errorf("unexpected zones: %+v", zones)
+	}
+}
+
+func TestForgePlan(t *testing.T) {
+
+	d := &Data{}
+	d.ClusterID = "kato"
+	d.Domain = "cell-1.dc-1.kato.ci"
+	d.Region = "eu-west-1"
+	d.VpcCidrBlock = "10.0.0.0/16"
+	d.Zones = []AvailZone{{Name: "a", ExtSubnetCidr: "10.0.0.0/18"},
+		{Name: "b", ExtSubnetCidr: "10.0.64.0/18"}}
+	d.DNSProvider = "route53"
+	d.DNSApiKey = "secret"
+	d.AmiID = "ami-1"
+	d.VpcID = "vpc-1"
+	d.Quadruplets = []string{"3:m3.large:kato:quorum,master"}
+
+	p := d.forgePlan()
+
+	if out, _ := json.Marshal(p); strings.Contains(string(out), "secret") {
+		t.Error("secrets must be left out of the plan")
+	}
+
+	if p.Network[0] != "reuse vpc vpc-1 (10.0.0.0/16)" {
+		t.Errorf("unexpected network: %q", p.Network[0])
+	}
+
+	var zones, ips []string
+	for _, n := range p.Nodes {
+		zones = append(zones, n.Zone)
+		ips = append(ips, n.PrivateIP)
+	}
+	if !reflect.DeepEqual(zones, []string{"a", "b", "a"}) {
+		t.Errorf("nodes not spread across zones: %v", zones)
+	}
+	if !reflect.DeepEqual(ips, []string{"10.0.0.11", "10.0.64.12", "10.0.0.13"}) {
+		t.Errorf("unexpected master IPs: %v", ips)
+	}
+
+	if len(p.Records) != 3+3*2*3 {
+		t.Errorf("unexpected records: %v", p.Records)
+	}
+
+	if d.QuorumSecGrp != "" {
+		t.Error("security group placeholders must not leak")
+	}
+}
+
+func TestDNSRecords(t *testing.T) {
+
+	f := dns.NewFake()
+	dns.Register("fake", func(string) dns.Provider { return f })
+
+	d := &Data{}
+	d.DNSProvider = "fake"
+	d.Domain = "example.com"
+	d.HostID = "1"
+	for _, zone := range []string{d.Domain, "int." + d.Domain, "ext." + d.Domain} {
+		f.AddZone(zone)
+	}
+
+	out := []byte(`{"internal": "10.0.1.5", "external": "52.0.0.1"}`)
+	if err := d.publishDNSRecords("master,worker", out); err != nil {
+		t.Fatal(err)
+	}
+
+	records, _ := f.ListRecords("int." + d.Domain)
+	expected := []dns.Record{
+		{Name: "master-1", Type: "A", Data: []string{"10.0.1.5"}},
+		{Name: "worker-1", Type: "A", Data: []string{"10.0.1.5"}},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %+v, got %+v", expected, records)
+	}
+
+	records, _ = f.ListRecords(d.Domain)
+	if len(records) != 2 || records[0].Data[0] != "master-1.int.example.com" {
+		t.Errorf("unexpected CNAME records: %+v", records)
+	}
+
+	if err := d.deleteDNSRecords("master"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, zone := range []string{d.Domain, "int." + d.Domain, "ext." + d.Domain} {
+		if records, _ := f.ListRecords(zone); len(records) != 1 || records[0].Name != "worker-1" {
+			t.Errorf("%s: unexpected records: %+v", zone, records)
+		}
+	}
+}
+
+func TestDNSRecordsAlias(t *testing.T) {
+
+	f := dns.NewFake()
+	dns.Register("r53", func(string) dns.Provider { return f })
+
+	d := &Data{}
+	d.DNSProvider = "r53"
+	d.Domain = "example.com"
+	d.HostID = "1"
+	d.DNSName = "kato-1.eu-west-1.elb.amazonaws.com"
+	d.ELBZoneID = "Z32O12XQLNTSW2"
+	for _, zone := range []string{d.Domain, "int." + d.Domain, "ext." + d.Domain} {
+		f.AddZone(zone)
+	}
+
+	out := []byte(`{"internal": "10.0.1.5", "external": "52.0.0.1"}`)
+	if err := d.publishDNSRecords("worker", out); err != nil {
+		t.Fatal(err)
+	}
+
+	records, _ := f.ListRecords("ext." + d.Domain)
+	expected := []dns.Record{{Name: "@", Type: "A",
+		Alias: &dns.Alias{DNSName: d.DNSName, ZoneID: d.ELBZoneID}}}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %+v, got %+v", expected, records)
+	}
+}
+
+func TestUseSpec(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	yamlSpec := `
+version: v1
+clusterID: foo
+domain: foo.example.com
+region: eu-west-1
+keyPair: foo
+zones:
+  - name: a
+    externalSubnetCidr: 10.0.0.0/24
+  - name: b
+    externalSubnetCidr: 10.0.1.0/24
+dns:
+  provider: ns1
+  apiKey: spec-key
+nodePools:
+  - name: master
+    count: 3
+    instanceType: m3.large
+    roles: [quorum, master]
+  - name: worker
+    count: 2
+    instanceType: m3.large
+    roles: [worker]
+    diskSize: 100
+    tags: {team: infra, env: prod}
+`
+	jsonSpec := `{"version": "v1", "clusterID": "foo", "domain": "foo.example.com",
+  "region": "eu-west-1", "keyPair": "foo",
+  "zones": [{"name": "a", "externalSubnetCidr": "10.0.0.0/24"},
+            {"name": "b", "externalSubnetCidr": "10.0.1.0/24"}],
+  "dns": {"provider": "ns1", "apiKey": "spec-key"},
+  "nodePools": [
+    {"name": "master", "count": 3, "instanceType": "m3.large", "roles": ["quorum", "master"]},
+    {"name": "worker", "count": 2, "instanceType": "m3.large", "roles": ["worker"],
+     "diskSize": 100, "tags": {"team": "infra", "env": "prod"}}]}`
+
+	var states []State
+	var secrets []Secrets
+	for name, data := range map[string]string{"cluster.yaml": yamlSpec, "cluster.json": jsonSpec} {
+		file := dir + "/" + name
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		d := &Data{Secrets: Secrets{DNSApiKey: "flag-key"}}
+		if err := d.useSpec(file); err != nil {
+			t.Fatal(err)
+		}
+		states = append(states, d.State)
+		secrets = append(secrets, d.Secrets)
+	}
+
+	if !reflect.DeepEqual(states[0], states[1]) {
+		t.Errorf("YAML and JSON specs differ:\n%+v\n%+v", states[0], states[1])
+	}
+
+	st := states[0]
+	if secrets[0].DNSApiKey != "flag-key" || st.CoreOSChannel != "stable" || len(st.Zones) != 2 {
+		t.Errorf("unexpected state: %+v", st)
+	}
+	if expected := []string{"3:m3.large:master:quorum,master", "2:m3.large:worker:worker"}; !reflect.DeepEqual(st.Quadruplets, expected) {
+		t.Errorf("expected %v, got %v", expected, st.Quadruplets)
+	}
+	if tags := st.NodePools[1].tags(); !reflect.DeepEqual(tags, []string{"env=prod", "team=infra"}) {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+
+	// Invalid specs:
+	for _, tc := range []struct{ old, new string }{
+		{"version: v1", "version: v2"},
+		{"region: eu-west-1", "region: mars-1"},
+		{"instanceType: m3.large\n    roles: [worker]", "instanceType: t9.huge\n    roles: [worker]"},
+		{"roles: [worker]", "roles: [edge]"},
+		{"name: worker", "name: master"},
+		{"diskSize: 100", "diskSize: 100\n    size: 3"},
+	} {
+		file := dir + "/invalid.yaml"
+		if err := ioutil.WriteFile(file, []byte(strings.Replace(yamlSpec, tc.old, tc.new, 1)), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := (&Data{}).useSpec(file); err == nil {
+			t.Errorf("expected an error for %q", tc.new)
+		}
+	}
+}
+
+func TestDiffNodes(t *testing.T) {
+
+	pools := []NodePool{
+		{Name: "master", Count: 1, InstanceType: "m3.large", Roles: []string{"quorum", "master"}},
+		{Name: "worker", Count: 3, InstanceType: "m3.xlarge", Roles: []string{"worker"}},
+	}
+
+	nodes := []nodeStatus{
+		{HostName: "master", HostID: "1", Roles: "master,quorum", InstanceType: "m3.large", State: "running"},
+		{HostName: "worker", HostID: "1", Roles: "worker", InstanceType: "m3.xlarge", State: "running"},
+		{HostName: "worker", HostID: "2", Roles: "worker", InstanceType: "m3.large", State: "running"},
+		{HostName: "worker", HostID: "3", Roles: "worker", InstanceType: "m3.xlarge", State: "missing"},
+		{HostName: "worker", HostID: "4", Roles: "worker", InstanceType: "m3.xlarge", State: "running"},
+		{HostName: "worker", HostID: "10", Roles: "worker", InstanceType: "m3.xlarge", State: "stopped"},
+		{HostName: "border", HostID: "1", Roles: "border", InstanceType: "m3.medium", State: "running"},
+		{InstanceID: "i-unnamed", State: "running"},
+	}
+
+	var got []string
+	for _, c := range diffNodes(pools, nodes) {
+		got = append(got, c.Action+" "+c.HostName+"-"+c.HostID+" "+c.From+">"+c.To)
+	}
+
+	expected := []string{
+		"replace worker-2 m3.large>m3.xlarge",
+		"add worker-3 >m3.xlarge",
+		"remove border-1 m3.medium>",
+		"remove worker-10 m3.xlarge>",
+		"remove worker-4 m3.xlarge>",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	// Scaling up is only adds:
+	pools[1].Count = 10
+	adds := 0
+	for _, c := range diffNodes(pools, nodes[:2]) {
+		if c.Action != "add" {
+			t.Errorf("unexpected change: %+v", c)
+		}
+		adds++
+	}
+	if adds != 9 {
+		t.Errorf("expected 9 adds, got %d", adds)
+	}
+}
+
+func TestZkAlive(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	defer func(delay time.Duration) { zkRetryDelay = delay }(zkRetryDelay)
+	zkRetryDelay = time.Millisecond
+
+	// Two healthy servers and a dead one, asked from the border node:
+	defer func(fn func(string, string) ([]byte, error)) { onNode = fn }(onNode)
+	onNode = func(host, script string) ([]byte, error) {
+		if host != "54.0.0.1" {
+			t.Errorf("expected the checks on the border node, got %s", host)
+		}
+		if !strings.Contains(script, "ncat -w 2 10.0.0.3 2181") {
+			t.Errorf("expected all the servers asked, got %q", script)
+		}
+		return []byte("2\n"), nil
+	}
+
+	d := &Data{State: State{ClusterID: "test", Nodes: []Node{
+		{HostName: "border", HostID: "1", Roles: "border", PrivateIP: "10.0.0.9", PublicIP: "54.0.0.1"},
+	}}}
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		t.Fatal(err)
+	}
+	addrs := []string{"10.0.0.1:2181", "10.0.0.2:2181", "10.0.0.3:2181"}
+	if !d.zkAlive(addrs, 2, 1) {
+		t.Error("expected a majority of 2")
+	}
+	if d.zkAlive(addrs, 3, 2) {
+		t.Error("expected no ensemble of 3")
+	}
+
+	// Etcd members by name:
+	onNode = func(host, script string) ([]byte, error) {
+		return []byte("8e9e05c52164694d: name=quorum-1 peerURLs=http://10.0.0.5:2380 " +
+			"clientURLs=http://10.0.0.5:2379 isLeader=true\n" +
+			"91bc3c398fb3c146[unstarted]: peerURLs=http://10.0.1.6:2380\n"), nil
+	}
+	if id, err := d.etcdMemberID("quorum-1"); err != nil || id != "8e9e05c52164694d" {
+		t.Errorf("expected member 8e9e05c52164694d, got %q (%v)", id, err)
+	}
+	if id, err := d.etcdMemberID("quorum-2"); err != nil || id != "" {
+		t.Errorf("expected no member, got %q (%v)", id, err)
+	}
+
+	// Border nodes recorded meanwhile are used, never the ones being replaced:
+	var hosts []string
+	onNode = func(host, script string) ([]byte, error) {
+		hosts = append(hosts, host)
+		return nil, nil
+	}
+	if err := kato.DumpState(State{ClusterID: "test", Nodes: []Node{
+		{HostName: "border", HostID: "1", Roles: "border", PublicIP: "54.0.0.1"},
+		{HostName: "border", HostID: "2", Roles: "border", PublicIP: "54.0.0.2"},
+	}}, d.ClusterID); err != nil {
+		t.Fatal(err)
+	}
+	d.leaving = &sync.Map{}
+	d.leaving.Store("border-1", true)
+	if _, err := d.onCluster("true"); err != nil || !reflect.DeepEqual(hosts, []string{"54.0.0.2"}) {
+		t.Errorf("expected border-2 used, got %v (%v)", hosts, err)
+	}
+	d.leaving.Store("border-2", true)
+	if _, err := d.onCluster("true"); err == nil {
+		t.Error("expected an error with every border node leaving")
+	}
+
+	// No border node at all:
+	d = &Data{State: State{ClusterID: "test", Nodes: []Node{
+		{HostName: "quorum", HostID: "1", Roles: "quorum", PrivateIP: "10.0.0.1"},
+	}}}
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.requireBorder(); err != errNoBorder {
+		t.Errorf("expected %v, got %v", errNoBorder, err)
+	}
+	if _, err := d.onCluster("true"); err != errNoBorder {
+		t.Errorf("expected %v, got %v", errNoBorder, err)
+	}
+
+	// The quorum addresses leave out the nodes going down:
+	d = &Data{State: State{Nodes: []Node{
+		{HostName: "quorum", HostID: "1", Roles: "quorum", PrivateIP: "10.0.0.1"},
+		{HostName: "quorum", HostID: "2", Roles: "quorum,master", PrivateIP: "10.0.0.2"},
+		{HostName: "worker", HostID: "1", Roles: "worker", PrivateIP: "10.0.0.3"},
+	}}}
+	got := d.quorumAddrs([]nodeStatus{{HostName: "quorum", HostID: "1"}})
+	if !reflect.DeepEqual(got, []string{"10.0.0.2:2181"}) {
+		t.Errorf("unexpected quorum addresses: %v", got)
+	}
+
+	for roles, phase := range map[string]string{
+		"worker,master": "master", "border": "border", "quorum,master,worker": "quorum"} {
+		if got := upgradePhase(roles); got != phase {
+			t.Errorf("upgradePhase(%q): expected %s, got %s", roles, phase, got)
+		}
+	}
+}
+
+func TestRequireKMS(t *testing.T) {
+
+	for i, tc := range []struct {
+		d  *Data
+		ok bool
+	}{
+		{&Data{}, true},
+		{&Data{Secrets: Secrets{DNSApiKey: "key"}}, false},
+		{&Data{State: State{EtcdTLS: true}}, false},
+		{&Data{State: State{PKI: true}}, false},
+		{&Data{State: State{PKI: true, KmsKeyID: "arn:aws:kms:eu-west-1:123456789012:key/0b5d3ea1"}}, true},
+	} {
+		if err := tc.d.requireKMS(); (err == nil) != tc.ok {
+			t.Errorf("case %d: expected ok %v, got %v", i, tc.ok, err)
+		}
+	}
+}
+
+func TestNodeCert(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	if _, err := pki.InitStore("test", "kato.ci", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The revocation list goes to etcd through the border node:
+	var pushed string
+	defer func(fn func(string, string) ([]byte, error)) { onNode = fn }(onNode)
+	onNode = func(host, script string) ([]byte, error) {
+		pushed = script
+		return nil, nil
+	}
+
+	d := &Data{State: State{ClusterID: "test", Nodes: []Node{
+		{HostName: "border", HostID: "1", Roles: "border", PrivateIP: "10.0.0.9", PublicIP: "54.0.0.1"},
+	}}}
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		t.Fatal(err)
+	}
+	d.HostName, d.HostID, d.Roles = "master", "1", "master"
+	z := &AvailZone{ExtSubnetCidr: "10.0.0.0/24"}
+
+	for i, superseded := range []bool{false, true} {
+
+		// Signed with the fixed IP, recorded once accepted:
+		nc, err := d.signNodeCert(z)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(strings.Join(nc.hosts, " "), "10.0.0.11") {
+			t.Errorf("expected the fixed IP in %v", nc.hosts)
+		}
+		if n := len(nc.store.Certs); n != i {
+			t.Errorf("expected %d recorded certificates, got %d", i, n)
+		}
+		pushed = ""
+		if err := d.recordNodeCert(nc); err != nil {
+			t.Fatal(err)
+		}
+		if superseded != strings.Contains(pushed, "set /kato/pki/crl ") {
+			t.Errorf("expected the revocation list pushed: %v, got %q", superseded, pushed)
+		}
+	}
+}
+
+func TestRetrieveCoreOSAmiID(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	// Fake feeds:
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/aws-stable.json":
+			fmt.Fprint(w, `{"release_info": {"version": "1632.3.0"},
+				"eu-west-1": {"hvm": "ami-current", "pv": "ami-pv"}, "us-east-1": {"pv": "ami-pv"}}`)
+		case "/stable/1520.8.0":
+			fmt.Fprint(w, `{"amis": [{"name": "eu-west-1", "hvm": "ami-pinned", "pv": "ami-pv"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer func(current, version string) {
+		coreosCurrentURL, coreosVersionURL = current, version
+	}(coreosCurrentURL, coreosVersionURL)
+	coreosCurrentURL = srv.URL + "/aws-%s.json"
+	coreosVersionURL = srv.URL + "/%s/%s"
+
+	d := &Data{State: State{CoreOSChannel: "stable", Region: "eu-west-1"}}
+	check := func(version, expected string) {
+		d.CoreOSVersion = version
+		if amiID, err := d.retrieveCoreOSAmiID(); err != nil || amiID != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", version, expected, amiID, err)
+		}
+	}
+
+	// From the feeds:
+	check("", "ami-current")
+	check("1520.8.0", "ami-pinned")
+
+	// Missing regions and virtualization types are errors:
+	for _, region := range []string{"ap-south-9", "us-east-1"} {
+		d.Region = region
+		if _, err := d.retrieveCoreOSAmiID(); err == nil {
+			t.Errorf("%s: expected an error", region)
+		}
+	}
+	d.Region = "eu-west-1"
+
+	// Unknown versions are errors:
+	d.CoreOSVersion = "1.2.3"
+	if _, err := d.retrieveCoreOSAmiID(); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+
+	// From the cache with the feeds down:
+	srv.Close()
+	check("current", "ami-current")
+	check("1520.8.0", "ami-pinned")
+
+	// Tampered caches are rejected:
+	cacheFile := home + "/.kato/coreos/stable-current.json"
+	if err := ioutil.WriteFile(cacheFile, []byte(`{"eu-west-1": {"hvm": "ami-evil"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	d.CoreOSVersion = "current"
+	if _, err := d.retrieveCoreOSAmiID(); err == nil {
+		t.Error("expected an error for a tampered cache")
+	}
+}
@@ -0,0 +1,687 @@
+package ec2
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"errors"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/katosys/kato/pkg/kato"
+)
+
+//-----------------------------------------------------------------------------
+// Package factored var statement:
+//-----------------------------------------------------------------------------
+
+var (
+
+	// Upgrade phases, one role at a time:
+	upgradeOrder = []string{"quorum", "master", "worker", "border"}
+
+	// ZooKeeper client port and zk-alive retry delay:
+	zkPort       = "2181"
+	zkRetryDelay = 5 * time.Second
+
+	// Runs a script as root on a node, the health checks run in the cluster:
+	onNode = sshScript
+
+	// Quorum checks need a border node:
+	errNoBorder = errors.New("Ops! no border node reachable over SSH, " +
+		"quorum nodes are checked from one")
+)
+
+//-----------------------------------------------------------------------------
+// func: Upgrade
+//-----------------------------------------------------------------------------
+
+// Upgrade replaces the nodes not running the current channel AMI, quorum
+// first, then masters, workers and border nodes.
+func (d *Data) Upgrade(resume bool) {
+
+	// Set current command:
+	d.command = "upgrade"
+
+	// Load state from state file:
+	if err := d.loadState(); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	// Start or resume the upgrade:
+	if err := d.startUpgrade(resume); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.Upgrading.AmiID}).
+		Info("Upgrading cluster " + d.ClusterID)
+
+	// Connect and authenticate to the API endpoints:
+	d.setupAPIEndpoints()
+	d.leaving = &sync.Map{}
+
+	// One phase per role:
+	for _, role := range upgradeOrder {
+
+		// Nodes still running another AMI:
+		nodes, err := d.outdatedNodes(role)
+		if err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+		}
+
+		// The quorum goes first, nothing is touched without a border node:
+		if role == "quorum" && len(nodes) > 0 {
+			if err := d.requireBorder(); err != nil {
+				log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+			}
+		}
+
+		// Quorum and master nodes go one at a time:
+		batch := 1
+		if role == "worker" || role == "border" {
+			batch = d.MaxUnavailable
+		}
+
+		for i := 0; i < len(nodes); i += batch {
+
+			// Stop here if paused:
+			if err := d.checkPaused(); err != nil {
+				log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+			}
+
+			end := i + batch
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			if err := d.upgradeNodes(role, nodes[i:end]); err != nil {
+				log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+			}
+		}
+	}
+
+	// Forget the finished upgrade:
+	if err := d.updateState(func(s *State) { s.Upgrading = nil }); err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+		Info("Cluster upgraded")
+}
+
+//-----------------------------------------------------------------------------
+// func: PauseUpgrade
+//-----------------------------------------------------------------------------
+
+// PauseUpgrade stops a running upgrade once its current batch is done.
+func (d *Data) PauseUpgrade() {
+
+	// Set current command:
+	d.command = "upgrade"
+
+	// Flag the upgrade as paused:
+	found := false
+	err := d.updateState(func(s *State) {
+		if s.Upgrading != nil {
+			s.Upgrading.Paused, found = true, true
+		}
+	})
+	if err != nil {
+		log.WithField("cmd", "ec2:"+d.command).Fatal(err)
+	}
+	if !found {
+		log.WithField("cmd", "ec2:"+d.command).Fatal("Ops! there is no upgrade in progress")
+	}
+
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": d.ClusterID}).
+		Info("Upgrade paused, continue with --resume")
+}
+
+//-----------------------------------------------------------------------------
+// func: startUpgrade
+//-----------------------------------------------------------------------------
+
+// Record the target AMI in the state file so the upgrade can be resumed.
+func (d *Data) startUpgrade(resume bool) error {
+
+	// Resume:
+	if resume {
+		if d.Upgrading == nil {
+			return errors.New("Ops! there is no upgrade to resume")
+		}
+		return d.updateState(func(s *State) {
+			if s.Upgrading == nil {
+				s.Upgrading = d.Upgrading
+			}
+			s.Upgrading.Paused = false
+			d.Upgrading = s.Upgrading
+		})
+	}
+
+	// Refuse to start twice:
+	if d.Upgrading != nil {
+		return errors.New("Ops! an upgrade to " + d.Upgrading.AmiID +
+			" is in progress, use --resume")
+	}
+
+	// Target the current channel AMI:
+	if d.AmiID == "" {
+		var err error
+		if d.AmiID, err = d.retrieveCoreOSAmiID(); err != nil {
+			return err
+		}
+	}
+
+	// New nodes added later get the same version:
+	d.Upgrading = &Upgrade{AmiID: d.AmiID}
+	return d.updateState(func(s *State) {
+		s.Upgrading = d.Upgrading
+		s.CoreOSVersion = d.CoreOSVersion
+	})
+}
+
+//-----------------------------------------------------------------------------
+// func: checkPaused
+//-----------------------------------------------------------------------------
+
+func (d *Data) checkPaused() error {
+
+	// Read the latest state:
+	s, err := d.refreshNodes()
+	if err != nil {
+		return err
+	}
+
+	if s.Upgrading != nil && s.Upgrading.Paused {
+		return errors.New("Ops! upgrade paused, continue with --resume")
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: refreshNodes
+//-----------------------------------------------------------------------------
+
+// Re-read the state file, replaced nodes are recorded by other processes.
+func (d *Data) refreshNodes() (State, error) {
+
+	// Read the latest state:
+	raw, err := kato.ReadState(d.ClusterID)
+	if err != nil {
+		return State{}, err
+	}
+	s, err := decodeState(raw)
+	if err != nil {
+		return s, err
+	}
+
+	// Keep the inventory in sync:
+	d.Nodes = s.Nodes
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: outdatedNodes
+//-----------------------------------------------------------------------------
+
+// Live nodes whose highest priority role is the given one and which run an
+// AMI other than the target.
+func (d *Data) outdatedNodes(role string) ([]nodeStatus, error) {
+
+	// Retrieve the actual nodes:
+	instances, err := d.describeClusterInstances()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []nodeStatus
+	for _, n := range d.reconcileNodes(instances, nil) {
+		if n.HostName != "" && n.AmiID != d.Upgrading.AmiID &&
+			(n.State == "running" || n.State == "pending" || n.State == "stopped") &&
+			upgradePhase(n.Roles) == role {
+			list = append(list, n)
+		}
+	}
+
+	return list, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: upgradePhase
+//-----------------------------------------------------------------------------
+
+func upgradePhase(roles string) string {
+	for _, role := range upgradeOrder {
+		if contains(strings.Split(roles, ","), role) {
+			return role
+		}
+	}
+	return ""
+}
+
+//-----------------------------------------------------------------------------
+// func: upgradeNodes
+//-----------------------------------------------------------------------------
+
+// Replace a batch of nodes with the target AMI. Quorum nodes are only taken
+// down while the rest of the ensemble keeps a majority, and the ensemble has
+// to be whole again before moving on.
+func (d *Data) upgradeNodes(role string, nodes []nodeStatus) error {
+
+	// Same check as zk-alive, without the nodes going down:
+	if role == "quorum" {
+		if !d.zkAlive(d.quorumAddrs(nodes), d.QuorumCount/2+1, 3) {
+			return errors.New("Ops! replacing " + nodes[0].HostName + "-" + nodes[0].HostID +
+				" leaves no ZooKeeper majority")
+		}
+	}
+
+	// Replace the batch, collecting the failures:
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+	for _, n := range nodes {
+		wg.Add(1)
+		go func(n nodeStatus) {
+			defer wg.Done()
+			c := change{Action: "replace", HostName: n.HostName, HostID: n.HostID,
+				Roles: n.Roles, From: n.InstanceType, To: n.InstanceType,
+				AmiID: d.Upgrading.AmiID, pool: d.specPool(n.HostName)}
+			if err := d.replaceNode(c); err != nil {
+				log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": n.HostName + "-" + n.HostID}).
+					Error(err)
+				mu.Lock()
+				failed = append(failed, n.HostName+"-"+n.HostID)
+				mu.Unlock()
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	// Report the failures:
+	if len(failed) > 0 {
+		return errors.New("Ops! failed to replace " + strings.Join(failed, ", ") +
+			", fix it and continue with --resume")
+	}
+
+	// Wait for the whole ensemble:
+	if role == "quorum" {
+		if _, err := d.refreshNodes(); err != nil {
+			return err
+		}
+		if !d.zkAlive(d.quorumAddrs(nil), d.QuorumCount, 10) {
+			return errors.New("Ops! the ZooKeeper ensemble is not whole again, " +
+				"check it and continue with --resume")
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: replaceNode
+//-----------------------------------------------------------------------------
+
+// Replace a node. Quorum nodes leave the etcd cluster first and their
+// replacement joins it again with the same member name and address. The
+// cluster is never reached through the node while it is replaced.
+func (d *Data) replaceNode(c change) error {
+
+	d.leaving.Store(c.HostName+"-"+c.HostID, true)
+	defer d.leaving.Delete(c.HostName + "-" + c.HostID)
+
+	// Not an etcd member:
+	if !contains(strings.Split(c.Roles, ","), "quorum") {
+		if err := d.removeNode(c); err != nil {
+			return err
+		}
+		return d.addNode(c)
+	}
+
+	// The replacement keeps the address of the member:
+	for _, n := range d.Nodes {
+		if n.HostName == c.HostName && n.HostID == c.HostID {
+			c.PrivateIP = n.PrivateIP
+		}
+	}
+	if c.PrivateIP == "" {
+		return errors.New("Ops! no private IP recorded for " + c.HostName + "-" + c.HostID)
+	}
+
+	// Only touch a healthy etcd cluster:
+	if err := d.etcdHealthy(d.QuorumCount); err != nil {
+		return err
+	}
+
+	// Remove the member, if not done by a previous attempt:
+	name := "quorum-" + c.HostID
+	id, err := d.etcdMemberID(name)
+	if err != nil {
+		return err
+	}
+	if id != "" {
+		if _, err := d.etcdctl("member remove " + id); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": name}).
+			Info("Etcd member removed")
+	}
+
+	// Terminate the node:
+	if err := d.removeNode(c); err != nil {
+		return err
+	}
+
+	// Add the member back, the output tells how to join:
+	out, err := d.etcdctl("member add " + name + " " +
+		etcdScheme(d.EtcdTLS) + "://" + c.PrivateIP + ":2380")
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, "ETCD_INITIAL_CLUSTER=") {
+			c.EtcdCluster = strings.Trim(strings.TrimPrefix(line, "ETCD_INITIAL_CLUSTER="), `"`)
+		}
+	}
+	if c.EtcdCluster == "" {
+		return errors.New("Ops! unexpected etcdctl member add output: " + out)
+	}
+	log.WithFields(log.Fields{"cmd": "ec2:" + d.command, "id": name}).
+		Info("Etcd member added")
+
+	// Launch the replacement:
+	if err := d.addNode(c); err != nil {
+		return err
+	}
+
+	// Wait until it has joined:
+	for t := 1; t <= 10; t++ {
+		if err = d.etcdHealthy(d.QuorumCount); err == nil {
+			return nil
+		}
+		time.Sleep(time.Duration(t) * zkRetryDelay)
+	}
+
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: quorumAddrs
+//-----------------------------------------------------------------------------
+
+// ZooKeeper addresses of the quorum nodes, except the given ones.
+func (d *Data) quorumAddrs(except []nodeStatus) (addrs []string) {
+	for _, n := range d.Nodes {
+		skip := !contains(strings.Split(n.Roles, ","), "quorum") || n.PrivateIP == ""
+		for _, e := range except {
+			if n.HostName == e.HostName && n.HostID == e.HostID {
+				skip = true
+			}
+		}
+		if !skip {
+			addrs = append(addrs, net.JoinHostPort(n.PrivateIP, zkPort))
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: specPool
+//-----------------------------------------------------------------------------
+
+// The stored spec pool of a node, if any, so replacements keep their disk
+// size and tags.
+func (d *Data) specPool(name string) NodePool {
+
+	if raw, err := kato.ReadSpec(d.ClusterID); err == nil {
+		if s, err := decodeSpec(raw); err == nil {
+			for _, p := range s.NodePools {
+				if p.Name == name {
+					return p
+				}
+			}
+		}
+	}
+
+	return NodePool{Name: name}
+}
+
+//-----------------------------------------------------------------------------
+// func: zkAlive
+//-----------------------------------------------------------------------------
+
+// Port of /opt/bin/zk-alive: true once at least need ZooKeeper servers
+// answer 'imok' to 'ruok', retried with a growing delay. The servers are
+// asked from a border node, private IPs are not reachable from here.
+func (d *Data) zkAlive(addrs []string, need, tries int) bool {
+
+	for t := 1; t <= tries; t++ {
+
+		// Count the healthy servers:
+		cnt, err := d.zkCount(addrs)
+		if err != nil {
+			log.WithField("cmd", "ec2:"+d.command).Warning(err)
+		}
+		if cnt >= need {
+			return true
+		}
+
+		// Sleep and try again:
+		if t < tries {
+			time.Sleep(time.Duration(t) * zkRetryDelay)
+		}
+	}
+
+	return false
+}
+
+//-----------------------------------------------------------------------------
+// func: zkCount
+//-----------------------------------------------------------------------------
+
+func (d *Data) zkCount(addrs []string) (int, error) {
+
+	// Ask every server:
+	script := "cnt=0\n"
+	for _, addr := range addrs {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return 0, err
+		}
+		script += "echo ruok | ncat -w 2 " + host + " " + port +
+			" 2> /dev/null | grep -q imok && cnt=$((cnt+1))\n"
+	}
+	script += "echo ${cnt}\n"
+
+	out, err := d.onCluster(script)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(out))
+}
+
+//-----------------------------------------------------------------------------
+// func: etcdHealthy
+//-----------------------------------------------------------------------------
+
+// Nil if the etcd cluster is healthy with at least the given members.
+func (d *Data) etcdHealthy(members int) error {
+
+	out, err := d.etcdctl("cluster-health")
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(out, "cluster is healthy") ||
+		strings.Count(out, "is healthy: got healthy result") < members {
+		return errors.New("Ops! the etcd cluster is not healthy:\n" + out)
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: etcdMemberID
+//-----------------------------------------------------------------------------
+
+// The ID of the named etcd member, empty if not a member.
+func (d *Data) etcdMemberID(name string) (string, error) {
+
+	out, err := d.etcdctl("member list")
+	if err != nil {
+		return "", err
+	}
+
+	// 8e9e05c52164694d: name=quorum-1 peerURLs=http://10.0.0.5:2380 ...
+	for _, line := range strings.Split(out, "\n") {
+		f := strings.Fields(line)
+		if len(f) > 1 && f[1] == "name="+name {
+			return strings.TrimSuffix(f[0], ":"), nil
+		}
+	}
+
+	return "", nil
+}
+
+//-----------------------------------------------------------------------------
+// func: etcdctl
+//-----------------------------------------------------------------------------
+
+// Run etcdctl from a border node against the quorum members.
+func (d *Data) etcdctl(args string) (string, error) {
+
+	nodes, err := d.latestNodes()
+	if err != nil {
+		return "", err
+	}
+
+	var endpoints []string
+	for _, n := range nodes {
+		if contains(strings.Split(n.Roles, ","), "quorum") && n.PrivateIP != "" {
+			endpoints = append(endpoints, etcdScheme(d.EtcdTLS)+"://"+n.PrivateIP+":2379")
+		}
+	}
+
+	return d.onCluster("source /etc/kato.env\n" +
+		"export ETCDCTL_CA_FILE ETCDCTL_CERT_FILE ETCDCTL_KEY_FILE\n" +
+		"etcdctl --endpoints " + strings.Join(endpoints, ",") + " " + args + "\n")
+}
+
+//-----------------------------------------------------------------------------
+// func: etcdScheme
+//-----------------------------------------------------------------------------
+
+func etcdScheme(tls bool) string {
+	if tls {
+		return "https"
+	}
+	return "http"
+}
+
+//-----------------------------------------------------------------------------
+// func: onCluster
+//-----------------------------------------------------------------------------
+
+// Run a script on a border node, the only ones reachable over SSH. Nodes are
+// read again from the state file as others replace them meanwhile, and the
+// ones being replaced are left out.
+func (d *Data) onCluster(script string) (string, error) {
+
+	nodes, err := d.latestNodes()
+	if err != nil {
+		return "", err
+	}
+
+	// The first border node staying up:
+	borders := 0
+	for _, n := range nodes {
+		if !contains(strings.Split(n.Roles, ","), "border") || n.PublicIP == "" {
+			continue
+		}
+		if borders++; d.isLeaving(n.HostName + "-" + n.HostID) {
+			continue
+		}
+		out, err := onNode(n.PublicIP, script)
+		return string(out), err
+	}
+
+	if borders > 0 {
+		return "", errors.New("Ops! every border node is being replaced, none left to reach the cluster over SSH")
+	}
+	return "", errNoBorder
+}
+
+//-----------------------------------------------------------------------------
+// func: requireBorder
+//-----------------------------------------------------------------------------
+
+// Quorum health checks run from a border node, refuse to start without one.
+func (d *Data) requireBorder() error {
+	for _, n := range d.Nodes {
+		if contains(strings.Split(n.Roles, ","), "border") && n.PublicIP != "" {
+			return nil
+		}
+	}
+	return errNoBorder
+}
+
+//-----------------------------------------------------------------------------
+// func: latestNodes
+//-----------------------------------------------------------------------------
+
+// The nodes recorded in the state file right now.
+func (d *Data) latestNodes() ([]Node, error) {
+	raw, err := kato.ReadState(d.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+	s, err := decodeState(raw)
+	return s.Nodes, err
+}
+
+//-----------------------------------------------------------------------------
+// func: isLeaving
+//-----------------------------------------------------------------------------
+
+// True while the named node is being replaced.
+func (d *Data) isLeaving(name string) bool {
+	if d.leaving == nil {
+		return false
+	}
+	_, ok := d.leaving.Load(name)
+	return ok
+}
+
+//-----------------------------------------------------------------------------
+// func: sshScript
+//-----------------------------------------------------------------------------
+
+// Run a script as root over SSH, with the keys of the local agent. The host
+// key has to be in the known_hosts file of the user.
+func sshScript(host, script string) ([]byte, error) {
+
+	cmd := exec.Command("ssh",
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=10",
+		"-o", "LogLevel=ERROR",
+		"core@"+host, "sudo", "/bin/bash", "-s")
+	cmd.Stdin = strings.NewReader(script)
+
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok {
+		return out, errors.New("Ops! ssh core@" + host + ": " +
+			strings.TrimSpace(string(out)+string(ee.Stderr)))
+	}
+
+	return out, err
+}
@@ -0,0 +1,81 @@
+package etcd
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"os"
+	"path/filepath"
+
+	// Local:
+	"github.com/katosys/kato/pkg/cli"
+)
+
+//-----------------------------------------------------------------------------
+// 'katoctl etcd' command flags definitions:
+//-----------------------------------------------------------------------------
+
+var (
+
+	//-------------------------
+	// etcd: top level command
+	//-------------------------
+
+	cmdEtcd = cli.App.Command("etcd", "Helpers to bootstrap etcd clusters.")
+
+	//---------------------------
+	// etcd discovery: subcommand
+	//---------------------------
+
+	cmdEtcdDiscovery = cmdEtcd.Command("discovery",
+		"Serve the etcd discovery protocol for clusters without internet access.")
+
+	flEtcdDiscoveryListen = cmdEtcdDiscovery.Flag("listen",
+		"Address to listen on.").
+		Default(":8087").PlaceHolder("KATO_ETCD_DISCOVERY_LISTEN").
+		OverrideDefaultFromEnvar("KATO_ETCD_DISCOVERY_LISTEN").
+		String()
+
+	flEtcdDiscoveryURL = cli.RegexpMatch(cmdEtcdDiscovery.Flag("url",
+		"Base URL handed out to the nodes, defaults to http://<request host>").
+		PlaceHolder("KATO_ETCD_DISCOVERY_URL").
+		OverrideDefaultFromEnvar("KATO_ETCD_DISCOVERY_URL"), "^https?://[^/]+$")
+
+	flEtcdDiscoveryDataDir = cmdEtcdDiscovery.Flag("data-dir",
+		"Directory to keep the clusters in across restarts, defaults to ~/.kato/discovery").
+		PlaceHolder("KATO_ETCD_DISCOVERY_DATA_DIR").
+		OverrideDefaultFromEnvar("KATO_ETCD_DISCOVERY_DATA_DIR").
+		String()
+)
+
+//-----------------------------------------------------------------------------
+// RunCmd:
+//-----------------------------------------------------------------------------
+
+// RunCmd runs the cmd if owned by this package.
+func RunCmd(cmd string) bool {
+
+	switch cmd {
+
+	// katoctl etcd discovery:
+	case cmdEtcdDiscovery.FullCommand():
+		if *flEtcdDiscoveryDataDir == "" {
+			*flEtcdDiscoveryDataDir = filepath.Join(os.Getenv("HOME"), ".kato", "discovery")
+		}
+		d := Data{
+			Listen:  *flEtcdDiscoveryListen,
+			URL:     *flEtcdDiscoveryURL,
+			DataDir: *flEtcdDiscoveryDataDir,
+		}
+		d.Discovery()
+
+	// Nothing to do:
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,417 @@
+package etcd
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Data struct for the etcd helpers.
+type Data struct {
+	command string
+	Listen  string
+	URL     string
+	DataDir string
+}
+
+// Discovery registry. It speaks the subset of the etcd v2 keys API used by
+// etcd members and proxies to discover each other. With a data directory it
+// is written to registry.json on every change and read back on start.
+type registry struct {
+	sync.Mutex
+	url      string
+	file     string
+	index    uint64
+	clusters map[string]*cluster
+	changed  chan struct{}
+}
+
+// Discovery cluster, one per token.
+type cluster struct {
+	Size    node   `json:"Size"`
+	Members []node `json:"Members"`
+	Created uint64 `json:"Created"`
+}
+
+// Registry as written to disk.
+type snapshot struct {
+	Index    uint64              `json:"Index"`
+	Clusters map[string]*cluster `json:"Clusters"`
+}
+
+// Node of the etcd v2 keys API.
+type node struct {
+	Key           string `json:"key"`
+	Value         string `json:"value,omitempty"`
+	Dir           bool   `json:"dir,omitempty"`
+	Nodes         []node `json:"nodes,omitempty"`
+	CreatedIndex  uint64 `json:"createdIndex,omitempty"`
+	ModifiedIndex uint64 `json:"modifiedIndex,omitempty"`
+}
+
+// Response of the etcd v2 keys API.
+type response struct {
+	Action string `json:"action"`
+	Node   node   `json:"node"`
+}
+
+// Error of the etcd v2 keys API.
+type apiError struct {
+	ErrorCode int    `json:"errorCode"`
+	Message   string `json:"message"`
+	Cause     string `json:"cause"`
+	Index     uint64 `json:"index"`
+}
+
+//-----------------------------------------------------------------------------
+// func: Discovery
+//-----------------------------------------------------------------------------
+
+// Discovery serves the etcd discovery protocol until killed. Clusters are kept
+// in the data directory: etcd proxies of nodes added at any time later read
+// the members of their cluster from its discovery URL.
+func (d *Data) Discovery() {
+
+	// Set the current command:
+	d.command = "discovery"
+
+	// Load the registry:
+	r, err := newRegistry(d.URL, d.DataDir)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "etcd:" + d.command, "id": d.DataDir}).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "etcd:" + d.command, "id": d.Listen}).
+		Info("Serving etcd discovery, request clusters at <url>/new?size=<n>")
+
+	// Serve:
+	if err := http.ListenAndServe(d.Listen, r); err != nil {
+		log.WithField("cmd", "etcd:"+d.command).Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: newRegistry
+//-----------------------------------------------------------------------------
+
+// New registry, read back from dataDir if given and already written.
+func newRegistry(url, dataDir string) (*registry, error) {
+
+	r := &registry{
+		url:      strings.TrimSuffix(url, "/"),
+		clusters: map[string]*cluster{},
+		changed:  make(chan struct{}),
+	}
+
+	// Memory only:
+	if dataDir == "" {
+		return r, nil
+	}
+
+	// Read it back:
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return nil, err
+	}
+	r.file = filepath.Join(dataDir, "registry.json")
+	data, err := ioutil.ReadFile(r.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r, nil
+		}
+		return nil, err
+	}
+	snap := snapshot{Clusters: r.clusters}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, err
+	}
+	r.index = snap.Index
+
+	return r, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: save
+//-----------------------------------------------------------------------------
+
+// Write the registry to the data directory, if any, called with the lock
+// held. The file is replaced at once so a crash never leaves half of it.
+func (r *registry) save() error {
+
+	if r.file == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(snapshot{Index: r.index, Clusters: r.clusters})
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(r.file+".tmp", data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(r.file+".tmp", r.file)
+}
+
+//-----------------------------------------------------------------------------
+// func: ServeHTTP
+//-----------------------------------------------------------------------------
+
+// Routes:
+//
+//	GET /new?size=<n>                    new cluster, replies its URL
+//	GET /<token>/_config/size            expected cluster size
+//	PUT /<token>/<id>                    register a member
+//	GET /<token>[?wait=true&waitIndex=]  list members or wait for the next
+func (r *registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "new":
+		r.newCluster(w, req)
+	case len(parts) == 1 && parts[0] != "" && req.Method == http.MethodGet:
+		r.getCluster(w, req, parts[0])
+	case len(parts) == 2 && req.Method == http.MethodPut:
+		r.putMember(w, req, parts[0], parts[1])
+	case len(parts) == 3 && parts[1] == "_config" && parts[2] == "size" &&
+		req.Method == http.MethodGet:
+		r.getSize(w, parts[0])
+	default:
+		r.Lock()
+		defer r.Unlock()
+		r.writeError(w, http.StatusNotFound, 100, "Key not found", req.URL.Path)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: newCluster
+//-----------------------------------------------------------------------------
+
+func (r *registry) newCluster(w http.ResponseWriter, req *http.Request) {
+
+	// Expected cluster size:
+	size := 3
+	if s := req.FormValue("size"); s != "" {
+		var err error
+		if size, err = strconv.Atoi(s); err != nil || size < 1 {
+			http.Error(w, "size must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Random token:
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	// Register the cluster:
+	r.Lock()
+	r.index++
+	r.clusters[token] = &cluster{
+		Size: node{Key: "/" + token + "/_config/size", Value: strconv.Itoa(size),
+			CreatedIndex: r.index, ModifiedIndex: r.index},
+		Created: r.index,
+	}
+	if err := r.save(); err != nil {
+		delete(r.clusters, token)
+		r.Unlock()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	r.Unlock()
+
+	// Reply the discovery URL:
+	url := r.url
+	if url == "" {
+		url = "http://" + req.Host
+	}
+
+	log.WithFields(log.Fields{"cmd": "etcd:discovery", "id": token}).
+		Info("New cluster of size " + strconv.Itoa(size))
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(url + "/" + token))
+}
+
+//-----------------------------------------------------------------------------
+// func: getSize
+//-----------------------------------------------------------------------------
+
+func (r *registry) getSize(w http.ResponseWriter, token string) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	c, ok := r.clusters[token]
+	if !ok {
+		r.writeError(w, http.StatusNotFound, 100, "Key not found", "/"+token+"/_config/size")
+		return
+	}
+
+	r.writeNode(w, http.StatusOK, "get", c.Size)
+}
+
+//-----------------------------------------------------------------------------
+// func: putMember
+//-----------------------------------------------------------------------------
+
+func (r *registry) putMember(w http.ResponseWriter, req *http.Request, token, id string) {
+
+	r.Lock()
+	defer r.Unlock()
+
+	key := "/" + token + "/" + id
+	c, ok := r.clusters[token]
+	if !ok || id == "_config" {
+		r.writeError(w, http.StatusNotFound, 100, "Key not found", key)
+		return
+	}
+
+	// Members register once:
+	for i, m := range c.Members {
+		if m.Key == key {
+			if req.FormValue("prevExist") == "false" {
+				r.writeError(w, http.StatusPreconditionFailed, 105, "Key already exists", key)
+				return
+			}
+			r.index++
+			c.Members[i].Value, c.Members[i].ModifiedIndex = req.FormValue("value"), r.index
+			r.notify()
+			if err := r.save(); err != nil {
+				r.writeError(w, http.StatusInternalServerError, 300, "Raft Internal Error", err.Error())
+				return
+			}
+			r.writeNode(w, http.StatusOK, "set", c.Members[i])
+			return
+		}
+	}
+
+	r.index++
+	m := node{Key: key, Value: req.FormValue("value"), CreatedIndex: r.index, ModifiedIndex: r.index}
+	c.Members = append(c.Members, m)
+	r.notify()
+	if err := r.save(); err != nil {
+		r.writeError(w, http.StatusInternalServerError, 300, "Raft Internal Error", err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{"cmd": "etcd:discovery", "id": token}).
+		Info("Member " + m.Value + " registered")
+
+	r.writeNode(w, http.StatusCreated, "create", m)
+}
+
+//-----------------------------------------------------------------------------
+// func: getCluster
+//-----------------------------------------------------------------------------
+
+func (r *registry) getCluster(w http.ResponseWriter, req *http.Request, token string) {
+
+	// Watches start at the given index or at the next change:
+	waitIndex := uint64(0)
+	wait := req.FormValue("wait") == "true"
+	if s := req.FormValue("waitIndex"); s != "" {
+		waitIndex, _ = strconv.ParseUint(s, 10, 64)
+	}
+
+	r.Lock()
+	if waitIndex == 0 {
+		waitIndex = r.index + 1
+	}
+
+	for {
+
+		c, ok := r.clusters[token]
+		if !ok {
+			r.writeError(w, http.StatusNotFound, 100, "Key not found", "/"+token)
+			r.Unlock()
+			return
+		}
+
+		// List the members:
+		if !wait {
+			dir := node{Key: "/" + token, Dir: true, Nodes: c.Members,
+				CreatedIndex: c.Created, ModifiedIndex: c.Created}
+			r.writeNode(w, http.StatusOK, "get", dir)
+			r.Unlock()
+			return
+		}
+
+		// Reply the first change at or after waitIndex:
+		for _, m := range c.Members {
+			if m.ModifiedIndex >= waitIndex {
+				r.writeNode(w, http.StatusOK, "create", m)
+				r.Unlock()
+				return
+			}
+		}
+
+		// Wait for a change or for the client to leave:
+		changed := r.changed
+		r.Unlock()
+		select {
+		case <-changed:
+		case <-req.Context().Done():
+			return
+		}
+		r.Lock()
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: notify
+//-----------------------------------------------------------------------------
+
+// Wake up the watchers, called with the lock held.
+func (r *registry) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+//-----------------------------------------------------------------------------
+// func: writeNode
+//-----------------------------------------------------------------------------
+
+// Called with the lock held.
+func (r *registry) writeNode(w http.ResponseWriter, status int, action string, n node) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Etcd-Index", strconv.FormatUint(r.index, 10))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response{Action: action, Node: n})
+}
+
+//-----------------------------------------------------------------------------
+// func: writeError
+//-----------------------------------------------------------------------------
+
+// Called with the lock held.
+func (r *registry) writeError(w http.ResponseWriter, status, code int, message, cause string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Etcd-Index", strconv.FormatUint(r.index, 10))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{ErrorCode: code, Message: message,
+		Cause: cause, Index: r.index})
+}
@@ -0,0 +1,159 @@
+package etcd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katosys/kato/pkg/kato"
+)
+
+func get(t *testing.T, u string, v interface{}) int {
+	res, err := http.Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode
+}
+
+func put(t *testing.T, u, value string) int {
+	req, _ := http.NewRequest("PUT", u, strings.NewReader(url.Values{"value": {value}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestDiscovery(t *testing.T) {
+
+	reg, err := newRegistry("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(reg)
+	defer srv.Close()
+
+	// Request a cluster the way deploy does:
+	wch := kato.NewWaitChan(1)
+	discovery := srv.URL + "/new"
+	go kato.NewEtcdDiscovery(wch, 2, "auto", &discovery)
+	if err := wch.WaitErr(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(discovery, srv.URL+"/") || len(discovery) != len(srv.URL)+33 {
+		t.Fatalf("unexpected discovery URL: %s", discovery)
+	}
+
+	var r response
+	if code := get(t, discovery+"/_config/size", &r); code != 200 || r.Node.Value != "2" {
+		t.Fatalf("unexpected size: %d %+v", code, r)
+	}
+
+	// Members register once:
+	if code := put(t, discovery+"/a1?prevExist=false", "quorum-1=http://10.0.0.5:2380"); code != 201 {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	if code := put(t, discovery+"/a1?prevExist=false", "quorum-1=http://10.0.0.5:2380"); code != 412 {
+		t.Fatalf("expected a conflict, got: %d", code)
+	}
+
+	// Watchers wake up on the next member:
+	done := make(chan response)
+	go func() {
+		var w response
+		get(t, discovery+"?wait=true&recursive=true&waitIndex=3", &w)
+		done <- w
+	}()
+	time.Sleep(50 * time.Millisecond)
+	put(t, discovery+"/b2?prevExist=false", "quorum-2=http://10.0.1.6:2380")
+	select {
+	case w := <-done:
+		if w.Node.Value != "quorum-2=http://10.0.1.6:2380" {
+			t.Errorf("unexpected watch: %+v", w)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("watch never returned")
+	}
+
+	if get(t, discovery+"?recursive=true", &r); len(r.Node.Nodes) != 2 || !r.Node.Dir {
+		t.Errorf("unexpected members: %+v", r)
+	}
+
+	var e apiError
+	if code := get(t, srv.URL+"/nope/_config/size", &e); code != 404 || e.ErrorCode != 100 {
+		t.Errorf("unexpected error: %d %+v", code, e)
+	}
+
+	// Static and given URLs are left alone, public tokens are expanded:
+	for _, c := range []struct{ source, token, expected string }{
+		{"static", "auto", "static"},
+		{"https://disco.example.com/abc", "auto", "https://disco.example.com/abc"},
+		{"public", "abc123", "https://discovery.etcd.io/abc123"},
+	} {
+		wch := kato.NewWaitChan(1)
+		s := c.source
+		go kato.NewEtcdDiscovery(wch, 3, c.token, &s)
+		if err := wch.WaitErr(); err != nil || s != c.expected {
+			t.Errorf("%s: got %s, %v", c.source, s, err)
+		}
+	}
+}
+
+func TestDiscoveryRestart(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Bootstrap a cluster:
+	r, err := newRegistry("http://disco", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(r)
+	res, err := http.Get(srv.URL + "/new?size=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	token := strings.TrimPrefix(string(raw), "http://disco/")
+	if code := put(t, srv.URL+"/"+token+"/a1?prevExist=false", "quorum-1=http://10.0.0.5:2380"); code != 201 {
+		t.Fatalf("unexpected status: %d", code)
+	}
+	srv.Close()
+
+	// Proxies of nodes added after a restart still find the members:
+	if r, err = newRegistry("http://disco", dir); err != nil {
+		t.Fatal(err)
+	}
+	srv = httptest.NewServer(r)
+	defer srv.Close()
+
+	var resp response
+	if code := get(t, srv.URL+"/"+token+"?recursive=true", &resp); code != 200 ||
+		len(resp.Node.Nodes) != 1 || resp.Node.Nodes[0].Value != "quorum-1=http://10.0.0.5:2380" {
+		t.Errorf("unexpected members: %d %+v", code, resp)
+	}
+	if code := put(t, srv.URL+"/"+token+"/b2?prevExist=false", "quorum-2=http://10.0.1.6:2380"); code != 201 {
+		t.Errorf("unexpected status: %d", code)
+	}
+	if len(r.clusters[token].Members) != 2 || r.index != 3 {
+		t.Errorf("expected the index to carry on, got %d", r.index)
+	}
+}
@@ -18,6 +18,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+
+	// Community:
+	"github.com/ajeddeloh/yaml"
+
+	// Local:
+	"github.com/katosys/kato/pkg/dns"
+	"github.com/katosys/kato/pkg/state"
 )
 
 //-----------------------------------------------------------------------------
@@ -62,7 +69,8 @@ func (wch *WaitChan) WaitErr() error {
 // func: DumpState
 //-----------------------------------------------------------------------------
 
-// DumpState serializes the given state as a clusterID JSON file.
+// DumpState serializes the given state as a clusterID JSON object in the
+// state backend.
 func DumpState(s interface{}, clusterID string) error {
 
 	// Marshal the data:
@@ -71,41 +79,152 @@ func DumpState(s interface{}, clusterID string) error {
 		return err
 	}
 
-	// Create the state directory:
-	path := os.Getenv("HOME") + "/.kato"
-	if _, err := os.Stat(path); err != nil {
-		if os.IsNotExist(err) {
-			err = os.Mkdir(path, 0700)
-			if err != nil {
-				return err
-			}
-		}
+	return putState(clusterID+".json", data)
+}
+
+//-----------------------------------------------------------------------------
+// func: DumpSpec
+//-----------------------------------------------------------------------------
+
+// DumpSpec serializes the given cluster spec as a clusterID YAML object next
+// to the state.
+func DumpSpec(s interface{}, clusterID string) error {
+
+	// Marshal the data:
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
 	}
 
-	// Write the state file:
-	err = ioutil.WriteFile(path+"/"+clusterID+".json", data, 0600)
+	return putState(clusterID+".spec.yaml", data)
+}
+
+//-----------------------------------------------------------------------------
+// func: ReadSpec
+//-----------------------------------------------------------------------------
+
+// ReadSpec reads the cluster spec stored by DumpSpec.
+func ReadSpec(clusterID string) ([]byte, error) {
+	return getState(clusterID + ".spec.yaml")
+}
+
+//-----------------------------------------------------------------------------
+// func: LockState
+//-----------------------------------------------------------------------------
+
+// LockState waits for an exclusive lock on the clusterID state. Callers read,
+// modify and dump the state and then call UnlockState.
+func LockState(clusterID string) (*state.Lock, error) {
+
+	// Open the state backend:
+	b, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	// Acquire the lock:
+	return state.AcquireLock(b, clusterID+".json", operation())
+}
+
+//-----------------------------------------------------------------------------
+// func: UnlockState
+//-----------------------------------------------------------------------------
+
+// UnlockState releases a lock acquired with LockState.
+func UnlockState(l *state.Lock) error {
+
+	// Open the state backend:
+	b, err := state.Current()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// Release the lock:
+	return state.Release(b, l)
 }
 
 //-----------------------------------------------------------------------------
 // func: ReadState
 //-----------------------------------------------------------------------------
 
-// ReadState reads the current ClusterID state file.
+// ReadState reads the current ClusterID state.
 func ReadState(clusterID string) ([]byte, error) {
+	return getState(clusterID + ".json")
+}
+
+//-----------------------------------------------------------------------------
+// func: DeleteState
+//-----------------------------------------------------------------------------
 
-	// Read data from state file:
-	stateFile := os.Getenv("HOME") + "/.kato/" + clusterID + ".json"
-	raw, err := ioutil.ReadFile(stateFile)
+// DeleteState removes the ClusterID state, spec, secrets and PKI (if any) and
+// the local secrets key.
+func DeleteState(clusterID string) error {
+
+	// Open the state backend:
+	b, err := state.Current()
+	if err != nil {
+		return err
+	}
+
+	// Remove the state, spec, secrets, PKI and locks:
+	for _, ext := range append(append([]string{}, state.Objects...), ".json.lock", ".lock") {
+		if err := b.Delete(clusterID + ext); err != nil {
+			return err
+		}
+	}
+
+	// Remove the secrets key:
+	return state.Local().Delete(clusterID + ".key")
+}
+
+//-----------------------------------------------------------------------------
+// func: getState
+//-----------------------------------------------------------------------------
+
+func getState(name string) ([]byte, error) {
+
+	// Open the state backend:
+	b, err := state.Current()
 	if err != nil {
 		return nil, err
 	}
 
-	return raw, nil
+	// Read the object:
+	data, _, err := b.Get(name)
+	return data, err
+}
+
+//-----------------------------------------------------------------------------
+// func: putState
+//-----------------------------------------------------------------------------
+
+func putState(name string, data []byte) error {
+
+	// Open the state backend:
+	b, err := state.Current()
+	if err != nil {
+		return err
+	}
+
+	// Write the object:
+	_, err = b.Put(name, data, "")
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: operation
+//-----------------------------------------------------------------------------
+
+// The katoctl command holding a lock, flags left out as they carry secrets.
+func operation() string {
+	op := "katoctl"
+	for _, arg := range os.Args[1:] {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		op += " " + arg
+	}
+	return op
 }
 
 //-----------------------------------------------------------------------------
@@ -134,21 +253,61 @@ func CountNodes(quads []string, role string) (count int) {
 // func: CreateDNSZones
 //-----------------------------------------------------------------------------
 
-// CreateDNSZones creates (int|ext).<domain> zones using <provider>.
-func CreateDNSZones(wch *WaitChan, provider, apiKey, domain string) {
+// CreateDNSZones creates (int|ext).<domain> zones using <provider>. The int
+// zone is private to <network> if given and supported by the provider.
+func CreateDNSZones(wch *WaitChan, provider, apiKey, domain, network, region string) {
 
 	// Decrement:
 	defer wch.WaitGrp.Done()
 
-	// Forge the zone command:
-	cmd := exec.Command("katoctl", provider,
-		"--api-key", apiKey, "zone", "add",
-		domain, "int."+domain, "ext."+domain)
+	// Get the DNS provider:
+	p, err := dns.New(provider, apiKey)
+	if err != nil {
+		wch.ErrChan <- err
+		return
+	}
+
+	// Add the zones (parent first):
+	for _, zone := range []string{domain, "int." + domain, "ext." + domain} {
 
-	// Execute the zone command:
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+		// Private internal zone:
+		if pp, ok := p.(dns.PrivateProvider); ok &&
+			network != "" && zone == "int."+domain {
+			err = pp.AddPrivateZone(zone, network, region)
+		} else {
+			err = p.AddZone(zone)
+		}
+
+		if err != nil {
+			wch.ErrChan <- err
+			return
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: DeleteDNSZones
+//-----------------------------------------------------------------------------
+
+// DeleteDNSZones deletes the zones created by CreateDNSZones using <provider>.
+func DeleteDNSZones(wch *WaitChan, provider, apiKey, domain string) {
+
+	// Decrement:
+	defer wch.WaitGrp.Done()
+
+	// Get the DNS provider:
+	p, err := dns.New(provider, apiKey)
+	if err != nil {
 		wch.ErrChan <- err
+		return
+	}
+
+	// Delete the zones (children first):
+	for _, zone := range []string{"int." + domain, "ext." + domain, domain} {
+		if err := p.DelZone(zone); err != nil {
+			wch.ErrChan <- err
+			return
+		}
 	}
 }
 
@@ -201,52 +360,84 @@ func ExecutePipeline(cmd1, cmd2 *exec.Cmd) ([]byte, error) {
 }
 
 //-----------------------------------------------------------------------------
-// func: NewEtcdToken
+// func: NewEtcdDiscovery
 //-----------------------------------------------------------------------------
 
-// NewEtcdToken takes quorumCount and returns a valid etcd bootstrap token:
-func NewEtcdToken(wch *WaitChan, quorumCount int, token *string) {
+// EtcdPublicDiscovery is the public etcd discovery service:
+const EtcdPublicDiscovery = "https://discovery.etcd.io/"
+
+// NewEtcdDiscovery resolves the etcd discovery source of a new cluster into
+// the discovery URL its nodes bootstrap from. The source is one of:
+//
+//	public      discovery.etcd.io, with the given token or a new one
+//	static      no discovery, members are known in advance
+//	<url>/new   a discovery service, a new URL is requested from it
+//	<url>       a discovery URL created beforehand
+func NewEtcdDiscovery(wch *WaitChan, quorumCount int, token string, source *string) {
 
 	// Decrement:
 	defer wch.WaitGrp.Done()
 
-	// Send the request:
-	const etcdIO = "https://discovery.etcd.io/"
-	res, err := http.Get(etcdIO + "new?size=" + strconv.Itoa(quorumCount))
+	var err error
+
+	switch {
+
+	// Nothing to discover:
+	case *source == "static":
+		return
+
+	// Public service:
+	case *source == "public" || *source == "":
+		if token != "" && token != "auto" {
+			*source = EtcdPublicDiscovery + token
+			return
+		}
+		*source, err = newDiscoveryURL(EtcdPublicDiscovery+"new", quorumCount)
+
+	// Private service:
+	case strings.HasSuffix(*source, "/new"):
+		*source, err = newDiscoveryURL(*source, quorumCount)
+	}
+
 	if err != nil {
 		wch.ErrChan <- err
-		return
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: newDiscoveryURL
+//-----------------------------------------------------------------------------
+
+// Request a new cluster of the given size to a discovery service.
+func newDiscoveryURL(service string, quorumCount int) (string, error) {
+
+	// Send the request:
+	res, err := http.Get(service + "?size=" + strconv.Itoa(quorumCount))
+	if err != nil {
+		return "", err
 	}
 
 	// Get the response body:
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		wch.ErrChan <- err
-		return
+		return "", err
 	}
 
 	// Call the close method:
 	if err := res.Body.Close(); err != nil {
-		wch.ErrChan <- err
-		return
-	}
-
-	// Test whether pattern matches string:
-	match, err := regexp.MatchString(etcdIO+"([a-z,0-9]+$)", string(body))
-	if err != nil {
-		wch.ErrChan <- err
-		return
+		return "", err
 	}
 
 	// Return if invalid:
-	if !match {
-		wch.ErrChan <- errors.New("Invalid etcd token retrieved")
-		return
+	discovery := strings.TrimSpace(string(body))
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return "", errors.New("Ops! " + service + " returned " + res.Status)
+	}
+	if match, _ := regexp.MatchString("^https?://.+/[a-z0-9]+$", discovery); !match {
+		return "", errors.New("Invalid etcd discovery URL retrieved: " + discovery)
 	}
 
-	// Return the token ID:
-	slice := strings.Split(string(body), "/")
-	*token = slice[len(slice)-1]
+	return discovery, nil
 }
 
 //-----------------------------------------------------------------------------
@@ -0,0 +1,232 @@
+package kato
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+
+	// Community:
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+
+	// Local:
+	"github.com/katosys/kato/pkg/state"
+)
+
+//-----------------------------------------------------------------------------
+// func: DumpSecrets
+//-----------------------------------------------------------------------------
+
+// DumpSecrets encrypts the given secrets as a clusterID secrets object next
+// to the state. The AES-256-GCM key is read from KATO_SECRETS_KEY or from the
+// local clusterID key file, created on first use.
+func DumpSecrets(s interface{}, clusterID string) error {
+
+	// Marshal the data:
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt it:
+	key, err := secretsKey(clusterID, true)
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(key, data, clusterID)
+	if err != nil {
+		return err
+	}
+
+	return putState(clusterID+".secrets", sealed)
+}
+
+//-----------------------------------------------------------------------------
+// func: ReadSecrets
+//-----------------------------------------------------------------------------
+
+// ReadSecrets decrypts the clusterID secrets object into s. Clusters without
+// secrets leave s untouched.
+func ReadSecrets(clusterID string, s interface{}) error {
+
+	// Read the secrets (if any):
+	sealed, err := getState(clusterID + ".secrets")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	// Decrypt it:
+	key, err := secretsKey(clusterID, false)
+	if err != nil {
+		return err
+	}
+	data, err := open(key, sealed, clusterID)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, s)
+}
+
+//-----------------------------------------------------------------------------
+// func: EncryptSecret
+//-----------------------------------------------------------------------------
+
+// EncryptSecret encrypts data with the secrets key of the cluster, bound to
+// the name of the object it is stored as.
+func EncryptSecret(clusterID, name string, data []byte) ([]byte, error) {
+	key, err := secretsKey(clusterID, true)
+	if err != nil {
+		return nil, err
+	}
+	return seal(key, data, name)
+}
+
+//-----------------------------------------------------------------------------
+// func: DecryptSecret
+//-----------------------------------------------------------------------------
+
+// DecryptSecret decrypts data encrypted with EncryptSecret.
+func DecryptSecret(clusterID, name string, sealed []byte) ([]byte, error) {
+	key, err := secretsKey(clusterID, false)
+	if err != nil {
+		return nil, err
+	}
+	return open(key, sealed, name)
+}
+
+//-----------------------------------------------------------------------------
+// func: SealKMS
+//-----------------------------------------------------------------------------
+
+// SealKMS encrypts data with the given KMS key and returns the base64 encoded
+// ciphertext, which only principals allowed to kms:Decrypt can read.
+func SealKMS(region, keyID string, data []byte) (string, error) {
+
+	// Connect and authenticate to the API endpoint:
+	svc := kms.New(session.New(&aws.Config{Region: aws.String(region)}))
+
+	// Send the encryption request:
+	out, err := svc.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(keyID),
+		Plaintext: data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(out.CiphertextBlob), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: secretsKey
+//-----------------------------------------------------------------------------
+
+func secretsKey(clusterID string, create bool) ([]byte, error) {
+
+	// Key from the environment:
+	if env := os.Getenv("KATO_SECRETS_KEY"); env != "" {
+		key, err := base64.StdEncoding.DecodeString(env)
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("Ops! KATO_SECRETS_KEY must be 32 base64 encoded bytes")
+		}
+		return key, nil
+	}
+
+	// Key from the key file, it never leaves this host:
+	keyFile := clusterID + ".key"
+	if data, _, err := state.Local().Get(keyFile); err == nil {
+		key, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil || len(key) != 32 {
+			return nil, errors.New("Ops! " + keyFile + " is not a valid secrets key")
+		}
+		return key, nil
+	} else if !os.IsNotExist(err) || !create {
+		return nil, errors.New("Ops! can't read the secrets key: " + err.Error())
+	}
+
+	// New key, unless someone else was faster:
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if _, err := state.Local().Put(keyFile,
+		[]byte(base64.StdEncoding.EncodeToString(key)), state.Absent); err != nil {
+		if err == state.ErrConflict {
+			return secretsKey(clusterID, false)
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: seal
+//-----------------------------------------------------------------------------
+
+// Encrypt and authenticate data bound to the given label, the nonce goes
+// first.
+func seal(key, data []byte, label string) ([]byte, error) {
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, data, []byte(label)), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: open
+//-----------------------------------------------------------------------------
+
+func open(key, sealed []byte, label string) ([]byte, error) {
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("Ops! the secrets file is truncated")
+	}
+
+	data, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(label))
+	if err != nil {
+		return nil, errors.New("Ops! can't decrypt the secrets, wrong key?")
+	}
+
+	return data, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: newGCM
+//-----------------------------------------------------------------------------
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1 +1,128 @@
 package kato
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/katosys/kato/pkg/dns"
+)
+
+func TestLockState(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	if err := DumpState(map[string]int{"n": 0}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Parallel read-modify-write cycles must not lose updates:
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := LockState("test")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer UnlockState(lock)
+			raw, err := ReadState("test")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			s := map[string]int{}
+			if err := json.Unmarshal(raw, &s); err != nil {
+				t.Error(err)
+				return
+			}
+			s["n"]++
+			if err := DumpState(s, "test"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	raw, err := ReadState("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := map[string]int{}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s["n"] != 20 {
+		t.Fatalf("expected 20 updates, got %d", s["n"])
+	}
+}
+
+func TestSecrets(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	// No secrets yet:
+	s := map[string]string{}
+	if err := ReadSecrets("test", &s); err != nil || len(s) != 0 {
+		t.Fatalf("expected no secrets, got %v (%v)", s, err)
+	}
+
+	// Round trip:
+	if err := DumpSecrets(map[string]string{"DNSApiKey": "s3cr3t"}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(home + "/.kato/test.secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "s3cr3t") {
+		t.Error("secrets stored in clear")
+	}
+	if err := ReadSecrets("test", &s); err != nil || s["DNSApiKey"] != "s3cr3t" {
+		t.Fatalf("expected the secrets back, got %v (%v)", s, err)
+	}
+
+	// Wrong key:
+	defer os.Unsetenv("KATO_SECRETS_KEY")
+	os.Setenv("KATO_SECRETS_KEY", "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err := ReadSecrets("test", &s); err == nil {
+		t.Error("expected an error with the wrong key")
+	}
+}
+
+func TestCreateDNSZones(t *testing.T) {
+
+	f := dns.NewFake()
+	dns.Register("fake", func(string) dns.Provider { return f })
+
+	wch := NewWaitChan(1)
+	go CreateDNSZones(wch, "fake", "", "example.com", "vpc-1", "eu-west-1")
+	if err := wch.WaitErr(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Zones) != 3 {
+		t.Errorf("unexpected zones: %v", f.Zones)
+	}
+	if !reflect.DeepEqual(f.Private, map[string]string{"int.example.com": "vpc-1/eu-west-1"}) {
+		t.Errorf("unexpected private zones: %v", f.Private)
+	}
+}
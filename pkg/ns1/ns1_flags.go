@@ -36,6 +36,21 @@ var (
 		"DNS zone where records are added.").Required().String()
 	arNs1RecordAddName = cmdNs1RecordAdd.Arg("record",
 		"List of name:type:data records.").Required().Strings()
+
+	// ns1 record del:
+	cmdNs1RecordDel    = cmdNs1Record.Command("del", "Deletes records from NS1 zones.")
+	flNs1RecordDelZone = cmdNs1RecordDel.Flag("zone",
+		"DNS zone where records are deleted.").Required().String()
+	arNs1RecordDelName = cmdNs1RecordDel.Arg("record",
+		"List of name:type[:data] records.").Required().Strings()
+
+	// ns1 record list:
+	cmdNs1RecordList    = cmdNs1Record.Command("list", "Lists the records of an NS1 zone.")
+	flNs1RecordListZone = cmdNs1RecordList.Flag("zone",
+		"DNS zone whose records are listed.").Required().String()
+	flNs1RecordListOutput = cmdNs1RecordList.Flag("output",
+		"Output format [ table | json ]").
+		Short('o').Default("table").Enum("table", "json")
 )
 
 //-----------------------------------------------------------------------------
@@ -72,6 +87,24 @@ func RunCmd(cmd string) bool {
 		}
 		d.AddRecords()
 
+	// katoctl ns1 record del:
+	case cmdNs1RecordDel.FullCommand():
+		d := Data{
+			APIKey:  *flNs1APIKey,
+			Zone:    *flNs1RecordDelZone,
+			Records: *arNs1RecordDelName,
+		}
+		d.DelRecords()
+
+	// katoctl ns1 record list:
+	case cmdNs1RecordList.FullCommand():
+		d := Data{
+			APIKey: *flNs1APIKey,
+			Zone:   *flNs1RecordListZone,
+			Output: *flNs1RecordListOutput,
+		}
+		d.PrintRecords()
+
 	// Nothing to do:
 	default:
 		return false
@@ -7,14 +7,19 @@ package ns1
 import (
 
 	// Stdlib:
+	"errors"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
+	// Local:
+	"github.com/katosys/kato/pkg/dns"
+
 	// Community:
 	log "github.com/Sirupsen/logrus"
 	api "gopkg.in/ns1/ns1-go.v2/rest"
-	"gopkg.in/ns1/ns1-go.v2/rest/model/dns"
+	model "gopkg.in/ns1/ns1-go.v2/rest/model/dns"
 )
 
 //-----------------------------------------------------------------------------
@@ -29,6 +34,17 @@ type Data struct {
 	APIKey  string
 	Zone    string
 	Records []string
+	Output  string
+}
+
+//-----------------------------------------------------------------------------
+// func: init
+//-----------------------------------------------------------------------------
+
+func init() {
+	dns.Register("ns1", func(apiKey string) dns.Provider {
+		return &Data{APIKey: apiKey, command: "dns"}
+	})
 }
 
 //-----------------------------------------------------------------------------
@@ -41,16 +57,65 @@ func (d *Data) AddRecords() {
 	// Set the current command:
 	d.command = "record:add"
 
-	// Create an NS1 API client:
-	httpClient := &http.Client{Timeout: time.Second * 10}
-	d.ns1 = api.NewClient(httpClient, api.SetAPIKey(d.APIKey))
+	// Parse the requested records:
+	records, err := dns.ParseRecords(d.Records)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
 
-	// For each requested record:
-	for _, record := range d.Records {
-		if err := d.addRecord(record); err != nil {
-			log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": record}).
-				Fatal(err)
-		}
+	// Add or update the records:
+	if err := d.UpsertRecords(d.Zone, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: DelRecords
+//-----------------------------------------------------------------------------
+
+// DelRecords deletes one or more records from an NS1 zone.
+func (d *Data) DelRecords() {
+
+	// Set the current command:
+	d.command = "record:del"
+
+	// Parse the requested records:
+	records, err := dns.ParseRecords(d.Records)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+
+	// Delete the records:
+	if err := d.DeleteRecords(d.Zone, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: PrintRecords
+//-----------------------------------------------------------------------------
+
+// PrintRecords prints all the records of an NS1 zone.
+func (d *Data) PrintRecords() {
+
+	// Set the current command:
+	d.command = "record:list"
+
+	// Get the records:
+	records, err := d.ListRecords(d.Zone)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+
+	// Print the records:
+	if err := dns.PrintRecords(os.Stdout, d.Output, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": d.Zone}).
+			Fatal(err)
 	}
 }
 
@@ -64,13 +129,9 @@ func (d *Data) AddZones() {
 	// Set the current command:
 	d.command = "zone:add"
 
-	// Create an NS1 API client:
-	httpClient := &http.Client{Timeout: time.Second * 10}
-	d.ns1 = api.NewClient(httpClient, api.SetAPIKey(d.APIKey))
-
 	// For each requested zone:
 	for _, zone := range d.Zones {
-		if err := d.addZone(zone); err != nil {
+		if err := d.AddZone(zone); err != nil {
 			log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": zone}).
 				Fatal(err)
 		}
@@ -87,47 +148,180 @@ func (d *Data) DelZones() {
 	// Set the current command:
 	d.command = "zone:del"
 
-	// Create an NS1 API client:
-	httpClient := &http.Client{Timeout: time.Second * 10}
-	d.ns1 = api.NewClient(httpClient, api.SetAPIKey(d.APIKey))
-
 	// For each requested zone:
 	for _, zone := range d.Zones {
-		if err := d.delZone(zone); err != nil {
+		if err := d.DelZone(zone); err != nil {
 			log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": zone}).
 				Fatal(err)
 		}
 	}
 }
 
+//-----------------------------------------------------------------------------
+// func: AddZone
+//-----------------------------------------------------------------------------
+
+// AddZone adds a zone to NS1.
+func (d *Data) AddZone(zone string) error {
+	d.connect()
+	return d.addZone(zone)
+}
+
+//-----------------------------------------------------------------------------
+// func: DelZone
+//-----------------------------------------------------------------------------
+
+// DelZone deletes a zone from NS1.
+func (d *Data) DelZone(zone string) error {
+	d.connect()
+	return d.delZone(zone)
+}
+
+//-----------------------------------------------------------------------------
+// func: UpsertRecords
+//-----------------------------------------------------------------------------
+
+// UpsertRecords adds or updates records in an NS1 zone.
+func (d *Data) UpsertRecords(zone string, records []dns.Record) error {
+
+	d.connect()
+
+	// For each requested record:
+	for _, record := range records {
+		if err := d.addRecord(zone, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: DeleteRecords
+//-----------------------------------------------------------------------------
+
+// DeleteRecords deletes records from an NS1 zone.
+func (d *Data) DeleteRecords(zone string, records []dns.Record) error {
+
+	d.connect()
+
+	// For each requested record:
+	for _, record := range records {
+		if err := d.delRecord(zone, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: ListRecords
+//-----------------------------------------------------------------------------
+
+// ListRecords returns all the records of an NS1 zone.
+func (d *Data) ListRecords(zone string) ([]dns.Record, error) {
+
+	d.connect()
+
+	// Send the zone request:
+	z, _, err := d.ns1.Zones.Get(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	// Names are relative to the zone:
+	records := []dns.Record{}
+	for _, r := range z.Records {
+		name := strings.TrimSuffix(r.Domain, "."+zone)
+		if r.Domain == zone {
+			name = "@"
+		}
+		records = append(records, dns.Record{
+			Name: name, Type: r.Type, TTL: int64(r.TTL), Data: r.ShortAns})
+	}
+
+	return records, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: connect
+//-----------------------------------------------------------------------------
+
+func (d *Data) connect() {
+	if d.ns1 == nil {
+		httpClient := &http.Client{Timeout: time.Second * 10}
+		d.ns1 = api.NewClient(httpClient, api.SetAPIKey(d.APIKey))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: fqdn
+//-----------------------------------------------------------------------------
+
+func fqdn(name, zone string) string {
+	if name == "@" {
+		return zone
+	}
+	return name + "." + zone
+}
+
 //-----------------------------------------------------------------------------
 // func: addRecord
 //-----------------------------------------------------------------------------
 
-func (d *Data) addRecord(record string) error {
+func (d *Data) addRecord(zone string, record dns.Record) error {
 
-	// Split into name:type:data
-	s := strings.Split(record, ":")
-	resourceName := s[0]
-	resourceType := s[1]
-	resourceData := s[2]
+	// Route 53 only features:
+	name := fqdn(record.Name, zone)
+	if record.Routed() {
+		return errors.New("Routing policies and aliases are not supported by NS1: " + name)
+	}
 
 	// Forge the record request:
-	rec := dns.NewRecord(d.Zone, resourceName, resourceType)
-	for _, data := range strings.Split(resourceData, ",") {
-		rec.AddAnswer(dns.NewAnswer([]string{data}))
+	rec := model.NewRecord(zone, name, record.Type)
+	rec.TTL = int(record.TTL)
+	for _, data := range record.Data {
+		rec.AddAnswer(model.NewAnswer([]string{data}))
 	}
 
-	// Send the record request:
+	// Send the record request, update if it already exists:
 	if _, err := d.ns1.Records.Create(rec); err != nil {
 		if err != api.ErrRecordExists {
 			return err
 		}
+		if _, err := d.ns1.Records.Update(rec); err != nil {
+			return err
+		}
 	}
 
 	// Log record creation:
 	log.WithFields(log.Fields{"cmd": "ns1:" + d.command,
-		"id": resourceName + "." + d.Zone}).Info("New DNS record created/updated")
+		"id": name}).Info("New DNS record created/updated")
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: delRecord
+//-----------------------------------------------------------------------------
+
+func (d *Data) delRecord(zone string, record dns.Record) error {
+
+	// Send the delete record request:
+	name := fqdn(record.Name, zone)
+	if _, err := d.ns1.Records.Delete(zone, name, record.Type); err != nil {
+		if err != api.ErrRecordMissing {
+			return err
+		}
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": name}).
+			Info("Ops! this record does not exist")
+		return nil
+	}
+
+	// Log record deletion:
+	log.WithFields(log.Fields{"cmd": "ns1:" + d.command,
+		"id": name}).Info("DNS record deleted")
 
 	return nil
 }
@@ -139,7 +333,7 @@ func (d *Data) addRecord(record string) error {
 func (d *Data) addZone(zone string) error {
 
 	// Forge the zone request:
-	z := dns.NewZone(zone)
+	z := model.NewZone(zone)
 
 	// Send the zone request:
 	if _, err := d.ns1.Zones.Create(z); err != nil {
@@ -163,7 +357,12 @@ func (d *Data) delZone(zone string) error {
 
 	// Send the delete zone request:
 	if _, err := d.ns1.Zones.Delete(zone); err != nil {
-		return err
+		if err != api.ErrZoneMissing {
+			return err
+		}
+		log.WithFields(log.Fields{"cmd": "ns1:" + d.command, "id": zone}).
+			Info("Ops! this zone does not exist")
+		return nil
 	}
 
 	// Log zone deletion:
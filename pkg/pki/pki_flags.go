@@ -0,0 +1,187 @@
+package pki
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+	"github.com/katosys/kato/pkg/cli"
+)
+
+//-----------------------------------------------------------------------------
+// 'katoctl pki' command flags definitions:
+//-----------------------------------------------------------------------------
+
+var (
+
+	//------------------------
+	// pki: top level command
+	//------------------------
+
+	cmdPki = cli.App.Command("pki", "Manages the certificate authority of a Káto cluster.")
+
+	//----------------------
+	// pki init: subcommand
+	//----------------------
+
+	cmdPkiInit = cmdPki.Command("init",
+		"Creates the cluster CA next to the state file.")
+
+	flPkiInitClusterID = cli.RegexpMatch(cmdPkiInit.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_PKI_INIT_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_PKI_INIT_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flPkiInitDomain = cmdPkiInit.Flag("domain",
+		"Cluster domain, read from the state file if not given.").
+		PlaceHolder("KATO_PKI_INIT_DOMAIN").
+		OverrideDefaultFromEnvar("KATO_PKI_INIT_DOMAIN").
+		String()
+
+	flPkiInitCaCert = cmdPkiInit.Flag("ca-cert",
+		"Import this CA certificate instead of creating one.").
+		PlaceHolder("KATO_PKI_INIT_CA_CERT").
+		OverrideDefaultFromEnvar("KATO_PKI_INIT_CA_CERT").
+		ExistingFile()
+
+	flPkiInitCaKey = cmdPkiInit.Flag("ca-key",
+		"Private key of the imported CA.").
+		PlaceHolder("KATO_PKI_INIT_CA_KEY").
+		OverrideDefaultFromEnvar("KATO_PKI_INIT_CA_KEY").
+		ExistingFile()
+
+	//-----------------------
+	// pki issue: subcommand
+	//-----------------------
+
+	cmdPkiIssue = cmdPki.Command("issue",
+		"Issues a service certificate valid for <name> in every cluster domain.")
+
+	flPkiIssueClusterID = cli.RegexpMatch(cmdPkiIssue.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_PKI_ISSUE_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_PKI_ISSUE_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flPkiIssueAlias = cmdPkiIssue.Flag("alias",
+		"Extra name, FQDN or IP (repeatable).").
+		PlaceHolder("KATO_PKI_ISSUE_ALIAS").
+		Strings()
+
+	arPkiIssueName = cmdPkiIssue.Arg("name",
+		"Service name.").Required().String()
+
+	//------------------------
+	// pki revoke: subcommand
+	//------------------------
+
+	cmdPkiRevoke = cmdPki.Command("revoke",
+		"Revokes node or service certificates.")
+
+	flPkiRevokeClusterID = cli.RegexpMatch(cmdPkiRevoke.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_PKI_REVOKE_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_PKI_REVOKE_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	arPkiRevokeName = cmdPkiRevoke.Arg("name",
+		"Certificate names, <host>-<id> for nodes.").Required().Strings()
+
+	//------------------------
+	// pki rotate: subcommand
+	//------------------------
+
+	cmdPkiRotate = cmdPki.Command("rotate",
+		"Re-issues service certificates, all of them if no name is given.")
+
+	flPkiRotateClusterID = cli.RegexpMatch(cmdPkiRotate.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_PKI_ROTATE_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_PKI_ROTATE_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flPkiRotateExpiring = cmdPkiRotate.Flag("expiring",
+		"Only rotate the certificates expiring within this duration (e.g. 720h).").
+		PlaceHolder("KATO_PKI_ROTATE_EXPIRING").
+		OverrideDefaultFromEnvar("KATO_PKI_ROTATE_EXPIRING").
+		Duration()
+
+	arPkiRotateName = cmdPkiRotate.Arg("name",
+		"Service names.").Strings()
+
+	//----------------------
+	// pki list: subcommand
+	//----------------------
+
+	cmdPkiList = cmdPki.Command("list",
+		"Lists the valid certificates of a Káto cluster.")
+
+	flPkiListClusterID = cli.RegexpMatch(cmdPkiList.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_PKI_LIST_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_PKI_LIST_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flPkiListOutput = cmdPkiList.Flag("output",
+		"Output format [ table | json | yaml ]").
+		Short('o').Default("table").PlaceHolder("KATO_PKI_LIST_OUTPUT").
+		OverrideDefaultFromEnvar("KATO_PKI_LIST_OUTPUT").
+		Enum("table", "json", "yaml")
+)
+
+//-----------------------------------------------------------------------------
+// RunCmd:
+//-----------------------------------------------------------------------------
+
+// RunCmd runs the cmd if owned by this package.
+func RunCmd(cmd string) bool {
+
+	switch cmd {
+
+	// katoctl pki init:
+	case cmdPkiInit.FullCommand():
+		d := Data{
+			ClusterID: *flPkiInitClusterID,
+			Domain:    *flPkiInitDomain,
+			CaCert:    *flPkiInitCaCert,
+			CaKey:     *flPkiInitCaKey,
+		}
+		d.Init()
+
+	// katoctl pki issue:
+	case cmdPkiIssue.FullCommand():
+		d := Data{
+			ClusterID: *flPkiIssueClusterID,
+			Names:     []string{*arPkiIssueName},
+			Aliases:   *flPkiIssueAlias,
+		}
+		d.Issue()
+
+	// katoctl pki revoke:
+	case cmdPkiRevoke.FullCommand():
+		d := Data{
+			ClusterID: *flPkiRevokeClusterID,
+			Names:     *arPkiRevokeName,
+		}
+		d.Revoke()
+
+	// katoctl pki rotate:
+	case cmdPkiRotate.FullCommand():
+		d := Data{
+			ClusterID: *flPkiRotateClusterID,
+			Names:     *arPkiRotateName,
+			Expiring:  *flPkiRotateExpiring,
+		}
+		d.Rotate()
+
+	// katoctl pki list:
+	case cmdPkiList.FullCommand():
+		d := Data{
+			ClusterID: *flPkiListClusterID,
+			Output:    *flPkiListOutput,
+		}
+		d.List()
+
+	// Nothing to do:
+	default:
+		return false
+	}
+
+	return true
+}
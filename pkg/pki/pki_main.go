@@ -0,0 +1,404 @@
+package pki
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/ajeddeloh/yaml"
+
+	// Local:
+	"github.com/katosys/kato/pkg/kato"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Data struct for the pki commands.
+type Data struct {
+	command   string
+	ClusterID string
+	Domain    string
+	CaCert    string
+	CaKey     string
+	Names     []string
+	Aliases   []string
+	Expiring  time.Duration
+	Output    string
+}
+
+// CA is a certificate authority able to issue leaf certificates.
+type CA struct {
+	Cert    *x509.Certificate
+	Key     *ecdsa.PrivateKey
+	CertPEM []byte
+}
+
+// Certificate validity periods:
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	leafValidity = 2 * 365 * 24 * time.Hour
+)
+
+//-----------------------------------------------------------------------------
+// func: Path
+//-----------------------------------------------------------------------------
+
+// Path returns the path of a local PKI file of a cluster, such as the service
+// certificates.
+func Path(clusterID, name string) string {
+	return filepath.Join(os.Getenv("HOME"), ".kato", clusterID+".pki", name)
+}
+
+//-----------------------------------------------------------------------------
+// func: NewCA
+//-----------------------------------------------------------------------------
+
+// NewCA creates a self-signed certificate authority.
+func NewCA(cn string) (*CA, error) {
+
+	// Private key:
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Self-signed certificate:
+	tmpl, err := template(cn, caValidity)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{
+		Cert:    cert,
+		Key:     key,
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: LoadCA
+//-----------------------------------------------------------------------------
+
+// LoadCA reads a PEM encoded certificate authority and its ECDSA key.
+func LoadCA(certPath, keyPath string) (*CA, error) {
+
+	// Certificate:
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("Ops! no PEM certificate found in " + certPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, errors.New("Ops! " + certPath + " is not a CA certificate")
+	}
+
+	// Private key:
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ = pem.Decode(keyPEM); block == nil {
+		return nil, errors.New("Ops! no PEM key found in " + keyPath)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("Ops! " + keyPath + " is not an EC private key: " + err.Error())
+	}
+
+	return &CA{Cert: cert, Key: key, CertPEM: certPEM}, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Issue
+//-----------------------------------------------------------------------------
+
+// Issue signs a new leaf certificate valid for the given DNS names and IPs and
+// returns it along with its private key, both PEM encoded.
+func (ca *CA) Issue(cn string, hosts []string, usage ...x509.ExtKeyUsage) (cert, key []byte, err error) {
+
+	// Private key:
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Certificate:
+	tmpl, err := template(cn, leafValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+	tmpl.ExtKeyUsage = usage
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, &priv.PublicKey, ca.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if key, err = encodeKey(priv); err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), key, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: template
+//-----------------------------------------------------------------------------
+
+func template(cn string, validity time.Duration) (*x509.Certificate, error) {
+
+	// Random 128-bit serial number:
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn, Organization: []string{"kato"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: encodeKey
+//-----------------------------------------------------------------------------
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: writeFile
+//-----------------------------------------------------------------------------
+
+func writeFile(name string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, perm)
+}
+
+//-----------------------------------------------------------------------------
+// func: Init
+//-----------------------------------------------------------------------------
+
+// Init creates the cluster CA next to the state file, or imports one.
+func (d *Data) Init() {
+
+	// Set the current command:
+	d.command = "init"
+
+	// Default to the domain of the deployed cluster:
+	if d.Domain == "" {
+		if raw, err := kato.ReadState(d.ClusterID); err == nil {
+			var st struct{ Domain string }
+			if err := json.Unmarshal(raw, &st); err == nil {
+				d.Domain = st.Domain
+			}
+		}
+	}
+
+	// Create the store:
+	s, err := InitStore(d.ClusterID, d.Domain, d.CaCert, d.CaKey)
+	if err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "pki:" + d.command, "id": d.ClusterID}).
+		Info("Cluster CA ready at " + s.backend.String() + "/" + d.ClusterID + caFile)
+}
+
+//-----------------------------------------------------------------------------
+// func: Issue
+//-----------------------------------------------------------------------------
+
+// Issue signs a service certificate, replacing the previous one if any.
+func (d *Data) Issue() {
+
+	// Set the current command:
+	d.command = "issue"
+
+	// Open the store:
+	s, err := OpenStore(d.ClusterID)
+	if err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	// Sign it:
+	name := d.Names[0]
+	if _, _, err := s.Issue(name, "service", ServiceHosts(s.Domain, name, d.Aliases)); err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "pki:" + d.command, "id": name}).
+		Info("Certificate issued at " + Path(d.ClusterID, filepath.Join(certsDir, name+".pem")))
+}
+
+//-----------------------------------------------------------------------------
+// func: Revoke
+//-----------------------------------------------------------------------------
+
+// Revoke adds node or service certificates to the revocation list.
+func (d *Data) Revoke() {
+
+	// Set the current command:
+	d.command = "revoke"
+
+	// Open the store:
+	s, err := OpenStore(d.ClusterID)
+	if err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	// Revoke them:
+	for _, name := range d.Names {
+		if err := s.Revoke(name); err != nil {
+			log.WithField("cmd", "pki:"+d.command).Fatal(err)
+		}
+		log.WithFields(log.Fields{"cmd": "pki:" + d.command, "id": name}).
+			Info("Certificate revoked")
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: Rotate
+//-----------------------------------------------------------------------------
+
+// Rotate re-issues service certificates with the same names and hosts.
+func (d *Data) Rotate() {
+
+	// Set the current command:
+	d.command = "rotate"
+
+	// Open the store:
+	s, err := OpenStore(d.ClusterID)
+	if err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	// Rotate them:
+	rotated, err := s.Rotate(d.Names, d.Expiring)
+	if err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "pki:" + d.command, "id": d.ClusterID}).
+		Info("Rotated " + strconv.Itoa(len(rotated)) + " certificates: " + strings.Join(rotated, " "))
+}
+
+//-----------------------------------------------------------------------------
+// func: List
+//-----------------------------------------------------------------------------
+
+// List the valid certificates of the cluster.
+func (d *Data) List() {
+
+	// Set the current command:
+	d.command = "list"
+
+	// Open the store:
+	s, err := OpenStore(d.ClusterID)
+	if err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+
+	// Print them:
+	if err := printCerts(os.Stdout, d.Output, s.Valid()); err != nil {
+		log.WithField("cmd", "pki:"+d.command).Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: printCerts
+//-----------------------------------------------------------------------------
+
+func printCerts(w io.Writer, format string, certs []Cert) error {
+
+	switch format {
+
+	case "json":
+		out, err := json.MarshalIndent(certs, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(out))
+		return err
+
+	case "yaml":
+		out, err := yaml.Marshal(certs)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tKIND\tNOT AFTER\tSERIAL\tHOSTS")
+		for _, c := range certs {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Name, c.Kind,
+				c.NotAfter.Format("2006-01-02"), c.Serial, strings.Join(c.Hosts, ","))
+		}
+		return tw.Flush()
+	}
+}
@@ -0,0 +1,698 @@
+package pki
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Local:
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/state"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Store is the cluster PKI kept next to the state, in the state backend: the
+// cluster CA, the index of the certificates it issued and their revocation
+// list. The CA key is encrypted with the secrets key of the cluster.
+type Store struct {
+	clusterID string
+	backend   state.Backend
+	version   string
+	ca        *CA
+	Domain    string `json:"Domain"`
+	Certs     []Cert `json:"Certs"`
+}
+
+// Cert is an issued certificate as recorded in the index.
+type Cert struct {
+	Name     string     `json:"Name" yaml:"Name"`
+	Kind     string     `json:"Kind" yaml:"Kind"` // node | service
+	Serial   string     `json:"Serial" yaml:"Serial"`
+	Hosts    []string   `json:"Hosts" yaml:"Hosts"`
+	NotAfter time.Time  `json:"NotAfter" yaml:"NotAfter"`
+	Revoked  *time.Time `json:"Revoked,omitempty" yaml:"Revoked,omitempty"`
+}
+
+// Store objects, <cluster-id> prefixed, in the state backend:
+const (
+	caFile        = ".pki.ca.pem"
+	caKeyFile     = ".pki.ca-key"
+	indexFile     = ".pki.json"
+	crlFile       = ".pki.crl.pem"
+	etcdCAFile    = ".pki.etcd-ca.pem"
+	etcdCAKeyFile = ".pki.etcd-ca-key"
+)
+
+// Service certificates are written under ~/.kato/<cluster-id>.pki:
+const certsDir = "certs"
+
+//-----------------------------------------------------------------------------
+// func: InitStore
+//-----------------------------------------------------------------------------
+
+// InitStore creates the PKI of a cluster with a new CA or, when both paths
+// are given, with a copy of an existing one.
+func InitStore(clusterID, domain, certPath, keyPath string) (*Store, error) {
+
+	s, err := newStore(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	s.Domain = domain
+
+	// Refuse to overwrite:
+	if _, _, err := s.backend.Get(clusterID + indexFile); err == nil {
+		return nil, errors.New("Ops! the PKI of " + clusterID + " is already initialized")
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if domain == "" {
+		return nil, errors.New("Ops! the cluster domain is required")
+	}
+
+	// New or existing CA:
+	switch {
+	case certPath == "" && keyPath == "":
+		if s.ca, err = NewCA(clusterID + " CA"); err != nil {
+			return nil, err
+		}
+	case certPath == "" || keyPath == "":
+		return nil, errors.New("Ops! the CA certificate and key go together")
+	default:
+		if s.ca, err = LoadCA(certPath, keyPath); err != nil {
+			return nil, err
+		}
+	}
+
+	// Store it, the key encrypted:
+	if err := putCA(s.backend, clusterID, caFile, caKeyFile, s.ca); err != nil {
+		return nil, err
+	}
+
+	// Empty index and revocation list:
+	return s, s.update(func() error { return nil })
+}
+
+//-----------------------------------------------------------------------------
+// func: OpenStore
+//-----------------------------------------------------------------------------
+
+// OpenStore loads the PKI of a cluster.
+func OpenStore(clusterID string) (*Store, error) {
+
+	s, err := newStore(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index:
+	if err := s.load(); err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("Ops! no PKI found for " + clusterID +
+				", run 'katoctl pki init' first")
+		}
+		return nil, err
+	}
+
+	// CA:
+	if s.ca, err = getCA(s.backend, clusterID, caFile, caKeyFile); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: EnsureStore
+//-----------------------------------------------------------------------------
+
+// EnsureStore opens the PKI of a cluster or initializes it with a new CA.
+func EnsureStore(clusterID, domain string) (*Store, error) {
+	s, err := newStore(clusterID)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := s.backend.Get(clusterID + indexFile); err == nil {
+		return OpenStore(clusterID)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return InitStore(clusterID, domain, "", "")
+}
+
+//-----------------------------------------------------------------------------
+// func: newStore
+//-----------------------------------------------------------------------------
+
+func newStore(clusterID string) (*Store, error) {
+	b, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+	return &Store{clusterID: clusterID, backend: b}, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: EnsureEtcdCA
+//-----------------------------------------------------------------------------
+
+// EnsureEtcdCA stores the etcd CA of a cluster next to its PKI, a copy of the
+// given one or, if none is stored yet, a new one. Like the cluster CA, its
+// key is encrypted with the secrets key.
+func EnsureEtcdCA(clusterID, certPath, keyPath string) (*CA, error) {
+
+	b, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	// Given CA:
+	if certPath != "" || keyPath != "" {
+		if certPath == "" || keyPath == "" {
+			return nil, errors.New("Ops! the etcd CA certificate and key go together")
+		}
+		ca, err := LoadCA(certPath, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		return ca, putCA(b, clusterID, etcdCAFile, etcdCAKeyFile, ca)
+	}
+
+	// Stored CA:
+	ca, err := getCA(b, clusterID, etcdCAFile, etcdCAKeyFile)
+	if err == nil || !os.IsNotExist(err) {
+		return ca, err
+	}
+
+	// New CA:
+	if ca, err = NewCA(clusterID + " etcd CA"); err != nil {
+		return nil, err
+	}
+	return ca, putCA(b, clusterID, etcdCAFile, etcdCAKeyFile, ca)
+}
+
+//-----------------------------------------------------------------------------
+// func: OpenEtcdCA
+//-----------------------------------------------------------------------------
+
+// OpenEtcdCA loads the etcd CA stored with EnsureEtcdCA.
+func OpenEtcdCA(clusterID string) (*CA, error) {
+
+	b, err := state.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	ca, err := getCA(b, clusterID, etcdCAFile, etcdCAKeyFile)
+	if os.IsNotExist(err) {
+		return nil, errors.New("Ops! no etcd CA found for " + clusterID +
+			", it is created by 'katoctl ec2 deploy --etcd-tls'")
+	}
+	return ca, err
+}
+
+//-----------------------------------------------------------------------------
+// func: putCA
+//-----------------------------------------------------------------------------
+
+// Write a CA to the backend, its key encrypted with the secrets key.
+func putCA(b state.Backend, clusterID, certFile, keyFile string, ca *CA) error {
+
+	keyPEM, err := encodeKey(ca.Key)
+	if err != nil {
+		return err
+	}
+	sealed, err := kato.EncryptSecret(clusterID, clusterID+keyFile, keyPEM)
+	if err != nil {
+		return err
+	}
+	if _, err := b.Put(clusterID+keyFile, sealed, ""); err != nil {
+		return err
+	}
+
+	_, err = b.Put(clusterID+certFile, ca.CertPEM, "")
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: getCA
+//-----------------------------------------------------------------------------
+
+// Read a CA written with putCA.
+func getCA(b state.Backend, clusterID, certFile, keyFile string) (*CA, error) {
+
+	// Certificate:
+	certPEM, _, err := b.Get(clusterID + certFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("Ops! no PEM certificate found in " + clusterID + certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	// Private key:
+	sealed, _, err := b.Get(clusterID + keyFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := kato.DecryptSecret(clusterID, clusterID+keyFile, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ = pem.Decode(keyPEM); block == nil {
+		return nil, errors.New("Ops! no PEM key found in " + clusterID + keyFile)
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CA{Cert: cert, Key: key, CertPEM: certPEM}, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: CertPEM
+//-----------------------------------------------------------------------------
+
+// CertPEM returns the PEM encoded CA certificate.
+func (s *Store) CertPEM() []byte {
+	return s.ca.CertPEM
+}
+
+//-----------------------------------------------------------------------------
+// func: Issue
+//-----------------------------------------------------------------------------
+
+// Issue signs a certificate of the given kind for the given hosts, revoking
+// the previous one with the same name. Service certificates and keys are kept
+// under certs/, node ones are only handed to the caller.
+func (s *Store) Issue(name, kind string, hosts []string) (cert, key []byte, err error) {
+	err = s.update(func() error {
+		cert, key, err = s.issue(name, kind, hosts)
+		return err
+	})
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: Sign
+//-----------------------------------------------------------------------------
+
+// Sign signs a certificate of the given kind for the given hosts without
+// recording it, for nodes not accepted yet. Record it once they are.
+func (s *Store) Sign(name, kind string, hosts []string) (cert, key []byte, err error) {
+	return s.sign(name, kind, hosts)
+}
+
+//-----------------------------------------------------------------------------
+// func: Record
+//-----------------------------------------------------------------------------
+
+// Record adds a certificate signed with Sign to the index, revoking the
+// previous one with the same name. It reports whether there was one.
+func (s *Store) Record(name, kind string, hosts []string, cert []byte) (superseded bool, err error) {
+	err = s.update(func() error {
+		n, err := s.record(name, kind, hosts, cert)
+		superseded = n > 0
+		return err
+	})
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: CRL
+//-----------------------------------------------------------------------------
+
+// CRL returns the PEM encoded revocation list, as of the last update.
+func (s *Store) CRL() ([]byte, error) {
+	data, _, err := s.backend.Get(s.clusterID + crlFile)
+	return data, err
+}
+
+//-----------------------------------------------------------------------------
+// func: Revoke
+//-----------------------------------------------------------------------------
+
+// Revoke adds the current certificate of the given name to the revocation
+// list and removes its files.
+func (s *Store) Revoke(name string) error {
+	return s.update(func() error {
+		if s.revoke(name) == 0 {
+			return errors.New("Ops! there is no valid certificate named " + name)
+		}
+		return nil
+	})
+}
+
+//-----------------------------------------------------------------------------
+// func: Rotate
+//-----------------------------------------------------------------------------
+
+// Rotate re-issues the given service certificates, or all of them, with the
+// same names and hosts. A positive within only rotates the ones expiring
+// before then. Node certificates roll with the nodes and are skipped.
+func (s *Store) Rotate(names []string, within time.Duration) (rotated []string, err error) {
+
+	err = s.update(func() error {
+
+		// Requested names must exist:
+		for _, n := range names {
+			c := s.find(n)
+			if c == nil {
+				return errors.New("Ops! there is no valid certificate named " + n)
+			}
+			if c.Kind == "node" {
+				return errors.New("Ops! " + n + " is a node certificate, replace the node to rotate it")
+			}
+		}
+
+		// Re-issue:
+		deadline := time.Now().Add(within)
+		for _, c := range s.Valid() {
+			if c.Kind != "service" || (len(names) > 0 && !contains(names, c.Name)) ||
+				(within > 0 && c.NotAfter.After(deadline)) {
+				continue
+			}
+			if _, _, err := s.issue(c.Name, c.Kind, c.Hosts); err != nil {
+				return err
+			}
+			rotated = append(rotated, c.Name)
+		}
+
+		return nil
+	})
+
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: Valid
+//-----------------------------------------------------------------------------
+
+// Valid returns the certificates neither revoked nor superseded.
+func (s *Store) Valid() (list []Cert) {
+	for _, c := range s.Certs {
+		if c.Revoked == nil {
+			list = append(list, c)
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: NodeHosts
+//-----------------------------------------------------------------------------
+
+// NodeHosts returns the names a node answers to: <role>-<id> for its host name
+// and every other role, in the cluster, int. and ext. domains, plus aliases.
+func NodeHosts(domain, hostName, hostID string, roles, aliases []string) []string {
+	hosts := fqdns(domain, hostName+"-"+hostID)
+	for _, role := range roles {
+		if role != hostName {
+			hosts = append(hosts, fqdns(domain, role+"-"+hostID)...)
+		}
+	}
+	hosts = append(hosts, "localhost", "127.0.0.1")
+	return dedup(append(hosts, expand(domain, aliases)...))
+}
+
+//-----------------------------------------------------------------------------
+// func: ServiceHosts
+//-----------------------------------------------------------------------------
+
+// ServiceHosts returns the names a service answers to in the cluster, int.
+// and ext. domains, plus aliases.
+func ServiceHosts(domain, name string, aliases []string) []string {
+	return dedup(append(fqdns(domain, name), expand(domain, aliases)...))
+}
+
+//-----------------------------------------------------------------------------
+// func: fqdns
+//-----------------------------------------------------------------------------
+
+func fqdns(domain, name string) []string {
+	return []string{name, name + "." + domain, name + ".int." + domain, name + ".ext." + domain}
+}
+
+//-----------------------------------------------------------------------------
+// func: expand
+//-----------------------------------------------------------------------------
+
+// Short aliases are expanded like names, IPs and FQDNs are taken as is.
+func expand(domain string, aliases []string) (hosts []string) {
+	for _, a := range aliases {
+		if net.ParseIP(a) != nil || strings.Contains(a, ".") {
+			hosts = append(hosts, a)
+		} else {
+			hosts = append(hosts, fqdns(domain, a)...)
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: dedup
+//-----------------------------------------------------------------------------
+
+func dedup(list []string) (out []string) {
+	for _, v := range list {
+		if !contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: contains
+//-----------------------------------------------------------------------------
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
+// func: find
+//-----------------------------------------------------------------------------
+
+func (s *Store) find(name string) *Cert {
+	for i := range s.Certs {
+		if s.Certs[i].Name == name && s.Certs[i].Revoked == nil {
+			return &s.Certs[i]
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: issue
+//-----------------------------------------------------------------------------
+
+// Called from within update.
+func (s *Store) issue(name, kind string, hosts []string) (cert, key []byte, err error) {
+
+	// Sign and record it:
+	if cert, key, err = s.sign(name, kind, hosts); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.record(name, kind, hosts, cert); err != nil {
+		return nil, nil, err
+	}
+
+	// Keep service certificates:
+	if kind == "service" {
+		if err := writeFile(Path(s.clusterID, filepath.Join(certsDir, name+"-key.pem")), key, 0600); err != nil {
+			return nil, nil, err
+		}
+		if err := writeFile(Path(s.clusterID, filepath.Join(certsDir, name+".pem")), cert, 0644); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return cert, key, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: sign
+//-----------------------------------------------------------------------------
+
+func (s *Store) sign(name, kind string, hosts []string) (cert, key []byte, err error) {
+
+	// Validate:
+	if kind != "node" && kind != "service" {
+		return nil, nil, errors.New("Ops! unknown certificate kind " + kind)
+	}
+	if name == "" || strings.ContainsAny(name, "/. ") {
+		return nil, nil, errors.New("Ops! invalid certificate name '" + name + "'")
+	}
+
+	// Sign it:
+	usage := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	return s.ca.Issue(name+"."+s.Domain, hosts, usage...)
+}
+
+//-----------------------------------------------------------------------------
+// func: record
+//-----------------------------------------------------------------------------
+
+// Called from within update, returns the count of superseded certificates.
+func (s *Store) record(name, kind string, hosts []string, cert []byte) (int, error) {
+
+	block, _ := pem.Decode(cert)
+	if block == nil {
+		return 0, errors.New("Ops! no PEM certificate to record for " + name)
+	}
+	x, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return 0, err
+	}
+
+	// Supersede the previous one:
+	n := s.revoke(name)
+	s.Certs = append(s.Certs, Cert{Name: name, Kind: kind, Hosts: hosts,
+		Serial: x.SerialNumber.Text(16), NotAfter: x.NotAfter.UTC()})
+
+	return n, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: revoke
+//-----------------------------------------------------------------------------
+
+// Called from within update, returns the count of revoked certificates.
+func (s *Store) revoke(name string) (count int) {
+
+	now := time.Now().UTC()
+	for i := range s.Certs {
+		if s.Certs[i].Name == name && s.Certs[i].Revoked == nil {
+			s.Certs[i].Revoked = &now
+			count++
+		}
+	}
+
+	// Remove the files, if any:
+	if count > 0 {
+		_ = os.Remove(Path(s.clusterID, filepath.Join(certsDir, name+".pem")))
+		_ = os.Remove(Path(s.clusterID, filepath.Join(certsDir, name+"-key.pem")))
+	}
+
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: update
+//-----------------------------------------------------------------------------
+
+// Lock the index, reload it, apply fn and write the index and the revocation
+// list back. Concurrent katoctl processes issue certificates for new nodes.
+func (s *Store) update(fn func() error) error {
+
+	// Lock:
+	lock, err := state.AcquireLock(s.backend, s.clusterID+indexFile, "pki")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = state.Release(s.backend, lock) }()
+
+	// Reload:
+	if err := s.load(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Apply:
+	if err := fn(); err != nil {
+		return err
+	}
+
+	// Write the revocation list:
+	crl, err := s.crl()
+	if err != nil {
+		return err
+	}
+	if _, err := s.backend.Put(s.clusterID+crlFile, crl, ""); err != nil {
+		return err
+	}
+
+	// Write the index, over the version just read:
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	match := s.version
+	if match == "" {
+		match = state.Absent
+	}
+	s.version, err = s.backend.Put(s.clusterID+indexFile, data, match)
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: load
+//-----------------------------------------------------------------------------
+
+func (s *Store) load() error {
+	data, version, err := s.backend.Get(s.clusterID + indexFile)
+	if err != nil {
+		return err
+	}
+	s.version = version
+	return json.Unmarshal(data, s)
+}
+
+//-----------------------------------------------------------------------------
+// func: crl
+//-----------------------------------------------------------------------------
+
+// PEM encoded revocation list of every revoked serial, valid as long as the
+// leaf certificates it may list.
+func (s *Store) crl() ([]byte, error) {
+
+	var revoked []pkix.RevokedCertificate
+	for _, c := range s.Certs {
+		if c.Revoked == nil {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(c.Serial, 16)
+		if !ok {
+			return nil, errors.New("Ops! invalid serial number " + c.Serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber: serial, RevocationTime: *c.Revoked})
+	}
+
+	now := time.Now()
+	der, err := s.ca.Cert.CreateCRL(rand.Reader, s.ca.Key, revoked, now, now.Add(leafValidity))
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), nil
+}
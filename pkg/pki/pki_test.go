@@ -0,0 +1,307 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/state"
+)
+
+func TestNodeHosts(t *testing.T) {
+
+	hosts := NodeHosts("kato.ci", "master", "2", []string{"master", "quorum"}, []string{"api", "10.0.0.11"})
+	exp := []string{
+		"master-2", "master-2.kato.ci", "master-2.int.kato.ci", "master-2.ext.kato.ci",
+		"quorum-2", "quorum-2.kato.ci", "quorum-2.int.kato.ci", "quorum-2.ext.kato.ci",
+		"localhost", "127.0.0.1",
+		"api", "api.kato.ci", "api.int.kato.ci", "api.ext.kato.ci", "10.0.0.11",
+	}
+
+	if !reflect.DeepEqual(hosts, exp) {
+		t.Errorf("expected %v, got %v", exp, hosts)
+	}
+}
+
+func TestCA(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certPath, keyPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+
+	// Create and load back:
+	ca, err := NewCA("etcd-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.Cert.IsCA || ca.Cert.Subject.CommonName != "etcd-ca" {
+		t.Errorf("expected an etcd-ca CA, got %v", ca.Cert.Subject)
+	}
+	keyPEM, err := encodeKey(ca.Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(certPath, ca.CertPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCA(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Cert.Equal(ca.Cert) || loaded.Key.D.Cmp(ca.Key.D) != 0 {
+		t.Error("loaded CA differs from the created one")
+	}
+
+	// Leaf certificates are not CAs:
+	leaf, _, err := ca.Issue("quorum-1", []string{"quorum-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(certPath, leaf, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCA(certPath, keyPath); err == nil {
+		t.Error("expected an error loading a leaf as CA")
+	}
+	if _, err := LoadCA(keyPath, keyPath); err == nil {
+		t.Error("expected an error loading a key as certificate")
+	}
+
+	// Names, IPs and usages:
+	ca, err = NewCA("etcd-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _, err := ca.Issue("quorum-1.kato.ci", []string{"quorum-1.kato.ci", "10.0.0.5"},
+		x509.ExtKeyUsageServerAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(raw)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM)
+	for _, host := range []string{"quorum-1.kato.ci", "10.0.0.5"} {
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: host}); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: "10.0.0.6"}); err == nil {
+		t.Error("expected an error verifying an unknown IP")
+	}
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}); err == nil {
+		t.Error("expected an error using a server certificate as client")
+	}
+}
+
+func TestStore(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	// Init once:
+	if _, err := InitStore("kato", "", "", ""); err == nil {
+		t.Error("expected an error without domain")
+	}
+	if _, err := InitStore("kato", "kato.ci", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InitStore("kato", "kato.ci", "", ""); err == nil {
+		t.Error("expected an error on second init")
+	}
+	s, err := OpenStore("kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Issue and verify:
+	if _, _, err := s.Issue("marathon", "service", ServiceHosts(s.Domain, "marathon", nil)); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := ioutil.ReadFile(Path("kato", "certs/marathon.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(raw)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(s.CertPEM())
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots,
+		DNSName: "marathon.ext.kato.ci"}); err != nil {
+		t.Error(err)
+	}
+
+	// Node certificates are not kept and not rotated:
+	if _, _, err := s.Issue("worker-1", "node", []string{"worker-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(Path("kato", "certs/worker-1.pem")); !os.IsNotExist(err) {
+		t.Error("node certificate written to disk")
+	}
+	if _, err := s.Rotate([]string{"worker-1"}, 0); err == nil {
+		t.Error("expected an error rotating a node certificate")
+	}
+
+	// Rotate only what expires soon:
+	if rotated, err := s.Rotate(nil, time.Hour); err != nil || len(rotated) != 0 {
+		t.Errorf("expected nothing rotated, got %v (%v)", rotated, err)
+	}
+	if rotated, err := s.Rotate(nil, 0); err != nil || !reflect.DeepEqual(rotated, []string{"marathon"}) {
+		t.Errorf("expected marathon rotated, got %v (%v)", rotated, err)
+	}
+
+	// Signed node certificates only count once recorded:
+	signed, _, err := s.Sign("worker-1", "node", []string{"worker-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Certs); n != 3 {
+		t.Errorf("expected 3 certificates before recording, got %d", n)
+	}
+	if superseded, err := s.Record("worker-1", "node", []string{"worker-1"}, signed); err != nil || !superseded {
+		t.Errorf("expected worker-1 superseded, got %v (%v)", superseded, err)
+	}
+
+	// Revoke:
+	if err := s.Revoke("worker-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke("worker-1"); err == nil {
+		t.Error("expected an error revoking twice")
+	}
+
+	// The index is shared:
+	s, err = OpenStore("kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Certs) != 4 || len(s.Valid()) != 1 {
+		t.Errorf("expected 4 certificates, 1 valid, got %d, %d", len(s.Certs), len(s.Valid()))
+	}
+
+	// Superseded and revoked serials are listed:
+	raw, err = s.CRL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := x509.ParseCRL(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(crl.TBSCertList.RevokedCertificates); n != 3 {
+		t.Errorf("expected 3 revoked certificates, got %d", n)
+	}
+}
+
+func TestRedeploy(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	// Deploy:
+	s, err := EnsureStore("kato", "kato.ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Destroy drops the PKI with the secrets key:
+	if err := kato.DeleteState("kato"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deploy again with a new CA:
+	r, err := EnsureStore("kato", "kato.ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ca.Cert.Equal(s.ca.Cert) {
+		t.Error("expected a new CA")
+	}
+	if _, err := OpenStore("kato"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEtcdCA(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", dir)
+
+	// Missing until deployed:
+	if _, err := OpenEtcdCA("kato"); err == nil {
+		t.Error("expected an error without etcd CA")
+	}
+
+	// Created once, then reused:
+	ca, err := EnsureEtcdCA("kato", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := EnsureEtcdCA("kato", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Cert.Equal(ca.Cert) {
+		t.Error("expected the stored etcd CA reused")
+	}
+
+	// Stored in the backend, the key encrypted:
+	loaded, err := OpenEtcdCA("kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Cert.Equal(ca.Cert) || loaded.Key.D.Cmp(ca.Key.D) != 0 {
+		t.Error("loaded etcd CA differs from the created one")
+	}
+	b, err := state.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _, err := b.Get("kato" + etcdCAKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "PRIVATE KEY") {
+		t.Error("etcd CA key stored in clear")
+	}
+
+	// Given CAs are imported:
+	if _, err := EnsureEtcdCA("kato", "ca.pem", ""); err == nil {
+		t.Error("expected an error with a certificate but no key")
+	}
+}
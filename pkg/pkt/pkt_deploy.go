@@ -0,0 +1,286 @@
+package pkt
+
+//---------------------------------------------------------------------------
+// Package factored import statement:
+//---------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/pki"
+)
+
+//--------------------------------------------------------------------------
+// func: Deploy
+//--------------------------------------------------------------------------
+
+// Deploy Kato's infrastructure on Packet.net
+func (d *Data) Deploy() {
+
+	// Initializations:
+	d.command = "deploy"
+	wch := kato.NewWaitChan(3)
+
+	// Count quorum and master nodes:
+	d.QuorumCount = kato.CountNodes(d.Quadruplets, "quorum")
+	d.MasterCount = kato.CountNodes(d.Quadruplets, "master")
+
+	// Cluster PKI, kept if already there:
+	if d.PKI {
+		if _, err := pki.EnsureStore(d.ClusterID, d.Domain); err != nil {
+			log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+		}
+	}
+
+	// Setup the environment (I):
+	go d.setupPacket(wch)
+	go kato.CreateDNSZones(wch, d.DNSProvider, d.DNSApiKey, d.Domain, "", "")
+	go kato.NewEtcdDiscovery(wch, d.QuorumCount, d.EtcdToken, &d.EtcdDiscovery)
+
+	// Wait and check for errors:
+	if err := wch.WaitErr(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Dump state to file (II):
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Secrets go to their own encrypted file:
+	if err := kato.DumpSecrets(d.Secrets, d.ClusterID); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Deploy all the nodes (III):
+	for _, q := range d.Quadruplets {
+		wch.WaitGrp.Add(1)
+		s := strings.Split(q, ":")
+		i, _ := strconv.Atoi(s[0])
+		go d.deployNodes(wch, i, s[1], s[2], s[3])
+	}
+
+	// Wait for the nodes:
+	if err := wch.WaitErr(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+}
+
+//--------------------------------------------------------------------------
+// func: setupPacket
+//--------------------------------------------------------------------------
+
+func (d *Data) setupPacket(wch *kato.WaitChan) {
+
+	// Decrement:
+	defer wch.WaitGrp.Done()
+
+	// Log this action:
+	log.WithFields(log.Fields{"cmd": "pkt:" + d.command, "id": d.ClusterID}).
+		Info("Setup the Packet.net project")
+
+	// Forge the setup command:
+	args := []string{"pkt", "setup",
+		"--api-key", d.APIKey,
+		"--cluster-id", d.ClusterID,
+		"--facility", d.Facility,
+	}
+
+	if d.ProjectID != "" {
+		args = append(args, "--project-id", d.ProjectID)
+	}
+
+	// Execute the setup command:
+	cmdSetup := exec.Command("katoctl", args...)
+	cmdSetup.Stderr = os.Stderr
+	if err := cmdSetup.Run(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		wch.ErrChan <- err
+		return
+	}
+
+	// Merge state from state file:
+	if err := d.loadState(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		wch.ErrChan <- err
+	}
+}
+
+//--------------------------------------------------------------------------
+// func: deployNodes
+//--------------------------------------------------------------------------
+
+func (d *Data) deployNodes(wch *kato.WaitChan, count int, plan, hostname, roles string) {
+
+	// Decrement:
+	defer wch.WaitGrp.Done()
+	var wgInt sync.WaitGroup
+
+	log.WithField("cmd", "pkt:"+d.command).
+		Info("Deploying " + strconv.Itoa(count) + " " + hostname + " nodes")
+
+	for i := 1; i <= count; i++ {
+		wgInt.Add(1)
+
+		go func(id int) {
+			defer wgInt.Done()
+
+			// Per node instance data:
+			node := Instance{
+				HostName:     hostname,
+				HostID:       strconv.Itoa(id),
+				Roles:        roles,
+				ClusterState: "new",
+				Plan:         plan,
+			}
+
+			// Sign the node certificate, recorded once the device is accepted:
+			var nc *nodeCert
+			if d.PKI {
+				var err error
+				if nc, err = d.signNodeCert(node); err != nil {
+					log.WithField("cmd", "pkt:"+d.command).Error(err)
+					wch.ErrChan <- err
+					return
+				}
+			}
+
+			// Execute the udata|run pipeline:
+			if _, err := kato.ExecutePipeline(
+				d.forgeUdataCommand(node, nc), d.forgeRunCommand(node)); err != nil {
+				log.WithField("cmd", "pkt:"+d.command).Error(err)
+				wch.ErrChan <- err
+				return
+			}
+
+			// Record the node certificate:
+			if nc != nil {
+				if _, err := nc.store.Record(nc.name, "node", nc.hosts, nc.cert); err != nil {
+					log.WithField("cmd", "pkt:"+d.command).Warning(err)
+				}
+			}
+		}(i)
+	}
+
+	// Wait:
+	wgInt.Wait()
+}
+
+//--------------------------------------------------------------------------
+// func: signNodeCert
+//--------------------------------------------------------------------------
+
+func (d *Data) signNodeCert(node Instance) (*nodeCert, error) {
+
+	// Open the cluster PKI:
+	s, err := pki.OpenStore(d.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign it:
+	nc := &nodeCert{store: s, name: node.HostName + "-" + node.HostID}
+	nc.hosts = pki.NodeHosts(s.Domain, node.HostName, node.HostID, strings.Split(node.Roles, ","), nil)
+	if nc.cert, nc.key, err = s.Sign(nc.name, "node", nc.hosts); err != nil {
+		return nil, err
+	}
+
+	return nc, nil
+}
+
+//--------------------------------------------------------------------------
+// func: forgeUdataCommand
+//--------------------------------------------------------------------------
+
+func (d *Data) forgeUdataCommand(node Instance, nc *nodeCert) *exec.Cmd {
+
+	// Udata arguments bundle:
+	args := []string{"udata",
+		"--roles", node.Roles,
+		"--cluster-id", d.ClusterID,
+		"--cluster-state", node.ClusterState,
+		"--quorum-count", strconv.Itoa(d.QuorumCount),
+		"--master-count", strconv.Itoa(d.MasterCount),
+		"--host-name", node.HostName,
+		"--host-id", node.HostID,
+		"--domain", d.Domain,
+		"--dns-provider", d.DNSProvider,
+		"--etcd-discovery", d.EtcdDiscovery,
+		"--calico-ip-pool", d.CalicoIPPool,
+		"--iaas-provider", "packet",
+		"--prometheus",
+	}
+
+	// Append flags if present:
+	if d.CaCertPath != "" {
+		args = append(args, "--ca-cert-path", d.CaCertPath)
+	}
+	for _, z := range d.StubZones {
+		args = append(args, "--stub-zone", z)
+	}
+	if d.AdminEmail != "" {
+		args = append(args, "--admin-email", d.AdminEmail)
+	}
+
+	// Secrets go through the environment, not the process list. Packet has
+	// no KMS so they end up in clear in the user data, see requireOptIn:
+	env := map[string]string{
+		"KATO_UDATA_DNS_API_KEY":   d.DNSApiKey,
+		"KATO_UDATA_SLACK_WEBHOOK": d.SlackWebhook,
+		"KATO_UDATA_SMTP_URL":      d.SMTPURL,
+	}
+	if nc != nil {
+		env["KATO_UDATA_NODE_CERT"] = string(nc.cert)
+		env["KATO_UDATA_NODE_KEY"] = string(nc.key)
+		env["KATO_UDATA_PKI_CA"] = string(nc.store.CertPEM())
+		if crl, err := nc.store.CRL(); err == nil {
+			env["KATO_UDATA_PKI_CRL"] = string(crl)
+		}
+	}
+
+	cmd := exec.Command("katoctl", args...)
+	cmd.Env = os.Environ()
+	for name, value := range env {
+		if value != "" {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+
+	return cmd
+}
+
+//--------------------------------------------------------------------------
+// func: forgeRunCommand
+//--------------------------------------------------------------------------
+
+func (d *Data) forgeRunCommand(node Instance) *exec.Cmd {
+
+	// Packet run arguments bundle:
+	args := []string{"pkt", "run",
+		"--api-key", d.APIKey,
+		"--host-name", node.HostName + "-" + node.HostID + "." + d.Domain,
+		"--project-id", d.ProjectID,
+		"--plan", node.Plan,
+		"--os", "coreos_" + d.CoreOSChannel,
+		"--facility", d.Facility,
+		"--billing", d.Billing,
+		"--tag", d.ClusterID,
+	}
+
+	// Tag the device with its roles:
+	for _, role := range strings.Split(node.Roles, ",") {
+		args = append(args, "--tag", role)
+	}
+
+	// Forge the command and return:
+	return exec.Command("katoctl", args...)
+}
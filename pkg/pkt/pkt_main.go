@@ -7,11 +7,16 @@ package pkt
 import (
 
 	// Stdlib:
+	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"os"
 
 	// Community:
 	log "github.com/Sirupsen/logrus"
+	"github.com/imdario/mergo"
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/pki"
 	"github.com/packethost/packngo"
 )
 
@@ -19,32 +24,165 @@ import (
 // Typedefs:
 //----------------------------------------------------------------------------
 
+// Instance data.
+type Instance struct {
+	HostName     string   `json:"HostName"`     // deploy | run
+	HostID       string   `json:"HostID"`       // deploy |
+	Roles        string   `json:"Roles"`        // deploy |
+	ClusterState string   `json:"ClusterState"` // deploy |
+	Plan         string   `json:"Plan"`         // deploy | run
+	OS           string   `json:"OS"`           //        | run
+	Tags         []string `json:"Tags"`         //        | run
+}
+
+// State data.
+type State struct {
+	Quadruplets   []string `json:"-"`             // deploy |       |
+	StubZones     []string `json:"StubZones"`     // deploy |       |
+	QuorumCount   int      `json:"QuorumCount"`   // deploy |       |
+	MasterCount   int      `json:"MasterCount"`   // deploy |       |
+	CoreOSChannel string   `json:"CoreOSChannel"` // deploy |       |
+	EtcdToken     string   `json:"EtcdToken"`     // deploy |       |
+	EtcdDiscovery string   `json:"EtcdDiscovery"` // deploy |       |
+	DNSProvider   string   `json:"DNSProvider"`   // deploy |       |
+	AdminEmail    string   `json:"AdminEmail"`    // deploy |       |
+	CaCertPath    string   `json:"CaCertPath"`    // deploy |       |
+	PKI           bool     `json:"PKI"`           // deploy |       |
+	CalicoIPPool  string   `json:"CalicoIPPool"`  // deploy |       |
+	Domain        string   `json:"Domain"`        // deploy |       |
+	Billing       string   `json:"Billing"`       // deploy |       | run
+	ClusterID     string   `json:"ClusterID"`     // deploy | setup |
+	Facility      string   `json:"Facility"`      // deploy | setup | run
+	ProjectID     string   `json:"ProjectID"`     //        | setup | run
+}
+
+// Secrets data, kept apart from the state and encrypted at rest.
+type Secrets struct {
+	DNSApiKey    string `json:"DNSApiKey,omitempty"`    // deploy
+	SlackWebhook string `json:"SlackWebhook,omitempty"` // deploy
+	SMTPURL      string `json:"SMTPURL,omitempty"`      // deploy
+}
+
 // Data contains variables used by Packet.net API.
 type Data struct {
-	command   string
-	APIKey    string
-	HostName  string
-	ProjectID string
-	Plan      string
-	OS        string
-	Facility  string
-	Billing   string
+	command          string
+	client           *packngo.Client
+	APIKey           string
+	InsecureUserData bool
+	Instance
+	State
+	Secrets
+}
+
+// Node certificate signed for a new device, recorded once it is accepted.
+type nodeCert struct {
+	store     *pki.Store
+	name      string
+	hosts     []string
+	cert, key []byte
 }
 
+// Packet.net API endpoint (overridden in tests):
+var apiURL = "https://api.packet.net/"
+
 //--------------------------------------------------------------------------
-// func: Deploy
+// func: connect
 //--------------------------------------------------------------------------
 
-// Deploy Kato's infrastructure on Packet.net
-func (d *Data) Deploy() {
+func (d *Data) connect() error {
+
+	// Connect and authenticate to the API endpoint:
+	client, err := packngo.NewClientWithBaseURL("", d.APIKey, nil, apiURL)
+	if err != nil {
+		return err
+	}
+
+	d.client = client
+	return nil
+}
+
+//--------------------------------------------------------------------------
+// func: requireOptIn
+//--------------------------------------------------------------------------
+
+// Packet has no KMS to seal secrets and node private keys, they can only
+// reach the nodes in clear in the user data and only when asked to.
+func (d *Data) requireOptIn() error {
+	if d.InsecureUserData {
+		return nil
+	}
+	if d.DNSApiKey != "" || d.SMTPURL != "" || d.SlackWebhook != "" || d.PKI {
+		return errors.New("Ops! secrets and --pki end up in clear in the user data, " +
+			"pass --insecure-user-data to accept it")
+	}
+	return nil
+}
+
+//--------------------------------------------------------------------------
+// func: loadState
+//--------------------------------------------------------------------------
+
+func (d *Data) loadState() error {
+
+	// Read raw data from state file:
+	raw, err := kato.ReadState(d.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	// Decode the loaded JSON data:
+	dat := State{}
+	if err := json.Unmarshal(raw, &dat); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		return err
+	}
+
+	// Merge the decoded data into the current state:
+	if err := mergo.Map(&d.State, dat); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		return err
+	}
+
+	// Merge the stored secrets, flags take precedence:
+	if err := d.loadSecrets(raw); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		return err
+	}
+
+	return nil
 }
 
 //--------------------------------------------------------------------------
-// func: Setup
+// func: loadSecrets
 //--------------------------------------------------------------------------
 
-// Setup a Packet.net project to be used by katoctl.
-func (d *Data) Setup() {
+// Read the encrypted secrets. Secrets found in clear in older state files
+// are moved to the secrets file, the next state dump drops them.
+func (d *Data) loadSecrets(raw []byte) error {
+
+	// Stored secrets:
+	stored := Secrets{}
+	if err := kato.ReadSecrets(d.ClusterID, &stored); err != nil {
+		return err
+	}
+
+	// Legacy secrets:
+	legacy := Secrets{}
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return err
+	}
+	if legacy != (Secrets{}) {
+		if err := mergo.Merge(&stored, legacy); err != nil {
+			return err
+		}
+		if err := kato.DumpSecrets(stored, d.ClusterID); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"cmd": "pkt:" + d.command, "id": d.ClusterID}).
+			Info("Secrets moved out of the state file")
+	}
+
+	return mergo.Merge(&d.Secrets, stored)
 }
 
 //--------------------------------------------------------------------------
@@ -64,7 +202,26 @@ func (d *Data) Run() {
 	}
 
 	// Connect and authenticate to the API endpoint:
-	client := packngo.NewClient("", d.APIKey, nil)
+	if err := d.connect(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Send the request:
+	newDevice, err := d.createDevice(udata)
+	if err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Pretty-print the response data:
+	log.WithFields(log.Fields{"cmd": "pkt:" + d.command, "id": newDevice.ID}).
+		Info("New " + d.Plan + " Packet.net device requested")
+}
+
+//--------------------------------------------------------------------------
+// func: createDevice
+//--------------------------------------------------------------------------
+
+func (d *Data) createDevice(udata []byte) (*packngo.Device, error) {
 
 	// Forge the request:
 	createRequest := &packngo.DeviceCreateRequest{
@@ -75,14 +232,14 @@ func (d *Data) Run() {
 		BillingCycle: d.Billing,
 		ProjectID:    d.ProjectID,
 		UserData:     string(udata),
+		Tags:         d.Tags,
 	}
 
 	// Send the request:
-	newDevice, _, err := client.Devices.Create(createRequest)
+	newDevice, _, err := d.client.Devices.Create(createRequest)
 	if err != nil {
-		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+		return nil, err
 	}
 
-	// Pretty-print the response data:
-	log.WithField("cmd", "pkt:"+d.command).Info(newDevice)
+	return newDevice, nil
 }
@@ -0,0 +1,98 @@
+package pkt
+
+//---------------------------------------------------------------------------
+// Package factored import statement:
+//---------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"strings"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/packethost/packngo"
+)
+
+//--------------------------------------------------------------------------
+// func: Setup
+//--------------------------------------------------------------------------
+
+// Setup a Packet.net project to be used by katoctl.
+func (d *Data) Setup() {
+
+	// Set current command:
+	d.command = "setup"
+
+	// Load state from state file (if any):
+	if err := d.loadState(); err != nil {
+		if !strings.Contains(err.Error(), "no such file or directory") {
+			log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+		}
+	}
+
+	// Connect and authenticate to the API endpoint:
+	if err := d.connect(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Create the project:
+	if err := d.createProject(); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+
+	// Dump state to file:
+	if err := kato.DumpState(d.State, d.ClusterID); err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Fatal(err)
+	}
+}
+
+//--------------------------------------------------------------------------
+// func: createProject
+//--------------------------------------------------------------------------
+
+func (d *Data) createProject() error {
+
+	// Return if already defined:
+	if d.ProjectID != "" {
+		log.WithFields(log.Fields{"cmd": "pkt:" + d.command, "id": d.ProjectID}).
+			Info("Using defined project")
+		return nil
+	}
+
+	// Look for an existing project:
+	projects, _, err := d.client.Projects.List()
+	if err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		return err
+	}
+
+	for _, p := range projects {
+		if p.Name == d.ClusterID {
+			d.ProjectID = p.ID
+			log.WithFields(log.Fields{"cmd": "pkt:" + d.command, "id": d.ProjectID}).
+				Info("Using existing project")
+			return nil
+		}
+	}
+
+	// Forge the project request:
+	params := &packngo.ProjectCreateRequest{
+		Name: d.ClusterID,
+	}
+
+	// Send the project request:
+	project, _, err := d.client.Projects.Create(params)
+	if err != nil {
+		log.WithField("cmd", "pkt:"+d.command).Error(err)
+		return err
+	}
+
+	// Store the project ID:
+	d.ProjectID = project.ID
+	log.WithFields(log.Fields{"cmd": "pkt:" + d.command, "id": d.ProjectID}).
+		Info("New Packet.net project created")
+
+	return nil
+}
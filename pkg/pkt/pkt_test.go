@@ -1 +1,171 @@
 package pkt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/packethost/packngo"
+)
+
+//-----------------------------------------------------------------------------
+// Fake packngo HTTP endpoint:
+//-----------------------------------------------------------------------------
+
+type fakePacket struct {
+	projects []packngo.Project
+	devices  []packngo.DeviceCreateRequest
+}
+
+func (f *fakePacket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	switch {
+
+	case r.Method == "GET" && r.URL.Path == "/projects":
+		_ = json.NewEncoder(w).Encode(map[string][]packngo.Project{"projects": f.projects})
+
+	case r.Method == "POST" && r.URL.Path == "/projects":
+		req := packngo.ProjectCreateRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		p := packngo.Project{ID: fmt.Sprintf("project-%d", len(f.projects)+1), Name: req.Name}
+		f.projects = append(f.projects, p)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(p)
+
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/devices"):
+		req := packngo.DeviceCreateRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		f.devices = append(f.devices, req)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(packngo.Device{ID: "device-1", Hostname: req.HostName})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakePacket(t *testing.T, f *fakePacket) (*Data, *httptest.Server) {
+	srv := httptest.NewServer(f)
+	apiURL = srv.URL + "/"
+	d := &Data{APIKey: "secret", State: State{ClusterID: "kato"}}
+	if err := d.connect(); err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return d, srv
+}
+
+//-----------------------------------------------------------------------------
+// Tests:
+//-----------------------------------------------------------------------------
+
+func TestCreateProject(t *testing.T) {
+
+	f := &fakePacket{}
+	d, srv := newFakePacket(t, f)
+	defer srv.Close()
+
+	if err := d.createProject(); err != nil {
+		t.Fatal(err)
+	}
+	if d.ProjectID != "project-1" || len(f.projects) != 1 {
+		t.Fatalf("expected a new project, got %q (%d projects)", d.ProjectID, len(f.projects))
+	}
+}
+
+func TestCreateProjectExisting(t *testing.T) {
+
+	f := &fakePacket{projects: []packngo.Project{
+		{ID: "other", Name: "other"},
+		{ID: "existing", Name: "kato"},
+	}}
+	d, srv := newFakePacket(t, f)
+	defer srv.Close()
+
+	if err := d.createProject(); err != nil {
+		t.Fatal(err)
+	}
+	if d.ProjectID != "existing" || len(f.projects) != 2 {
+		t.Fatalf("expected the existing project, got %q (%d projects)", d.ProjectID, len(f.projects))
+	}
+}
+
+func TestCreateDevice(t *testing.T) {
+
+	f := &fakePacket{projects: []packngo.Project{{ID: "existing", Name: "kato"}}}
+	d, srv := newFakePacket(t, f)
+	defer srv.Close()
+	d.ProjectID = "existing"
+	d.Facility = "ewr1"
+	d.Billing = "hourly"
+	d.Instance = Instance{
+		HostName: "worker-1.example.com",
+		Plan:     "baremetal_0",
+		OS:       "coreos_stable",
+		Tags:     []string{"kato", "worker"},
+	}
+
+	dev, err := d.createDevice([]byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.ID != "device-1" || len(f.devices) != 1 {
+		t.Fatalf("expected one device, got %q (%d devices)", dev.ID, len(f.devices))
+	}
+	if req := f.devices[0]; req.HostName != "worker-1.example.com" ||
+		req.ProjectID != "existing" || req.UserData != "{}" || len(req.Tags) != 2 {
+		t.Fatalf("unexpected device request: %+v", req)
+	}
+}
+
+func TestForgeRunCommand(t *testing.T) {
+
+	d := &Data{APIKey: "secret", State: State{
+		ClusterID:     "kato",
+		Domain:        "example.com",
+		ProjectID:     "existing",
+		CoreOSChannel: "beta",
+		Facility:      "ams1",
+		Billing:       "hourly",
+	}}
+
+	cmd := d.forgeRunCommand(Instance{
+		HostName: "master", HostID: "2", Roles: "master,worker", Plan: "baremetal_1"})
+
+	expected := []string{"katoctl", "pkt", "run",
+		"--api-key", "secret",
+		"--host-name", "master-2.example.com",
+		"--project-id", "existing",
+		"--plan", "baremetal_1",
+		"--os", "coreos_beta",
+		"--facility", "ams1",
+		"--billing", "hourly",
+		"--tag", "kato",
+		"--tag", "master",
+		"--tag", "worker",
+	}
+
+	if fmt.Sprint(cmd.Args) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, cmd.Args)
+	}
+}
+
+func TestRequireOptIn(t *testing.T) {
+
+	for i, tc := range []struct {
+		d  *Data
+		ok bool
+	}{
+		{&Data{}, true},
+		{&Data{Secrets: Secrets{SlackWebhook: "https://hooks.slack.com/x"}}, false},
+		{&Data{State: State{PKI: true}}, false},
+		{&Data{State: State{PKI: true}, InsecureUserData: true}, true},
+	} {
+		if err := tc.d.requireOptIn(); (err == nil) != tc.ok {
+			t.Errorf("case %d: expected ok %v, got %v", i, tc.ok, err)
+		}
+	}
+}
@@ -7,6 +7,8 @@ package r53
 import (
 	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/katosys/kato/pkg/cli"
+	"github.com/katosys/kato/pkg/dns"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 //-----------------------------------------------------------------------------
@@ -25,6 +27,10 @@ var (
 	cmdR53ZoneAdd    = cmdR53Zone.Command("add", "Adds Route 53 zones.")
 	arR53ZoneAddName = cmdR53ZoneAdd.Arg("fqdn",
 		"List of zones to publish.").Required().Strings()
+	flR53ZoneAddVpcID = cmdR53ZoneAdd.Flag("vpc-id",
+		"Make the zones private to this VPC.").String()
+	flR53ZoneAddRegion = cmdR53ZoneAdd.Flag("vpc-region",
+		"Region of the VPC.").String()
 
 	// r53 zone del:
 	cmdR53ZoneDel    = cmdR53Zone.Command("del", "Deletes Route 53 zones.")
@@ -36,7 +42,45 @@ var (
 	flR53RecordAddZone = cmdR53RecordAdd.Flag("zone",
 		"DNS zone where records are added.").Required().String()
 	arR53RecordAddName = cmdR53RecordAdd.Arg("record",
-		"List of name:type:data records.").Required().Strings()
+		"List of name:type[:data] records.").Required().Strings()
+	flR53RecordAddTTL = cmdR53RecordAdd.Flag("ttl",
+		"Records TTL in seconds.").Default("300").Int64()
+	flR53RecordAddSetID = cmdR53RecordAdd.Flag("set-id",
+		"Set identifier of weighted and latency-based records.").String()
+	flR53RecordAddWeightSet bool
+	flR53RecordAddWeight    = cmdR53RecordAdd.Flag("weight",
+		"Weight [0-255] of weighted records.").
+		Action(func(*kingpin.ParseContext) error {
+			flR53RecordAddWeightSet = true
+			return nil
+		}).Int64()
+	flR53RecordAddRegion = cmdR53RecordAdd.Flag("region",
+		"EC2 region of latency-based records.").String()
+	flR53RecordAddHealthCheck = cmdR53RecordAdd.Flag("health-check-id",
+		"Health check associated to the records.").String()
+	flR53RecordAddAliasTarget = cmdR53RecordAdd.Flag("alias-target",
+		"DNS name of an ELB or record set to alias.").String()
+	flR53RecordAddAliasZoneID = cmdR53RecordAdd.Flag("alias-zone-id",
+		"Hosted zone ID of the alias target.").String()
+	flR53RecordAddAliasHealth = cmdR53RecordAdd.Flag("evaluate-target-health",
+		"Evaluate the health of the alias target.").Bool()
+
+	// r53 record del:
+	cmdR53RecordDel    = cmdR53Record.Command("del", "Deletes records from Route 53 zones.")
+	flR53RecordDelZone = cmdR53RecordDel.Flag("zone",
+		"DNS zone where records are deleted.").Required().String()
+	arR53RecordDelName = cmdR53RecordDel.Arg("record",
+		"List of name:type[:data] records.").Required().Strings()
+	flR53RecordDelSetID = cmdR53RecordDel.Flag("set-id",
+		"Set identifier of weighted and latency-based records.").String()
+
+	// r53 record list:
+	cmdR53RecordList    = cmdR53Record.Command("list", "Lists the records of a Route 53 zone.")
+	flR53RecordListZone = cmdR53RecordList.Flag("zone",
+		"DNS zone whose records are listed.").Required().String()
+	flR53RecordListOutput = cmdR53RecordList.Flag("output",
+		"Output format [ table | json ]").
+		Short('o').Default("table").Enum("table", "json")
 )
 
 //-----------------------------------------------------------------------------
@@ -53,6 +97,8 @@ func RunCmd(cmd string) bool {
 		d := Data{
 			APIKey: *flR53APIKey,
 			Zones:  *arR53ZoneAddName,
+			VpcID:  *flR53ZoneAddVpcID,
+			Region: *flR53ZoneAddRegion,
 		}
 		d.AddZones()
 
@@ -74,9 +120,52 @@ func RunCmd(cmd string) bool {
 				},
 			},
 			Records: *arR53RecordAddName,
+			Policy: dns.Record{
+				TTL:         *flR53RecordAddTTL,
+				SetID:       *flR53RecordAddSetID,
+				Region:      *flR53RecordAddRegion,
+				HealthCheck: *flR53RecordAddHealthCheck,
+			},
+		}
+		if flR53RecordAddWeightSet {
+			d.Policy.Weight = flR53RecordAddWeight
+		}
+		if *flR53RecordAddAliasTarget != "" {
+			d.Policy.Alias = &dns.Alias{
+				DNSName:        *flR53RecordAddAliasTarget,
+				ZoneID:         *flR53RecordAddAliasZoneID,
+				EvaluateHealth: *flR53RecordAddAliasHealth,
+			}
 		}
 		d.AddRecords()
 
+	// katoctl r53 record del:
+	case cmdR53RecordDel.FullCommand():
+		d := Data{
+			APIKey: *flR53APIKey,
+			Zone: zoneData{
+				HostedZone: route53.HostedZone{
+					Name: flR53RecordDelZone,
+				},
+			},
+			Records: *arR53RecordDelName,
+			Policy:  dns.Record{SetID: *flR53RecordDelSetID},
+		}
+		d.DelRecords()
+
+	// katoctl r53 record list:
+	case cmdR53RecordList.FullCommand():
+		d := Data{
+			APIKey: *flR53APIKey,
+			Zone: zoneData{
+				HostedZone: route53.HostedZone{
+					Name: flR53RecordListZone,
+				},
+			},
+			Output: *flR53RecordListOutput,
+		}
+		d.PrintRecords()
+
 	// Nothing to do:
 	default:
 		return false
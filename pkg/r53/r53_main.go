@@ -7,8 +7,8 @@ package r53
 import (
 
 	// Stdlib:
+	"errors"
 	"os"
-	"os/exec"
 	"strings"
 	"time"
 
@@ -17,6 +17,9 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
 
+	// Local:
+	"github.com/katosys/kato/pkg/dns"
+
 	// Community:
 	log "github.com/Sirupsen/logrus"
 )
@@ -38,6 +41,20 @@ type Data struct {
 	Zone    zoneData
 	Records []string
 	Zones   []string
+	Output  string
+	Policy  dns.Record // TTL, routing policy and alias of the added records
+	VpcID   string     // Private zones are associated with this VPC
+	Region  string     // and region
+}
+
+//-----------------------------------------------------------------------------
+// func: init
+//-----------------------------------------------------------------------------
+
+func init() {
+	dns.Register("r53", func(apiKey string) dns.Provider {
+		return &Data{APIKey: apiKey, command: "dns"}
+	})
 }
 
 //-----------------------------------------------------------------------------
@@ -50,29 +67,89 @@ func (d *Data) AddRecords() {
 	// Set the current command:
 	d.command = "record:add"
 
-	// Create the service handler:
-	d.r53 = route53.New(session.Must(session.NewSession()))
+	// Parse the requested records:
+	zone := *d.Zone.HostedZone.Name
+	records, err := dns.ParseRecords(d.Records)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+			Fatal(err)
+	}
 
-	// Get the zone data:
-	zone := normalizeZoneName(*d.Zone.HostedZone.Name)
-	*d.Zone.HostedZone.Name = zone
-	if _, err := d.getZone(zone); err != nil {
+	// Validate the routing policy:
+	if err := validatePolicy(d.Policy); err != nil {
 		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
 			Fatal(err)
 	}
 
-	// Return if zone is missing:
-	if d.Zone.Id == nil || *d.Zone.Id == "" {
+	// Apply the TTL, routing policy and alias:
+	for i := range records {
+		records[i].TTL = d.Policy.TTL
+		records[i].SetID = d.Policy.SetID
+		records[i].Weight = d.Policy.Weight
+		records[i].Region = d.Policy.Region
+		records[i].HealthCheck = d.Policy.HealthCheck
+		records[i].Alias = d.Policy.Alias
+	}
+
+	// Add or update the records:
+	if err := d.UpsertRecords(zone, records); err != nil {
 		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
-			Fatal("Ops! This zone does not exist")
+			Fatal(err)
 	}
+}
 
-	// For each requested record:
-	for _, record := range d.Records {
-		if err := d.addRecord(record); err != nil {
-			log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": record}).
-				Fatal(err)
-		}
+//-----------------------------------------------------------------------------
+// func: DelRecords
+//-----------------------------------------------------------------------------
+
+// DelRecords deletes one or more records from a Route 53 zone.
+func (d *Data) DelRecords() {
+
+	// Set the current command:
+	d.command = "record:del"
+
+	// Parse the requested records:
+	zone := *d.Zone.HostedZone.Name
+	records, err := dns.ParseRecords(d.Records)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+			Fatal(err)
+	}
+
+	// Record sets sharing name and type are told apart by their identifier:
+	for i := range records {
+		records[i].SetID = d.Policy.SetID
+	}
+
+	// Delete the records:
+	if err := d.DeleteRecords(zone, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+			Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: PrintRecords
+//-----------------------------------------------------------------------------
+
+// PrintRecords prints all the records of a Route 53 zone.
+func (d *Data) PrintRecords() {
+
+	// Set the current command:
+	d.command = "record:list"
+
+	// Get the records:
+	zone := *d.Zone.HostedZone.Name
+	records, err := d.ListRecords(zone)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+			Fatal(err)
+	}
+
+	// Print the records:
+	if err := dns.PrintRecords(os.Stdout, d.Output, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+			Fatal(err)
 	}
 }
 
@@ -86,127 +163,466 @@ func (d *Data) AddZones() {
 	// Set the current command:
 	d.command = "zone:add"
 
-	// Create the service handler:
-	d.r53 = route53.New(session.Must(session.NewSession()))
+	// Private zones need a region:
+	if d.VpcID != "" && d.Region == "" {
+		log.WithField("cmd", "r53:"+d.command).
+			Fatal("Private zones need the VPC region")
+	}
 
 	// For each requested zone:
 	for _, zone := range d.Zones {
-
-		// Normalize the zone name:
-		zone = normalizeZoneName(zone)
-		d.Zone.HostedZone.Name = &zone
-
-		// Add the child zone:
-		if err := d.addZone(); err != nil {
+		if err := d.AddPrivateZone(zone, d.VpcID, d.Region); err != nil {
 			log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
 				Fatal(err)
 		}
+	}
+}
 
-		// Get the parent zone:
-		pZone, err := d.getParentZone()
-		if err != nil {
+//-----------------------------------------------------------------------------
+// func: DelZones
+//-----------------------------------------------------------------------------
+
+// DelZones deletes one or more zones from Route 53.
+func (d *Data) DelZones() {
+
+	// Set the current command:
+	d.command = "zone:del"
+
+	// For each requested zone:
+	for _, zone := range d.Zones {
+		if err := d.DelZone(zone); err != nil {
 			log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
 				Fatal(err)
 		}
+	}
+}
 
-		// If any:
-		if pZone != "" {
-			if err := d.delegateZone(pZone); err != nil {
-				log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
-					Fatal(err)
-			}
+//-----------------------------------------------------------------------------
+// func: AddZone
+//-----------------------------------------------------------------------------
+
+// AddZone adds a zone to Route 53 and delegates it from its parent zone.
+func (d *Data) AddZone(zone string) error {
+	return d.AddPrivateZone(zone, "", "")
+}
+
+//-----------------------------------------------------------------------------
+// func: AddPrivateZone
+//-----------------------------------------------------------------------------
+
+// AddPrivateZone adds a zone to Route 53 only resolvable from within the
+// given VPC. Public zones are added when vpcID is empty.
+func (d *Data) AddPrivateZone(zone, vpcID, region string) error {
+
+	// Normalize the zone name:
+	d.connect()
+	zone = normalizeZoneName(zone)
+	d.Zone = zoneData{}
+	d.Zone.HostedZone.Name = &zone
+
+	// Add the child zone:
+	if err := d.addZone(vpcID, region); err != nil {
+		return err
+	}
+
+	// Private zones are not delegated:
+	if vpcID != "" {
+		return nil
+	}
+
+	// Get the parent zone:
+	pZone, err := d.getParentZone()
+	if err != nil {
+		return err
+	}
+
+	// If any:
+	if pZone != "" {
+		return d.delegateZone(pZone)
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: DelZone
+//-----------------------------------------------------------------------------
+
+// DelZone deletes a zone and its delegation from Route 53.
+func (d *Data) DelZone(zone string) error {
+
+	// Normalize the zone name:
+	d.connect()
+	zone = normalizeZoneName(zone)
+	d.Zone = zoneData{}
+	d.Zone.HostedZone.Name = &zone
+
+	// Delete the child zone:
+	if err := d.delZone(); err != nil {
+		return err
+	}
+
+	// Get the parent zone:
+	pZone, err := d.getParentZone()
+	if err != nil {
+		return err
+	}
+
+	// If any:
+	if pZone != "" {
+		return d.undelegateZone(pZone)
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: UpsertRecords
+//-----------------------------------------------------------------------------
+
+// UpsertRecords adds or updates records in a Route 53 zone.
+func (d *Data) UpsertRecords(zone string, records []dns.Record) error {
+
+	// Get the zone data:
+	if err := d.useZone(zone); err != nil {
+		return err
+	}
+
+	// For each requested record:
+	for _, record := range records {
+		if err := d.addRecord(record); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
 
-		// Clean zone data:
-		d.Zone.Id = nil
+//-----------------------------------------------------------------------------
+// func: DeleteRecords
+//-----------------------------------------------------------------------------
+
+// DeleteRecords deletes records from a Route 53 zone.
+func (d *Data) DeleteRecords(zone string, records []dns.Record) error {
+
+	// Get the zone data:
+	if err := d.useZone(zone); err != nil {
+		return err
+	}
+
+	// For each requested record:
+	for _, record := range records {
+		if err := d.delRecord(record); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
 
 //-----------------------------------------------------------------------------
-// func: DelZones
+// func: ListRecords
 //-----------------------------------------------------------------------------
 
-// DelZones deletes one or more zones from Route 53.
-func (d *Data) DelZones() {
+// ListRecords returns all the records of a Route 53 zone.
+func (d *Data) ListRecords(zone string) ([]dns.Record, error) {
 
-	// Set the current command:
-	d.command = "zone:del"
+	// Get the zone data:
+	if err := d.useZone(zone); err != nil {
+		return nil, err
+	}
 
-	// Create the service handler:
-	d.r53 = route53.New(session.Must(session.NewSession()))
+	// Forge the record list request:
+	zone = *d.Zone.HostedZone.Name
+	params := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(*d.Zone.Id),
+	}
 
-	// For each requested zone:
-	for _, zone := range d.Zones {
+	// Collect all the records:
+	records := []dns.Record{}
+	if err := d.r53.ListResourceRecordSetsPages(params,
+		func(page *route53.ListResourceRecordSetsOutput, last bool) bool {
+			for _, rrs := range page.ResourceRecordSets {
+				records = append(records, newRecord(rrs, zone))
+			}
+			return true
+		}); err != nil {
+		return nil, err
+	}
 
-		// Normalize the zone name:
-		zone = normalizeZoneName(zone)
-		d.Zone.HostedZone.Name = &zone
+	return records, nil
+}
 
-		// Delete the child zone:
-		if err := d.delZone(); err != nil {
-			log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": d.Zone}).
-				Fatal(err)
+//-----------------------------------------------------------------------------
+// func: connect
+//-----------------------------------------------------------------------------
+
+func (d *Data) connect() {
+	if d.r53 == nil {
+		d.r53 = route53.New(session.Must(session.NewSession()))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: useZone
+//-----------------------------------------------------------------------------
+
+func (d *Data) useZone(zone string) error {
+
+	// Get the zone data:
+	d.connect()
+	zone = normalizeZoneName(zone)
+	d.Zone = zoneData{}
+	d.Zone.HostedZone.Name = &zone
+	if _, err := d.getZone(zone); err != nil {
+		return err
+	}
+
+	// Return if zone is missing:
+	if d.Zone.Id == nil || *d.Zone.Id == "" {
+		return errors.New("Ops! This zone does not exist: " + zone)
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: newRecord
+//-----------------------------------------------------------------------------
+
+func newRecord(rrs *route53.ResourceRecordSet, zone string) dns.Record {
+
+	// Names are relative to the zone:
+	name := strings.TrimSuffix(*rrs.Name, "."+zone)
+	if *rrs.Name == zone {
+		name = "@"
+	}
+
+	record := dns.Record{
+		Name:        name,
+		Type:        *rrs.Type,
+		TTL:         aws.Int64Value(rrs.TTL),
+		SetID:       aws.StringValue(rrs.SetIdentifier),
+		Weight:      rrs.Weight,
+		Region:      aws.StringValue(rrs.Region),
+		HealthCheck: aws.StringValue(rrs.HealthCheckId),
+	}
+	for _, rr := range rrs.ResourceRecords {
+		record.Data = append(record.Data, *rr.Value)
+	}
+
+	// Alias target:
+	if a := rrs.AliasTarget; a != nil {
+		record.Alias = &dns.Alias{
+			DNSName:        aws.StringValue(a.DNSName),
+			ZoneID:         aws.StringValue(a.HostedZoneId),
+			EvaluateHealth: aws.BoolValue(a.EvaluateTargetHealth),
 		}
 	}
+
+	return record
+}
+
+//-----------------------------------------------------------------------------
+// func: validatePolicy
+//-----------------------------------------------------------------------------
+
+func validatePolicy(p dns.Record) error {
+
+	switch {
+	case (p.Weight != nil || p.Region != "") && p.SetID == "":
+		return errors.New("Weighted and latency-based records need a set identifier")
+	case p.Weight != nil && p.Region != "":
+		return errors.New("A record is either weighted or latency-based")
+	case p.Weight != nil && (*p.Weight < 0 || *p.Weight > 255):
+		return errors.New("The weight must be between 0 and 255")
+	case p.SetID != "" && p.Weight == nil && p.Region == "":
+		return errors.New("A set identifier needs a weight or a region")
+	case p.Alias != nil && (p.Alias.DNSName == "" || p.Alias.ZoneID == ""):
+		return errors.New("Alias records need a target DNS name and zone ID")
+	}
+
+	return nil
 }
 
 //-----------------------------------------------------------------------------
 // func: addRecord
 //-----------------------------------------------------------------------------
 
-func (d *Data) addRecord(record string) error {
+func (d *Data) addRecord(record dns.Record) error {
 
-	// Split into name:type:data
-	s := strings.Split(record, ":")
-	resourceName := s[0]
-	resourceType := s[1]
-	resourceData := s[2]
+	// Send the change request:
+	name, err := d.changeRecord("UPSERT", record)
+	if err != nil {
+		return err
+	}
+
+	// Log record creation:
+	log.WithFields(log.Fields{"cmd": "r53:" + d.command,
+		"id": name}).Info("DNS record created/updated")
 
-	// Resource records (innermost matryoshka):
-	resourceRecords := []*route53.ResourceRecord{}
-	for _, resource := range strings.Split(resourceData, ",") {
-		resourceRecords = append(resourceRecords, &route53.ResourceRecord{
-			Value: aws.String(resource),
-		})
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: delRecord
+//-----------------------------------------------------------------------------
+
+func (d *Data) delRecord(record dns.Record) error {
+
+	// A DELETE must match the existing TTL and values:
+	name := fqdn(record.Name, *d.Zone.HostedZone.Name)
+	rrs, err := d.getRecordSet(name, record.Type, record.SetID)
+	if err != nil {
+		return err
 	}
 
-	// Changes (middle matryoshka):
-	zone := *d.Zone.HostedZone.Name
-	changes := []*route53.Change{{
-		Action: aws.String("UPSERT"),
-		ResourceRecordSet: &route53.ResourceRecordSet{
-			Name:            aws.String(resourceName + "." + zone),
-			Type:            aws.String(resourceType),
-			TTL:             aws.Int64(300),
-			ResourceRecords: resourceRecords,
+	// Return if the record is missing:
+	if rrs == nil {
+		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": name}).
+			Info("Ops! this record does not exist")
+		return nil
+	}
+
+	// Send the change request:
+	if _, err := d.r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(*d.Zone.Id),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: rrs,
+			}},
 		},
-	}}
+	}); err != nil {
+		return err
+	}
+
+	// Log record deletion:
+	log.WithFields(log.Fields{"cmd": "r53:" + d.command,
+		"id": name}).Info("DNS record deleted")
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: getRecordSet
+//-----------------------------------------------------------------------------
+
+func (d *Data) getRecordSet(name, rtype, setID string) (*route53.ResourceRecordSet, error) {
+
+	// Forge the record list request:
+	params := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(*d.Zone.Id),
+		MaxItems:        aws.String("1"),
+		StartRecordName: aws.String(name),
+		StartRecordType: aws.String(rtype),
+	}
+
+	// Record sets sharing name and type are told apart by their identifier:
+	if setID != "" {
+		params.StartRecordIdentifier = aws.String(setID)
+	}
+
+	// Send the record list request:
+	resp, err := d.r53.ListResourceRecordSets(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Record sets are sorted, the first one is ours if it exists:
+	if len(resp.ResourceRecordSets) < 1 ||
+		!strings.EqualFold(*resp.ResourceRecordSets[0].Name, name) ||
+		*resp.ResourceRecordSets[0].Type != rtype ||
+		aws.StringValue(resp.ResourceRecordSets[0].SetIdentifier) != setID {
+		return nil, nil
+	}
+
+	return resp.ResourceRecordSets[0], nil
+}
+
+//-----------------------------------------------------------------------------
+// func: changeRecord
+//-----------------------------------------------------------------------------
+
+func (d *Data) changeRecord(action string, record dns.Record) (string, error) {
+
+	// Record set (middle matryoshka):
+	name := fqdn(record.Name, *d.Zone.HostedZone.Name)
+	rrs := &route53.ResourceRecordSet{
+		Name: aws.String(name),
+		Type: aws.String(record.Type),
+	}
+
+	// Alias records have no TTL nor values:
+	if record.Alias != nil {
+		rrs.AliasTarget = &route53.AliasTarget{
+			DNSName:              aws.String(record.Alias.DNSName),
+			HostedZoneId:         aws.String(record.Alias.ZoneID),
+			EvaluateTargetHealth: aws.Bool(record.Alias.EvaluateHealth),
+		}
+	} else {
+
+		// Default TTL:
+		rrs.TTL = aws.Int64(record.TTL)
+		if record.TTL == 0 {
+			rrs.TTL = aws.Int64(300)
+		}
+
+		// Resource records (innermost matryoshka):
+		for _, resource := range record.Data {
+			rrs.ResourceRecords = append(rrs.ResourceRecords, &route53.ResourceRecord{
+				Value: aws.String(resource),
+			})
+		}
+	}
+
+	// Weighted and latency-based routing:
+	if record.SetID != "" {
+		rrs.SetIdentifier = aws.String(record.SetID)
+	}
+	if record.Weight != nil {
+		rrs.Weight = aws.Int64(*record.Weight)
+	}
+	if record.Region != "" {
+		rrs.Region = aws.String(record.Region)
+	}
+	if record.HealthCheck != "" {
+		rrs.HealthCheckId = aws.String(record.HealthCheck)
+	}
 
 	// Forge the change request (outermost matryoshka):
 	params := &route53.ChangeResourceRecordSetsInput{
 		HostedZoneId: aws.String(*d.Zone.Id),
 		ChangeBatch: &route53.ChangeBatch{
-			Changes: changes,
+			Changes: []*route53.Change{{
+				Action:            aws.String(action),
+				ResourceRecordSet: rrs,
+			}},
 		},
 	}
 
 	// Send the change request:
-	if _, err := d.r53.ChangeResourceRecordSets(params); err != nil {
-		return err
-	}
+	_, err := d.r53.ChangeResourceRecordSets(params)
 
-	// Log record creation:
-	log.WithFields(log.Fields{"cmd": "r53:" + d.command,
-		"id": resourceName + "." + zone}).Info("DNS record created/updated")
+	return name, err
+}
 
-	return nil
+//-----------------------------------------------------------------------------
+// func: fqdn
+//-----------------------------------------------------------------------------
+
+func fqdn(name, zone string) string {
+	if name == "@" {
+		return zone
+	}
+	return name + "." + zone
 }
 
 //-----------------------------------------------------------------------------
 // func: addZone
 //-----------------------------------------------------------------------------
 
-func (d *Data) addZone() error {
+func (d *Data) addZone(vpcID, region string) error {
 
 	// Get the zone data:
 	zone := *d.Zone.HostedZone.Name
@@ -223,6 +639,14 @@ func (d *Data) addZone() error {
 			Name:            aws.String(zone),
 		}
 
+		// Private zones are associated with a VPC:
+		if vpcID != "" {
+			params.VPC = &route53.VPC{
+				VPCId:     aws.String(vpcID),
+				VPCRegion: aws.String(region),
+			}
+		}
+
 		// Send the new zone request:
 		if _, err := d.r53.CreateHostedZone(params); err != nil {
 			return err
@@ -237,11 +661,18 @@ func (d *Data) addZone() error {
 		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
 			Info("New DNS zone created")
 
-	} else {
+		return nil
+	}
+
+	// Log zone already exists:
+	log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+		Info("DNS zone already exists")
 
-		// Log zone already exists:
+	// Public and private zones are not converted:
+	private := d.Zone.Config != nil && aws.BoolValue(d.Zone.Config.PrivateZone)
+	if private != (vpcID != "") {
 		log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
-			Info("DNS zone already exists")
+			Warning("DNS zone privacy differs from the requested one")
 	}
 
 	return nil
@@ -262,6 +693,11 @@ func (d *Data) delZone() error {
 	// If the zone exists:
 	if d.Zone.Id != nil && *d.Zone.Id != "" {
 
+		// Delete all the zone records:
+		if err := d.purgeZone(); err != nil {
+			return err
+		}
+
 		// Forge the delete zone request:
 		params := &route53.DeleteHostedZoneInput{
 			Id: aws.String(*d.Zone.Id),
@@ -286,6 +722,58 @@ func (d *Data) delZone() error {
 	return nil
 }
 
+//-----------------------------------------------------------------------------
+// func: purgeZone
+//-----------------------------------------------------------------------------
+
+func (d *Data) purgeZone() error {
+
+	// Forge the record list request:
+	zone := *d.Zone.HostedZone.Name
+	params := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(*d.Zone.Id),
+	}
+
+	// Collect all the records but the zone SOA and NS:
+	changes := []*route53.Change{}
+	if err := d.r53.ListResourceRecordSetsPages(params,
+		func(page *route53.ListResourceRecordSetsOutput, last bool) bool {
+			for _, rrs := range page.ResourceRecordSets {
+				if *rrs.Name == zone && (*rrs.Type == "SOA" || *rrs.Type == "NS") {
+					continue
+				}
+				changes = append(changes, &route53.Change{
+					Action:            aws.String("DELETE"),
+					ResourceRecordSet: rrs,
+				})
+			}
+			return true
+		}); err != nil {
+		return err
+	}
+
+	// Return if empty:
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// Send the change request:
+	if _, err := d.r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(*d.Zone.Id),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: changes,
+		},
+	}); err != nil {
+		return err
+	}
+
+	// Log records deletion:
+	log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+		Info("DNS zone records deleted")
+
+	return nil
+}
+
 //-----------------------------------------------------------------------------
 // func: getParentZone
 //-----------------------------------------------------------------------------
@@ -383,17 +871,65 @@ func (d *Data) delegateZone(pZone string) error {
 		ns = append(ns, *record.Value)
 	}
 
-	// Forge the 'record add' command:
+	// Add the NS record to the parent zone:
 	zone := strings.Replace(*d.Zone.HostedZone.Name, "."+pZone, "", 1)
-	cmd := exec.Command("katoctl", "r53", "record", "add",
-		"--zone", pZone, zone+":NS:"+strings.Join(ns, ","))
+	parent := &Data{r53: d.r53, command: d.command}
+	return parent.UpsertRecords(pZone, []dns.Record{
+		{Name: zone, Type: "NS", Data: ns},
+	})
+}
+
+//-----------------------------------------------------------------------------
+// func: undelegateZone
+//-----------------------------------------------------------------------------
+
+func (d *Data) undelegateZone(pZone string) error {
 
-	// Execute the 'record add' command:
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	// Get the parent zone ID:
+	zone := *d.Zone.HostedZone.Name
+	id, err := d.getZone(pZone)
+	if err != nil || id == "" {
 		return err
 	}
 
+	// Forge the NS record list request:
+	params := &route53.ListResourceRecordSetsInput{
+		HostedZoneId:    aws.String(id),
+		MaxItems:        aws.String("1"),
+		StartRecordName: aws.String(zone),
+		StartRecordType: aws.String("NS"),
+	}
+
+	// Send the NS record list request:
+	resp, err := d.r53.ListResourceRecordSets(params)
+	if err != nil {
+		return err
+	}
+
+	// Return if not delegated:
+	if len(resp.ResourceRecordSets) < 1 ||
+		*resp.ResourceRecordSets[0].Name != zone ||
+		*resp.ResourceRecordSets[0].Type != "NS" {
+		return nil
+	}
+
+	// Send the change request:
+	if _, err := d.r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(id),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{{
+				Action:            aws.String("DELETE"),
+				ResourceRecordSet: resp.ResourceRecordSets[0],
+			}},
+		},
+	}); err != nil {
+		return err
+	}
+
+	// Log delegation removal:
+	log.WithFields(log.Fields{"cmd": "r53:" + d.command, "id": zone}).
+		Info("DNS zone delegation removed from " + pZone)
+
 	return nil
 }
 
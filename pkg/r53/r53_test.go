@@ -1 +1,63 @@
 package r53
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/katosys/kato/pkg/dns"
+)
+
+var _ dns.PrivateProvider = (*Data)(nil)
+
+func TestNewRecord(t *testing.T) {
+
+	for _, tc := range []struct {
+		rrs      *route53.ResourceRecordSet
+		expected dns.Record
+	}{
+		{
+			&route53.ResourceRecordSet{
+				Name: aws.String("example.com."), Type: aws.String("NS"), TTL: aws.Int64(172800),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("ns-1.")}, {Value: aws.String("ns-2.")}},
+			},
+			dns.Record{Name: "@", Type: "NS", TTL: 172800, Data: []string{"ns-1.", "ns-2."}},
+		},
+		{
+			&route53.ResourceRecordSet{
+				Name: aws.String("worker-1.example.com."), Type: aws.String("A"), TTL: aws.Int64(300),
+				ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.1.5")}},
+			},
+			dns.Record{Name: "worker-1", Type: "A", TTL: 300, Data: []string{"10.0.1.5"}},
+		},
+	} {
+		if r := newRecord(tc.rrs, "example.com."); !reflect.DeepEqual(r, tc.expected) {
+			t.Errorf("expected %+v, got %+v", tc.expected, r)
+		}
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+
+	w := func(i int64) *int64 { return &i }
+
+	for _, tc := range []struct {
+		policy dns.Record
+		valid  bool
+	}{
+		{dns.Record{TTL: 60}, true},
+		{dns.Record{SetID: "a", Weight: w(0)}, true},
+		{dns.Record{SetID: "a", Region: "eu-west-1", HealthCheck: "hc-1"}, true},
+		{dns.Record{Alias: &dns.Alias{DNSName: "kato.elb.amazonaws.com", ZoneID: "Z1"}}, true},
+		{dns.Record{Weight: w(10)}, false},
+		{dns.Record{SetID: "a"}, false},
+		{dns.Record{SetID: "a", Weight: w(10), Region: "eu-west-1"}, false},
+		{dns.Record{SetID: "a", Weight: w(256)}, false},
+		{dns.Record{Alias: &dns.Alias{DNSName: "kato.elb.amazonaws.com"}}, false},
+	} {
+		if err := validatePolicy(tc.policy); (err == nil) != tc.valid {
+			t.Errorf("validatePolicy(%+v): expected valid=%v, got %v", tc.policy, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package rfc2136
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+	"github.com/katosys/kato/pkg/cli"
+)
+
+//-----------------------------------------------------------------------------
+// 'katoctl rfc2136' command flags definitions:
+//-----------------------------------------------------------------------------
+
+var (
+
+	// rfc2136 zone/record:
+	cmdRfc2136 = cli.App.Command("rfc2136",
+		"Manages zones and records with RFC 2136 dynamic updates.")
+	flRfc2136APIKey = cmdRfc2136.Flag("api-key",
+		"[[<algorithm>:]<tsig-key-name>:<tsig-secret>@]<server>[:<port>]").
+		Required().String()
+	cmdRfc2136Zone   = cmdRfc2136.Command("zone", "Manage RFC 2136 zones.")
+	cmdRfc2136Record = cmdRfc2136.Command("record", "Manage RFC 2136 records.")
+
+	// rfc2136 zone add:
+	cmdRfc2136ZoneAdd = cmdRfc2136Zone.Command("add",
+		"Checks and delegates zones configured on the name server.")
+	arRfc2136ZoneAddName = cmdRfc2136ZoneAdd.Arg("fqdn",
+		"List of zones to publish.").Required().Strings()
+
+	// rfc2136 zone del:
+	cmdRfc2136ZoneDel = cmdRfc2136Zone.Command("del",
+		"Empties zones and removes their delegation.")
+	arRfc2136ZoneDelName = cmdRfc2136ZoneDel.Arg("fqdn",
+		"List of zones to delete.").Required().Strings()
+
+	// rfc2136 record add:
+	cmdRfc2136RecordAdd    = cmdRfc2136Record.Command("add", "Adds records to zones.")
+	flRfc2136RecordAddZone = cmdRfc2136RecordAdd.Flag("zone",
+		"DNS zone where records are added.").Required().String()
+	arRfc2136RecordAddName = cmdRfc2136RecordAdd.Arg("record",
+		"List of name:type:data records.").Required().Strings()
+
+	// rfc2136 record del:
+	cmdRfc2136RecordDel    = cmdRfc2136Record.Command("del", "Deletes records from zones.")
+	flRfc2136RecordDelZone = cmdRfc2136RecordDel.Flag("zone",
+		"DNS zone where records are deleted.").Required().String()
+	arRfc2136RecordDelName = cmdRfc2136RecordDel.Arg("record",
+		"List of name:type[:data] records.").Required().Strings()
+
+	// rfc2136 record list:
+	cmdRfc2136RecordList    = cmdRfc2136Record.Command("list", "Lists the records of a zone.")
+	flRfc2136RecordListZone = cmdRfc2136RecordList.Flag("zone",
+		"DNS zone whose records are listed.").Required().String()
+	flRfc2136RecordListOutput = cmdRfc2136RecordList.Flag("output",
+		"Output format [ table | json ]").
+		Short('o').Default("table").Enum("table", "json")
+)
+
+//-----------------------------------------------------------------------------
+// RunCmd:
+//-----------------------------------------------------------------------------
+
+// RunCmd runs the cmd if owned by this package.
+func RunCmd(cmd string) bool {
+
+	switch cmd {
+
+	// katoctl rfc2136 zone add:
+	case cmdRfc2136ZoneAdd.FullCommand():
+		d := Data{
+			APIKey: *flRfc2136APIKey,
+			Zones:  *arRfc2136ZoneAddName,
+		}
+		d.AddZones()
+
+	// katoctl rfc2136 zone del:
+	case cmdRfc2136ZoneDel.FullCommand():
+		d := Data{
+			APIKey: *flRfc2136APIKey,
+			Zones:  *arRfc2136ZoneDelName,
+		}
+		d.DelZones()
+
+	// katoctl rfc2136 record add:
+	case cmdRfc2136RecordAdd.FullCommand():
+		d := Data{
+			APIKey:  *flRfc2136APIKey,
+			Zone:    *flRfc2136RecordAddZone,
+			Records: *arRfc2136RecordAddName,
+		}
+		d.AddRecords()
+
+	// katoctl rfc2136 record del:
+	case cmdRfc2136RecordDel.FullCommand():
+		d := Data{
+			APIKey:  *flRfc2136APIKey,
+			Zone:    *flRfc2136RecordDelZone,
+			Records: *arRfc2136RecordDelName,
+		}
+		d.DelRecords()
+
+	// katoctl rfc2136 record list:
+	case cmdRfc2136RecordList.FullCommand():
+		d := Data{
+			APIKey: *flRfc2136APIKey,
+			Zone:   *flRfc2136RecordListZone,
+			Output: *flRfc2136RecordListOutput,
+		}
+		d.PrintRecords()
+
+	// Nothing to do:
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,617 @@
+package rfc2136
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	// Local:
+	"github.com/katosys/kato/pkg/dns"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+	miekg "github.com/miekg/dns"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Data struct for RFC 2136 dynamic updates.
+type Data struct {
+	command string
+	key     *tsigKey
+	APIKey  string
+	Zone    string
+	Zones   []string
+	Records []string
+	Output  string
+}
+
+// TSIG key and name server parsed from the API key.
+type tsigKey struct {
+	algorithm string
+	name      string
+	secret    string
+	server    string
+}
+
+//-----------------------------------------------------------------------------
+// func: init
+//-----------------------------------------------------------------------------
+
+func init() {
+	dns.Register("rfc2136", func(apiKey string) dns.Provider {
+		return &Data{APIKey: apiKey, command: "dns"}
+	})
+}
+
+//-----------------------------------------------------------------------------
+// func: AddRecords
+//-----------------------------------------------------------------------------
+
+// AddRecords adds one or more records to a zone.
+func (d *Data) AddRecords() {
+
+	// Set the current command:
+	d.command = "record:add"
+
+	// Parse the requested records:
+	records, err := dns.ParseRecords(d.Records)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+
+	// Add or update the records:
+	if err := d.UpsertRecords(d.Zone, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: DelRecords
+//-----------------------------------------------------------------------------
+
+// DelRecords deletes one or more records from a zone.
+func (d *Data) DelRecords() {
+
+	// Set the current command:
+	d.command = "record:del"
+
+	// Parse the requested records:
+	records, err := dns.ParseRecords(d.Records)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+
+	// Delete the records:
+	if err := d.DeleteRecords(d.Zone, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: PrintRecords
+//-----------------------------------------------------------------------------
+
+// PrintRecords prints all the records of a zone.
+func (d *Data) PrintRecords() {
+
+	// Set the current command:
+	d.command = "record:list"
+
+	// Get the records:
+	records, err := d.ListRecords(d.Zone)
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+
+	// Print the records:
+	if err := dns.PrintRecords(os.Stdout, d.Output, records); err != nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": d.Zone}).
+			Fatal(err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: AddZones
+//-----------------------------------------------------------------------------
+
+// AddZones checks one or more zones and delegates them from their parents.
+func (d *Data) AddZones() {
+
+	// Set the current command:
+	d.command = "zone:add"
+
+	// For each requested zone:
+	for _, zone := range d.Zones {
+		if err := d.AddZone(zone); err != nil {
+			log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": zone}).
+				Fatal(err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: DelZones
+//-----------------------------------------------------------------------------
+
+// DelZones empties one or more zones and removes their delegations.
+func (d *Data) DelZones() {
+
+	// Set the current command:
+	d.command = "zone:del"
+
+	// For each requested zone:
+	for _, zone := range d.Zones {
+		if err := d.DelZone(zone); err != nil {
+			log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": zone}).
+				Fatal(err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: AddZone
+//-----------------------------------------------------------------------------
+
+// AddZone checks that the name server is authoritative for the zone and
+// delegates it from its parent zone. DNS UPDATE can't create zones, they
+// must be configured on the server beforehand.
+func (d *Data) AddZone(zone string) error {
+
+	zone = miekg.Fqdn(zone)
+
+	// The zone must exist:
+	ns, err := d.apex(zone, miekg.TypeNS)
+	if err != nil {
+		return err
+	}
+	if ns == nil {
+		return errors.New("Ops! This zone does not exist: " + zone +
+			" (zones must be configured on the name server)")
+	}
+
+	log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": zone}).
+		Info("DNS zone already exists")
+
+	// Get the parent zone (if any):
+	parent, err := d.parentZone(zone)
+	if err != nil || parent == "" {
+		return err
+	}
+
+	// Delegate the zone:
+	var servers []string
+	for _, rr := range ns {
+		servers = append(servers, rr.(*miekg.NS).Ns)
+	}
+
+	return d.UpsertRecords(parent, []dns.Record{{
+		Name: strings.TrimSuffix(zone, "."+parent), Type: "NS", Data: servers,
+	}})
+}
+
+//-----------------------------------------------------------------------------
+// func: DelZone
+//-----------------------------------------------------------------------------
+
+// DelZone deletes all the records of a zone but its SOA and NS, and removes
+// the delegation from its parent zone.
+func (d *Data) DelZone(zone string) error {
+
+	zone = miekg.Fqdn(zone)
+
+	// Return if the zone is missing:
+	soa, err := d.apex(zone, miekg.TypeSOA)
+	if err != nil {
+		return err
+	}
+	if soa == nil {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": zone}).
+			Info("Ops! this zone does not exist")
+		return nil
+	}
+
+	// Collect all the records but the zone SOA and NS:
+	records, err := d.ListRecords(zone)
+	if err != nil {
+		return err
+	}
+
+	var purge []dns.Record
+	for _, r := range records {
+		if r.Name == "@" && (r.Type == "SOA" || r.Type == "NS") {
+			continue
+		}
+		purge = append(purge, r)
+	}
+
+	// Delete them:
+	if err := d.DeleteRecords(zone, purge); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command, "id": zone}).
+		Info("DNS zone records deleted")
+
+	// Remove the delegation (if any):
+	parent, err := d.parentZone(zone)
+	if err != nil || parent == "" {
+		return err
+	}
+
+	return d.DeleteRecords(parent, []dns.Record{{
+		Name: strings.TrimSuffix(zone, "."+parent), Type: "NS",
+	}})
+}
+
+//-----------------------------------------------------------------------------
+// func: UpsertRecords
+//-----------------------------------------------------------------------------
+
+// UpsertRecords replaces the record sets with the same name and type.
+func (d *Data) UpsertRecords(zone string, records []dns.Record) error {
+
+	// Forge the update message:
+	zone = miekg.Fqdn(zone)
+	m := new(miekg.Msg)
+	m.SetUpdate(zone)
+
+	for _, record := range records {
+
+		// Route 53 only features:
+		if record.Routed() {
+			return errors.New("Routing policies and aliases are not supported by RFC 2136: " +
+				fqdn(record.Name, zone))
+		}
+
+		// Forge the new record set:
+		rrs, err := newRRs(zone, record)
+		if err != nil {
+			return err
+		}
+
+		// Replace the existing one:
+		m.RemoveRRset(rrs[:1])
+		m.Insert(rrs)
+	}
+
+	// Send the update:
+	if _, err := d.exchange(m); err != nil {
+		return err
+	}
+
+	// Log record creation:
+	for _, record := range records {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command,
+			"id": fqdn(record.Name, zone)}).Info("DNS record created/updated")
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: DeleteRecords
+//-----------------------------------------------------------------------------
+
+// DeleteRecords deletes the record sets with the same name and type.
+func (d *Data) DeleteRecords(zone string, records []dns.Record) error {
+
+	// Return if empty:
+	if len(records) == 0 {
+		return nil
+	}
+
+	// Forge the update message:
+	zone = miekg.Fqdn(zone)
+	m := new(miekg.Msg)
+	m.SetUpdate(zone)
+
+	for _, record := range records {
+		rtype, ok := miekg.StringToType[record.Type]
+		if !ok {
+			return errors.New("Unknown record type: " + record.Type)
+		}
+		m.RemoveRRset([]miekg.RR{&miekg.ANY{Hdr: miekg.RR_Header{
+			Name: fqdn(record.Name, zone), Rrtype: rtype, Class: miekg.ClassINET}}})
+	}
+
+	// Send the update, missing records are ignored by the server:
+	if _, err := d.exchange(m); err != nil {
+		return err
+	}
+
+	// Log record deletion:
+	for _, record := range records {
+		log.WithFields(log.Fields{"cmd": "rfc2136:" + d.command,
+			"id": fqdn(record.Name, zone)}).Info("DNS record deleted")
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: ListRecords
+//-----------------------------------------------------------------------------
+
+// ListRecords returns all the records of a zone via AXFR.
+func (d *Data) ListRecords(zone string) ([]dns.Record, error) {
+
+	// Parse the API key:
+	key, err := d.tsig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Forge the transfer request:
+	zone = miekg.Fqdn(zone)
+	m := new(miekg.Msg)
+	m.SetAxfr(zone)
+	t := &miekg.Transfer{}
+	if key.name != "" {
+		m.SetTsig(key.name, key.algorithm, 300, time.Now().Unix())
+		t.TsigSecret = map[string]string{key.name: key.secret}
+	}
+
+	// Send the transfer request:
+	ch, err := t.In(m, key.server)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group the records into record sets:
+	records := []dns.Record{}
+	index := map[string]int{}
+	for env := range ch {
+		if env.Error != nil {
+			return nil, env.Error
+		}
+		for _, rr := range env.RR {
+			r := newRecord(zone, rr)
+			data := r.Data[0]
+			i, ok := index[r.Name+" "+r.Type]
+			if !ok {
+				index[r.Name+" "+r.Type] = len(records)
+				records = append(records, r)
+				continue
+			}
+			if !contains(records[i].Data, data) {
+				records[i].Data = append(records[i].Data, data)
+			}
+		}
+	}
+
+	return records, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: tsig
+//-----------------------------------------------------------------------------
+
+func (d *Data) tsig() (*tsigKey, error) {
+	if d.key == nil {
+		key, err := parseKey(d.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		d.key = key
+	}
+	return d.key, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: parseKey
+//-----------------------------------------------------------------------------
+
+// The API key is [[<algorithm>:]<key-name>:<base64-secret>@]<server>[:<port>]
+func parseKey(apiKey string) (*tsigKey, error) {
+
+	key := &tsigKey{algorithm: miekg.HmacSHA256, server: apiKey}
+
+	// TSIG credentials:
+	if i := strings.LastIndex(apiKey, "@"); i >= 0 {
+		key.server = apiKey[i+1:]
+		s := strings.Split(apiKey[:i], ":")
+		switch len(s) {
+		case 3:
+			key.algorithm = miekg.Fqdn(strings.ToLower(s[0]))
+			s = s[1:]
+			fallthrough
+		case 2:
+			key.name, key.secret = miekg.Fqdn(strings.ToLower(s[0])), s[1]
+		default:
+			return nil, errors.New("Invalid TSIG key, expected [<algorithm>:]<name>:<secret>")
+		}
+	}
+
+	// Name server:
+	if key.server == "" || key.server == "none" {
+		return nil, errors.New("Missing name server in the API key")
+	}
+	if _, _, err := net.SplitHostPort(key.server); err != nil {
+		key.server = net.JoinHostPort(key.server, "53")
+	}
+
+	return key, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: exchange
+//-----------------------------------------------------------------------------
+
+func (d *Data) exchange(m *miekg.Msg) (*miekg.Msg, error) {
+
+	// Parse the API key:
+	key, err := d.tsig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Sign the message:
+	c := &miekg.Client{Net: "tcp"}
+	if key.name != "" {
+		m.SetTsig(key.name, key.algorithm, 300, time.Now().Unix())
+		c.TsigSecret = map[string]string{key.name: key.secret}
+	}
+
+	// Send the message:
+	r, _, err := c.Exchange(m, key.server)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Rcode != miekg.RcodeSuccess && r.Rcode != miekg.RcodeNameError {
+		return r, errors.New("DNS " + miekg.OpcodeToString[m.Opcode] + " " +
+			m.Question[0].Name + " failed: " + miekg.RcodeToString[r.Rcode])
+	}
+
+	return r, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: apex
+//-----------------------------------------------------------------------------
+
+// Return the apex records of the given type, nil if the server is not
+// authoritative for the zone.
+func (d *Data) apex(zone string, rtype uint16) ([]miekg.RR, error) {
+
+	// Send the query:
+	m := new(miekg.Msg)
+	m.SetQuestion(zone, rtype)
+	r, err := d.exchange(m)
+	if err != nil {
+		if r != nil && r.Rcode == miekg.RcodeRefused {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Only authoritative answers count:
+	if !r.Authoritative {
+		return nil, nil
+	}
+
+	var rrs []miekg.RR
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == rtype && strings.EqualFold(rr.Header().Name, zone) {
+			rrs = append(rrs, rr)
+		}
+	}
+
+	return rrs, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: parentZone
+//-----------------------------------------------------------------------------
+
+func (d *Data) parentZone(zone string) (string, error) {
+
+	// Iterate over parent zones:
+	labels := miekg.SplitDomainName(zone)
+	for i := 1; i < len(labels)-1; i++ {
+		parent := miekg.Fqdn(strings.Join(labels[i:], "."))
+		soa, err := d.apex(parent, miekg.TypeSOA)
+		if err != nil {
+			return "", err
+		}
+		if soa != nil {
+			return parent, nil
+		}
+	}
+
+	return "", nil
+}
+
+//-----------------------------------------------------------------------------
+// func: newRRs
+//-----------------------------------------------------------------------------
+
+func newRRs(zone string, record dns.Record) ([]miekg.RR, error) {
+
+	if len(record.Data) == 0 {
+		return nil, errors.New("Missing record data: " + record.String())
+	}
+
+	// Default TTL:
+	ttl := record.TTL
+	if ttl == 0 {
+		ttl = 300
+	}
+
+	var rrs []miekg.RR
+	for _, data := range record.Data {
+		rr, err := miekg.NewRR(strings.Join([]string{fqdn(record.Name, zone),
+			miekg.Class(miekg.ClassINET).String(), record.Type, data}, " "))
+		if err != nil {
+			return nil, err
+		}
+		if rr == nil {
+			return nil, errors.New("Invalid record: " + record.String())
+		}
+		rr.Header().Ttl = uint32(ttl)
+		rrs = append(rrs, rr)
+	}
+
+	return rrs, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: newRecord
+//-----------------------------------------------------------------------------
+
+func newRecord(zone string, rr miekg.RR) dns.Record {
+
+	// Names are relative to the zone:
+	hdr := rr.Header()
+	name := strings.TrimSuffix(hdr.Name, "."+zone)
+	if strings.EqualFold(hdr.Name, zone) {
+		name = "@"
+	}
+
+	return dns.Record{
+		Name: name,
+		Type: miekg.TypeToString[hdr.Rrtype],
+		TTL:  int64(hdr.Ttl),
+		Data: []string{strings.TrimPrefix(rr.String(), hdr.String())},
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: fqdn
+//-----------------------------------------------------------------------------
+
+func fqdn(name, zone string) string {
+	if name == "@" {
+		return zone
+	}
+	return miekg.Fqdn(name + "." + zone)
+}
+
+//-----------------------------------------------------------------------------
+// func: contains
+//-----------------------------------------------------------------------------
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
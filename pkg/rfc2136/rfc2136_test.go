@@ -0,0 +1,195 @@
+package rfc2136
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katosys/kato/pkg/dns"
+	miekg "github.com/miekg/dns"
+)
+
+const secret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ="
+
+// In-memory authoritative server accepting TSIG signed updates and AXFR.
+type server struct {
+	sync.Mutex
+	zones map[string][]miekg.RR
+}
+
+func (s *server) ServeDNS(w miekg.ResponseWriter, r *miekg.Msg) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	m := new(miekg.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	zone := strings.ToLower(r.Question[0].Name)
+
+	switch {
+
+	case r.IsTsig() == nil || w.TsigStatus() != nil:
+		m.Rcode = miekg.RcodeNotAuth
+
+	case r.Opcode == miekg.OpcodeUpdate:
+		if _, ok := s.zones[zone]; !ok {
+			m.Rcode = miekg.RcodeNotAuth
+			break
+		}
+		for _, rr := range r.Ns {
+			h := rr.Header()
+			var keep []miekg.RR
+			for _, old := range s.zones[zone] {
+				if h.Class == miekg.ClassANY && old.Header().Name == h.Name &&
+					old.Header().Rrtype == h.Rrtype {
+					continue
+				}
+				keep = append(keep, old)
+			}
+			if h.Class == miekg.ClassINET {
+				keep = append(keep, rr)
+			}
+			s.zones[zone] = keep
+		}
+
+	case r.Question[0].Qtype == miekg.TypeAXFR:
+		rrs := s.zones[zone]
+		ch := make(chan *miekg.Envelope, 1)
+		ch <- &miekg.Envelope{RR: append(append([]miekg.RR{}, rrs...), rrs[0])}
+		close(ch)
+		(&miekg.Transfer{}).Out(w, r, ch)
+		return
+
+	default:
+		rrs, ok := s.zones[zone]
+		if !ok {
+			m.Rcode = miekg.RcodeRefused
+			break
+		}
+		for _, rr := range rrs {
+			if rr.Header().Name == zone && rr.Header().Rrtype == r.Question[0].Qtype {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+	}
+
+	if r.IsTsig() != nil {
+		m.SetTsig(r.IsTsig().Hdr.Name, miekg.HmacSHA256, 300, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+}
+
+func newServer(t *testing.T, zones ...string) (*server, string, func()) {
+
+	s := &server{zones: map[string][]miekg.RR{}}
+	for _, zone := range zones {
+		soa, _ := miekg.NewRR(zone + " 3600 IN SOA ns1." + zone + " admin." + zone + " 1 3600 600 86400 300")
+		ns, _ := miekg.NewRR(zone + " 3600 IN NS ns1." + zone)
+		s.zones[zone] = []miekg.RR{soa, ns}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &miekg.Server{Listener: l, Net: "tcp", Handler: s,
+		TsigSecret: map[string]string{"kato.": secret}}
+	go srv.ActivateAndServe()
+
+	return s, l.Addr().String(), func() { srv.Shutdown() }
+}
+
+func TestParseKey(t *testing.T) {
+
+	for _, tc := range []struct {
+		in       string
+		expected tsigKey
+	}{
+		{"10.0.0.1", tsigKey{algorithm: miekg.HmacSHA256, server: "10.0.0.1:53"}},
+		{"Kato:c2VjcmV0@ns.example.com:5353", tsigKey{
+			algorithm: miekg.HmacSHA256, name: "kato.", secret: "c2VjcmV0", server: "ns.example.com:5353"}},
+		{"hmac-sha512:kato:c2VjcmV0@10.0.0.1", tsigKey{
+			algorithm: miekg.HmacSHA512, name: "kato.", secret: "c2VjcmV0", server: "10.0.0.1:53"}},
+	} {
+		key, err := parseKey(tc.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*key, tc.expected) {
+			t.Errorf("parseKey(%q): expected %+v, got %+v", tc.in, tc.expected, *key)
+		}
+	}
+
+	for _, in := range []string{"", "none", "kato@10.0.0.1", "a:b:c:d@10.0.0.1"} {
+		if _, err := parseKey(in); err == nil {
+			t.Errorf("parseKey(%q): expected an error", in)
+		}
+	}
+}
+
+func TestProvider(t *testing.T) {
+
+	s, addr, stop := newServer(t, "example.com.", "int.example.com.")
+	defer stop()
+
+	p, err := dns.New("rfc2136", "kato:"+secret+"@"+addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Zones must exist on the server:
+	if err := p.AddZone("ext.example.com"); err == nil {
+		t.Error("expected an error for a missing zone")
+	}
+
+	// The child zone is delegated from its parent:
+	if err := p.AddZone("int.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	records, err := p.ListRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !reflect.DeepEqual(records[2],
+		dns.Record{Name: "int", Type: "NS", TTL: 300, Data: []string{"ns1.int.example.com."}}) {
+		t.Errorf("unexpected delegation: %+v", records)
+	}
+
+	// Upserts replace the record set:
+	for _, data := range [][]string{{"10.0.0.1"}, {"10.0.0.2", "10.0.0.3"}} {
+		if err := p.UpsertRecords("int.example.com", []dns.Record{
+			{Name: "worker-1", Type: "A", TTL: 60, Data: data}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err = p.ListRecords("int.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !reflect.DeepEqual(records[2],
+		dns.Record{Name: "worker-1", Type: "A", TTL: 60, Data: []string{"10.0.0.2", "10.0.0.3"}}) {
+		t.Errorf("unexpected records: %+v", records)
+	}
+
+	// Wrong keys are rejected:
+	bad, _ := dns.New("rfc2136", "kato:"+strings.ToLower(secret)+"@"+addr)
+	if err := bad.UpsertRecords("int.example.com", []dns.Record{
+		{Name: "worker-2", Type: "A", Data: []string{"10.0.0.4"}}}); err == nil {
+		t.Error("expected an error for a wrong TSIG key")
+	}
+
+	// Deleting a zone keeps its SOA and NS and removes the delegation:
+	if err := p.DelZone("int.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	s.Lock()
+	defer s.Unlock()
+	if len(s.zones["int.example.com."]) != 2 || len(s.zones["example.com."]) != 2 {
+		t.Errorf("unexpected zones: %v", s.zones)
+	}
+}
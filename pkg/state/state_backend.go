@@ -0,0 +1,337 @@
+package state
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Backend stores the objects of a cluster state: <id>.json, <id>.spec.yaml
+// and <id>.secrets. Every object has an opaque version that changes on each
+// write, used to detect concurrent changes.
+type Backend interface {
+
+	// Get returns the object data and version, or a not exist error.
+	Get(name string) (data []byte, version string, err error)
+
+	// Put writes the object if its current version matches: any version if
+	// empty, no object at all if Absent. It returns the new version.
+	Put(name string, data []byte, match string) (version string, err error)
+
+	// Delete removes the object, if any.
+	Delete(name string) error
+
+	// String describes where the objects are.
+	String() string
+}
+
+// Lock held on an object, stored as the <name>.lock object. Locks with an
+// expiry are stale past it and can be taken over.
+type Lock struct {
+	Name      string     `json:"Name"`
+	ID        string     `json:"ID"`
+	Who       string     `json:"Who"`
+	Operation string     `json:"Operation"`
+	Created   time.Time  `json:"Created"`
+	Expires   *time.Time `json:"Expires,omitempty"`
+	file      *os.File
+}
+
+// LockedError is returned when someone else holds the lock.
+type LockedError struct {
+	Lock
+}
+
+// Absent matches objects that don't exist yet.
+const Absent = "*"
+
+// ErrConflict is returned by Put when the object version does not match.
+var ErrConflict = errors.New("Ops! the state changed meanwhile, read it again")
+
+// How long to wait for a lock held by someone else:
+var lockTimeout = 2 * time.Minute
+
+// How long a lock taken by katoctl lasts, a crashed process leaves it stale:
+var lockTTL = 10 * time.Minute
+
+//-----------------------------------------------------------------------------
+// func: Open
+//-----------------------------------------------------------------------------
+
+// Open the backend at the given URL:
+//
+//	(empty) or file:///path       local directory, $HOME/.kato by default
+//	s3://bucket/prefix            S3 with conditional writes, ?region=<region>
+//	                              and ?endpoint=<url> for S3 compatible stores
+//	http(s)://host/path           HTTP with ETag, If-Match and If-None-Match
+func Open(rawurl string) (Backend, error) {
+
+	// Local by default:
+	if rawurl == "" {
+		return Local(), nil
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return Local(), nil
+		}
+		return &local{dir: u.Path}, nil
+	case "s3":
+		return newS3(u)
+	case "http", "https":
+		return newHTTP(u), nil
+	}
+
+	return nil, errors.New("Ops! unsupported state backend " + rawurl)
+}
+
+//-----------------------------------------------------------------------------
+// func: Current
+//-----------------------------------------------------------------------------
+
+// Current opens the backend set in KATO_STATE_BACKEND, local if not set.
+func Current() (Backend, error) {
+	return Open(os.Getenv("KATO_STATE_BACKEND"))
+}
+
+//-----------------------------------------------------------------------------
+// func: TryLock
+//-----------------------------------------------------------------------------
+
+// TryLock locks the named object, or returns a LockedError with the current
+// holder. Locks are advisory and survive the process that took them, a
+// positive ttl makes them stale after that long and a stale lock is taken
+// over.
+func TryLock(b Backend, name, operation string, ttl time.Duration) (*Lock, error) {
+
+	// Lock identity:
+	l, err := newLock(name, operation, ttl)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the lock object, only if there is none:
+	_, err = b.Put(name+".lock", data, Absent)
+	if err == nil {
+		return l, nil
+	}
+	if err != ErrConflict {
+		return nil, err
+	}
+
+	// Held, unless released meanwhile:
+	raw, version, err := b.Get(name + ".lock")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrConflict
+		}
+		return nil, err
+	}
+	held := &Lock{}
+	if err := json.Unmarshal(raw, held); err != nil {
+		return nil, err
+	}
+	if !held.stale() {
+		return nil, &LockedError{*held}
+	}
+
+	// Take over the stale lock, only if nobody else did:
+	if _, err := b.Put(name+".lock", data, version); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: AcquireLock
+//-----------------------------------------------------------------------------
+
+// AcquireLock waits for the named object lock for a while. The lock expires
+// after lockTTL in case the process dies holding it. The local backend uses
+// flock(2) instead, released by the kernel with the process.
+func AcquireLock(b Backend, name, operation string) (*Lock, error) {
+
+	try := func() (*Lock, error) { return TryLock(b, name, operation, lockTTL) }
+	if l, ok := b.(*local); ok {
+		try = func() (*Lock, error) { return l.flock(name, operation) }
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	wait := 50 * time.Millisecond
+
+	for {
+		l, err := try()
+		if err == nil {
+			return l, nil
+		}
+		if _, ok := err.(*LockedError); !ok && err != ErrConflict {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+
+		// Back off:
+		time.Sleep(wait)
+		if wait *= 2; wait > time.Second {
+			wait = time.Second
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// func: Release
+//-----------------------------------------------------------------------------
+
+// Release releases a lock taken with AcquireLock.
+func Release(b Backend, l *Lock) error {
+	if l.file != nil {
+		defer l.file.Close()
+		return syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	}
+	return Unlock(b, l.Name, l.ID)
+}
+
+//-----------------------------------------------------------------------------
+// func: ReadLock
+//-----------------------------------------------------------------------------
+
+// ReadLock returns the lock held on the named object, nil if none.
+func ReadLock(b Backend, name string) (*Lock, error) {
+
+	data, _, err := b.Get(name + ".lock")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	l := &Lock{}
+	if err := json.Unmarshal(data, l); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Unlock
+//-----------------------------------------------------------------------------
+
+// Unlock releases the named object lock if it has the given ID. An empty ID
+// forces the release whoever holds it.
+func Unlock(b Backend, name, id string) error {
+
+	if id != "" {
+		held, err := ReadLock(b, name)
+		if err != nil {
+			return err
+		}
+		if held == nil {
+			return errors.New("Ops! " + name + " is not locked")
+		}
+		if held.ID != id {
+			return &LockedError{*held}
+		}
+	}
+
+	return b.Delete(name + ".lock")
+}
+
+//-----------------------------------------------------------------------------
+// func: Error
+//-----------------------------------------------------------------------------
+
+func (e *LockedError) Error() string {
+	msg := "Ops! " + e.Name + " is locked by " + e.Who + " (" + e.Operation + ") since " +
+		e.Created.Format(time.RFC3339)
+	if e.Expires != nil {
+		msg += " until " + e.Expires.Format(time.RFC3339)
+	}
+	return msg + ", lock ID " + e.ID
+}
+
+//-----------------------------------------------------------------------------
+// func: newLock
+//-----------------------------------------------------------------------------
+
+func newLock(name, operation string, ttl time.Duration) (*Lock, error) {
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+
+	l := &Lock{
+		Name:      name,
+		ID:        hex.EncodeToString(id),
+		Who:       os.Getenv("USER") + "@" + host,
+		Operation: operation,
+		Created:   time.Now().UTC(),
+	}
+	if ttl > 0 {
+		expires := l.Created.Add(ttl)
+		l.Expires = &expires
+	}
+
+	return l, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: stale
+//-----------------------------------------------------------------------------
+
+func (l *Lock) stale() bool {
+	return l.Expires != nil && time.Now().After(*l.Expires)
+}
+
+//-----------------------------------------------------------------------------
+// func: notFound
+//-----------------------------------------------------------------------------
+
+// Missing remote objects look like missing files to os.IsNotExist().
+func notFound(path string) error {
+	return &os.PathError{Op: "get", Path: path, Err: syscall.ENOENT}
+}
+
+//-----------------------------------------------------------------------------
+// func: unquote
+//-----------------------------------------------------------------------------
+
+// Versions are ETags without their quotes.
+func unquote(etag string) string {
+	if s, err := strconv.Unquote(etag); err == nil {
+		return s
+	}
+	return strings.Trim(etag, `"`)
+}
@@ -0,0 +1,191 @@
+package state
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+	"github.com/katosys/kato/pkg/cli"
+)
+
+//-----------------------------------------------------------------------------
+// 'katoctl state' command flags definitions:
+//-----------------------------------------------------------------------------
+
+var (
+
+	//--------------------------
+	// state: top level command
+	//--------------------------
+
+	cmdState = cli.App.Command("state", "Manages the state of a Káto cluster in its backend.")
+
+	//------------------------
+	// state pull: subcommand
+	//------------------------
+
+	cmdStatePull = cmdState.Command("pull",
+		"Prints the stored cluster state and logs its version.")
+
+	flStatePullBackend = cmdStatePull.Flag("backend",
+		"State backend URL: file://, s3:// or http(s)://").
+		PlaceHolder("KATO_STATE_BACKEND").
+		OverrideDefaultFromEnvar("KATO_STATE_BACKEND").
+		String()
+
+	flStatePullClusterID = cli.RegexpMatch(cmdStatePull.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_STATE_PULL_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_STATE_PULL_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flStatePullOutput = cmdStatePull.Flag("output",
+		"Write the state to this file instead of stdout.").
+		Short('o').PlaceHolder("KATO_STATE_PULL_OUTPUT").
+		OverrideDefaultFromEnvar("KATO_STATE_PULL_OUTPUT").
+		String()
+
+	//------------------------
+	// state push: subcommand
+	//------------------------
+
+	cmdStatePush = cmdState.Command("push",
+		"Stores a cluster state file, or migrates the local state, spec and secrets.")
+
+	flStatePushBackend = cmdStatePush.Flag("backend",
+		"State backend URL: file://, s3:// or http(s)://").
+		PlaceHolder("KATO_STATE_BACKEND").
+		OverrideDefaultFromEnvar("KATO_STATE_BACKEND").
+		String()
+
+	flStatePushClusterID = cli.RegexpMatch(cmdStatePush.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_STATE_PUSH_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_STATE_PUSH_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flStatePushVersion = cmdStatePush.Flag("version",
+		"Version of the stored state being replaced, as logged by pull.").
+		PlaceHolder("KATO_STATE_PUSH_VERSION").
+		OverrideDefaultFromEnvar("KATO_STATE_PUSH_VERSION").
+		String()
+
+	flStatePushLockID = cmdStatePush.Flag("lock-id",
+		"Push under this lock, taken with 'katoctl state lock'.").
+		PlaceHolder("KATO_STATE_PUSH_LOCK_ID").
+		OverrideDefaultFromEnvar("KATO_STATE_PUSH_LOCK_ID").
+		String()
+
+	flStatePushForce = cmdStatePush.Flag("force",
+		"Replace the stored state whatever its version.").
+		Bool()
+
+	arStatePushFile = cmdStatePush.Arg("file",
+		"State file to push.").ExistingFile()
+
+	//------------------------
+	// state lock: subcommand
+	//------------------------
+
+	cmdStateLock = cmdState.Command("lock",
+		"Locks the cluster state until unlocked, prints the lock ID.")
+
+	flStateLockBackend = cmdStateLock.Flag("backend",
+		"State backend URL: file://, s3:// or http(s)://").
+		PlaceHolder("KATO_STATE_BACKEND").
+		OverrideDefaultFromEnvar("KATO_STATE_BACKEND").
+		String()
+
+	flStateLockClusterID = cli.RegexpMatch(cmdStateLock.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_STATE_LOCK_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_STATE_LOCK_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flStateLockTTL = cmdStateLock.Flag("ttl",
+		"Let the lock go stale after this duration (e.g. 2h), never by default.").
+		PlaceHolder("KATO_STATE_LOCK_TTL").
+		OverrideDefaultFromEnvar("KATO_STATE_LOCK_TTL").
+		Duration()
+
+	//--------------------------
+	// state unlock: subcommand
+	//--------------------------
+
+	cmdStateUnlock = cmdState.Command("unlock",
+		"Unlocks the cluster state.")
+
+	flStateUnlockBackend = cmdStateUnlock.Flag("backend",
+		"State backend URL: file://, s3:// or http(s)://").
+		PlaceHolder("KATO_STATE_BACKEND").
+		OverrideDefaultFromEnvar("KATO_STATE_BACKEND").
+		String()
+
+	flStateUnlockClusterID = cli.RegexpMatch(cmdStateUnlock.Flag("cluster-id",
+		"Cluster ID").
+		Required().PlaceHolder("KATO_STATE_UNLOCK_CLUSTER_ID").
+		OverrideDefaultFromEnvar("KATO_STATE_UNLOCK_CLUSTER_ID"), "^[a-zA-Z0-9-]+$")
+
+	flStateUnlockForce = cmdStateUnlock.Flag("force",
+		"Release the lock whoever holds it.").
+		Bool()
+
+	arStateUnlockLockID = cmdStateUnlock.Arg("lock-id",
+		"Lock ID, as printed by lock.").String()
+)
+
+//-----------------------------------------------------------------------------
+// RunCmd:
+//-----------------------------------------------------------------------------
+
+// RunCmd runs the cmd if owned by this package.
+func RunCmd(cmd string) bool {
+
+	switch cmd {
+
+	// katoctl state pull:
+	case cmdStatePull.FullCommand():
+		d := Data{
+			Backend:   *flStatePullBackend,
+			ClusterID: *flStatePullClusterID,
+			Output:    *flStatePullOutput,
+		}
+		d.Pull()
+
+	// katoctl state push:
+	case cmdStatePush.FullCommand():
+		d := Data{
+			Backend:   *flStatePushBackend,
+			ClusterID: *flStatePushClusterID,
+			File:      *arStatePushFile,
+			Version:   *flStatePushVersion,
+			LockID:    *flStatePushLockID,
+			Force:     *flStatePushForce,
+		}
+		d.Push()
+
+	// katoctl state lock:
+	case cmdStateLock.FullCommand():
+		d := Data{
+			Backend:   *flStateLockBackend,
+			ClusterID: *flStateLockClusterID,
+			TTL:       *flStateLockTTL,
+		}
+		d.Lock()
+
+	// katoctl state unlock:
+	case cmdStateUnlock.FullCommand():
+		if (*arStateUnlockLockID == "") == !*flStateUnlockForce {
+			cli.App.Fatalf("give either the lock ID or --force, try --help")
+		}
+		d := Data{
+			Backend:   *flStateUnlockBackend,
+			ClusterID: *flStateUnlockClusterID,
+			LockID:    *arStateUnlockLockID,
+		}
+		d.Unlock()
+
+	// Nothing to do:
+	default:
+		return false
+	}
+
+	return true
+}
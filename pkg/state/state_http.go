@@ -0,0 +1,148 @@
+package state
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Generic HTTP backend: objects are GET, PUT and DELETE under a base URL.
+// The server returns an ETag and honors If-Match and If-None-Match with 412.
+// Basic auth credentials can be given in the URL.
+type httpBackend struct {
+	url *url.URL
+}
+
+//-----------------------------------------------------------------------------
+// func: newHTTP
+//-----------------------------------------------------------------------------
+
+func newHTTP(u *url.URL) Backend {
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &httpBackend{url: u}
+}
+
+//-----------------------------------------------------------------------------
+// func: Get
+//-----------------------------------------------------------------------------
+
+func (b *httpBackend) Get(name string) ([]byte, string, error) {
+
+	res, err := b.do("GET", name, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, "", notFound(b.String() + "/" + name)
+	default:
+		return nil, "", errors.New("Ops! GET " + b.String() + "/" + name + ": " + res.Status)
+	}
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, unquote(res.Header.Get("ETag")), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Put
+//-----------------------------------------------------------------------------
+
+func (b *httpBackend) Put(name string, data []byte, match string) (string, error) {
+
+	// Conditional write:
+	header := http.Header{}
+	switch match {
+	case "":
+	case Absent:
+		header.Set("If-None-Match", "*")
+	default:
+		header.Set("If-Match", `"`+match+`"`)
+	}
+
+	res, err := b.do("PUT", name, data, header)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return "", ErrConflict
+	default:
+		return "", errors.New("Ops! PUT " + b.String() + "/" + name + ": " + res.Status)
+	}
+
+	return unquote(res.Header.Get("ETag")), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Delete
+//-----------------------------------------------------------------------------
+
+func (b *httpBackend) Delete(name string) error {
+
+	res, err := b.do("DELETE", name, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+
+	return errors.New("Ops! DELETE " + b.String() + "/" + name + ": " + res.Status)
+}
+
+//-----------------------------------------------------------------------------
+// func: String
+//-----------------------------------------------------------------------------
+
+// The URL without credentials.
+func (b *httpBackend) String() string {
+	u := *b.url
+	u.User = nil
+	return u.String()
+}
+
+//-----------------------------------------------------------------------------
+// func: do
+//-----------------------------------------------------------------------------
+
+func (b *httpBackend) do(method, name string, data []byte, header http.Header) (*http.Response, error) {
+
+	u := *b.url
+	u.Path += "/" + name
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,190 @@
+package state
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Local directory backend, the version is a hash of the content.
+type local struct {
+	dir string
+}
+
+//-----------------------------------------------------------------------------
+// func: Local
+//-----------------------------------------------------------------------------
+
+// Local returns the $HOME/.kato backend, also used for files which never
+// leave this host such as the secrets key.
+func Local() Backend {
+	return &local{dir: os.Getenv("HOME") + "/.kato"}
+}
+
+//-----------------------------------------------------------------------------
+// func: Get
+//-----------------------------------------------------------------------------
+
+func (l *local) Get(name string) ([]byte, string, error) {
+
+	data, err := ioutil.ReadFile(l.dir + "/" + name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, hash(data), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Put
+//-----------------------------------------------------------------------------
+
+func (l *local) Put(name string, data []byte, match string) (string, error) {
+
+	// Create the state directory:
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return "", err
+	}
+
+	// Write a temporary file (0600):
+	f, err := ioutil.TempFile(l.dir, "."+name+".")
+	if err != nil {
+		return "", err
+	}
+	tmpFile := f.Name()
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmpFile)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmpFile)
+		return "", err
+	}
+
+	// Create it, hard links fail if the target exists:
+	if match == Absent {
+		defer os.Remove(tmpFile)
+		if err := os.Link(tmpFile, l.dir+"/"+name); err != nil {
+			if os.IsExist(err) {
+				return "", ErrConflict
+			}
+			return "", err
+		}
+		return hash(data), nil
+	}
+
+	// Check the version (best effort):
+	if match != "" {
+		if _, version, err := l.Get(name); err != nil || version != match {
+			_ = os.Remove(tmpFile)
+			return "", ErrConflict
+		}
+	}
+
+	// Replace it (atomically, readers never see partial data):
+	if err := os.Rename(tmpFile, l.dir+"/"+name); err != nil {
+		return "", err
+	}
+
+	return hash(data), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Delete
+//-----------------------------------------------------------------------------
+
+func (l *local) Delete(name string) error {
+	if err := os.Remove(l.dir + "/" + name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: String
+//-----------------------------------------------------------------------------
+
+func (l *local) String() string {
+	return "file://" + l.dir
+}
+
+//-----------------------------------------------------------------------------
+// func: flock
+//-----------------------------------------------------------------------------
+
+// Lock <name>.lock, <id>.lock for <id>.json, with flock(2) without blocking so
+// that AcquireLock bounds the wait. The holder is written in the lock file to
+// be reported to others. A lock held with 'katoctl state lock' is still
+// honored.
+func (l *local) flock(name, operation string) (*Lock, error) {
+
+	// Open the lock file:
+	if err := os.MkdirAll(l.dir, 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(l.dir+"/"+strings.TrimSuffix(name, ".json")+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// Acquire the lock:
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		defer f.Close()
+		if err != syscall.EWOULDBLOCK {
+			return nil, err
+		}
+		held := Lock{Name: name}
+		if data, err := ioutil.ReadAll(f); err == nil {
+			_ = json.Unmarshal(data, &held)
+		}
+		return nil, &LockedError{held}
+	}
+	lock, err := newLock(name, operation, 0)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	lock.file = f
+
+	// Tell who holds it:
+	if data, err := json.Marshal(lock); err == nil {
+		if err := f.Truncate(0); err == nil {
+			_, _ = f.WriteAt(data, 0)
+		}
+	}
+
+	// Someone holds the state:
+	if held, err := ReadLock(l, name); err != nil || (held != nil && !held.stale()) {
+		_ = Release(l, lock)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &LockedError{*held}
+	}
+
+	return lock, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: hash
+//-----------------------------------------------------------------------------
+
+func hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,244 @@
+package state
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	// Community:
+	log "github.com/Sirupsen/logrus"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Data struct for the state sub-commands.
+type Data struct {
+	command   string
+	backend   Backend
+	Backend   string        // pull | push | lock | unlock
+	ClusterID string        // pull | push | lock | unlock
+	Output    string        // pull |      |      |
+	File      string        //      | push |      |
+	Version   string        //      | push |      |
+	LockID    string        //      | push |      | unlock
+	Force     bool          //      | push |      |
+	TTL       time.Duration //      |      | lock |
+}
+
+// Objects kept per cluster in a backend, <cluster-id> prefixed: the state,
+// spec, secrets, cluster PKI and etcd CA.
+var Objects = []string{".json", ".spec.yaml", ".secrets",
+	".pki.json", ".pki.ca.pem", ".pki.ca-key", ".pki.crl.pem",
+	".pki.etcd-ca.pem", ".pki.etcd-ca-key"}
+
+//-----------------------------------------------------------------------------
+// func: Pull
+//-----------------------------------------------------------------------------
+
+// Pull prints the cluster state stored in the backend.
+func (d *Data) Pull() {
+
+	// Set the current command:
+	d.command = "pull"
+	d.open()
+
+	// Read the state:
+	data, version, err := d.backend.Get(d.ClusterID + ".json")
+	if err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+
+	// Write it out:
+	if d.Output == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(d.Output, data, 0600)
+	}
+	if err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "state:" + d.command, "id": d.backend.String()}).
+		Info("State version " + version)
+}
+
+//-----------------------------------------------------------------------------
+// func: Push
+//-----------------------------------------------------------------------------
+
+// Push writes the given state file to the backend. Without file the local
+// state, spec and secrets are pushed: a migration to a remote backend.
+func (d *Data) Push() {
+
+	// Set the current command:
+	d.command = "push"
+	d.open()
+
+	// Objects to push:
+	objects, err := d.objects()
+	if err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+
+	// Write them under the lock:
+	version, err := d.push(objects)
+	if err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "state:" + d.command, "id": d.backend.String()}).
+		Info("State version " + version)
+}
+
+//-----------------------------------------------------------------------------
+// func: Lock
+//-----------------------------------------------------------------------------
+
+// Lock the cluster state until unlocked, the lock ID goes to stdout.
+func (d *Data) Lock() {
+
+	// Set the current command:
+	d.command = "lock"
+	d.open()
+
+	// Take the lock:
+	lock, err := TryLock(d.backend, d.ClusterID+".json", "state lock", d.TTL)
+	if err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+
+	fmt.Println(lock.ID)
+}
+
+//-----------------------------------------------------------------------------
+// func: Unlock
+//-----------------------------------------------------------------------------
+
+// Unlock the cluster state.
+func (d *Data) Unlock() {
+
+	// Set the current command:
+	d.command = "unlock"
+	d.open()
+
+	// Release the lock, whoever holds it if forced:
+	if err := Unlock(d.backend, d.ClusterID+".json", d.LockID); err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+
+	log.WithFields(log.Fields{"cmd": "state:" + d.command, "id": d.ClusterID}).
+		Info("State unlocked")
+}
+
+//-----------------------------------------------------------------------------
+// func: open
+//-----------------------------------------------------------------------------
+
+func (d *Data) open() {
+	b, err := Open(d.Backend)
+	if err != nil {
+		log.WithField("cmd", "state:"+d.command).Fatal(err)
+	}
+	d.backend = b
+}
+
+//-----------------------------------------------------------------------------
+// func: push
+//-----------------------------------------------------------------------------
+
+func (d *Data) push(objects map[string][]byte) (string, error) {
+
+	// Hold the lock, or check the given one:
+	if d.LockID == "" {
+		lock, err := TryLock(d.backend, d.ClusterID+".json", "state push", lockTTL)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = Unlock(d.backend, lock.Name, lock.ID) }()
+	} else if held, err := ReadLock(d.backend, d.ClusterID+".json"); err != nil {
+		return "", err
+	} else if held == nil || held.ID != d.LockID {
+		return "", errors.New("Ops! the lock " + d.LockID + " is not held")
+	}
+
+	// Only overwrite the version that was pulled:
+	match := Absent
+	if _, version, err := d.backend.Get(d.ClusterID + ".json"); err == nil {
+		if !d.Force && d.Version != version {
+			return "", errors.New("Ops! the stored state is at version " + version +
+				", pull it first or use --force")
+		}
+		match = version
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	// The state goes first:
+	version, err := d.backend.Put(d.ClusterID+".json", objects[d.ClusterID+".json"], match)
+	if err != nil {
+		return "", err
+	}
+	for name, data := range objects {
+		if name != d.ClusterID+".json" {
+			if _, err := d.backend.Put(name, data, ""); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return version, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: objects
+//-----------------------------------------------------------------------------
+
+// Read the objects to push: the given file or the local cluster files.
+func (d *Data) objects() (map[string][]byte, error) {
+
+	objects := map[string][]byte{}
+
+	if d.File != "" {
+		data, err := ioutil.ReadFile(d.File)
+		if err != nil {
+			return nil, err
+		}
+		objects[d.ClusterID+".json"] = data
+	} else {
+		if d.backend.String() == Local().String() {
+			return nil, errors.New("Ops! the backend is the local state, nothing to push")
+		}
+		for _, ext := range Objects {
+			data, _, err := Local().Get(d.ClusterID + ext)
+			if err != nil {
+				if os.IsNotExist(err) && ext != ".json" {
+					continue
+				}
+				return nil, err
+			}
+			objects[d.ClusterID+ext] = data
+		}
+	}
+
+	// Catch mistakes:
+	var s struct{ ClusterID string }
+	if err := json.Unmarshal(objects[d.ClusterID+".json"], &s); err != nil {
+		return nil, err
+	}
+	if s.ClusterID != d.ClusterID {
+		return nil, errors.New("Ops! the state describes cluster " + s.ClusterID)
+	}
+
+	return objects, nil
+}
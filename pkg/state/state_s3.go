@@ -0,0 +1,162 @@
+package state
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	// Community:
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// S3 backend, the version is the ETag. Conditional writes make the locks, no
+// extra lock table is needed. Enable the bucket versioning to keep history.
+type s3Backend struct {
+	svc    *s3.S3
+	bucket string
+	prefix string
+}
+
+//-----------------------------------------------------------------------------
+// func: newS3
+//-----------------------------------------------------------------------------
+
+func newS3(u *url.URL) (Backend, error) {
+
+	// Region and S3 compatible endpoint:
+	cfg := &aws.Config{}
+	if region := u.Query().Get("region"); region != "" {
+		cfg.Region = aws.String(region)
+	}
+	if endpoint := u.Query().Get("endpoint"); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	// Connect and authenticate to the API endpoint:
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3Backend{
+		svc:    s3.New(sess),
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+	}, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Get
+//-----------------------------------------------------------------------------
+
+func (b *s3Backend) Get(name string) ([]byte, string, error) {
+
+	out, err := b.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name)),
+	})
+	if err != nil {
+		if status(err) == http.StatusNotFound {
+			return nil, "", notFound(b.String() + "/" + name)
+		}
+		return nil, "", err
+	}
+	defer out.Body.Close()
+
+	data, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return data, unquote(aws.StringValue(out.ETag)), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Put
+//-----------------------------------------------------------------------------
+
+func (b *s3Backend) Put(name string, data []byte, match string) (string, error) {
+
+	req, out := b.svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name)),
+		Body:   bytes.NewReader(data),
+	})
+
+	// Conditional write:
+	switch match {
+	case "":
+	case Absent:
+		req.HTTPRequest.Header.Set("If-None-Match", "*")
+	default:
+		req.HTTPRequest.Header.Set("If-Match", `"`+match+`"`)
+	}
+
+	if err := req.Send(); err != nil {
+		if s := status(err); s == http.StatusPreconditionFailed || s == http.StatusConflict {
+			return "", ErrConflict
+		}
+		return "", err
+	}
+
+	return unquote(aws.StringValue(out.ETag)), nil
+}
+
+//-----------------------------------------------------------------------------
+// func: Delete
+//-----------------------------------------------------------------------------
+
+func (b *s3Backend) Delete(name string) error {
+	_, err := b.svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.key(name)),
+	})
+	return err
+}
+
+//-----------------------------------------------------------------------------
+// func: String
+//-----------------------------------------------------------------------------
+
+func (b *s3Backend) String() string {
+	return "s3://" + b.bucket + "/" + b.prefix
+}
+
+//-----------------------------------------------------------------------------
+// func: key
+//-----------------------------------------------------------------------------
+
+func (b *s3Backend) key(name string) string {
+	if b.prefix == "" {
+		return name
+	}
+	return b.prefix + "/" + name
+}
+
+//-----------------------------------------------------------------------------
+// func: status
+//-----------------------------------------------------------------------------
+
+// HTTP status code of a failed AWS request, 0 if unknown.
+func status(err error) int {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode()
+	}
+	return 0
+}
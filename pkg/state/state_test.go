@@ -0,0 +1,276 @@
+package state
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// In-memory object store speaking the subset of S3 used by the backends:
+type objectStore struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	fail := func(status int, code string) {
+		w.WriteHeader(status)
+		w.Write([]byte("<Error><Code>" + code + "</Code><Message>" + code + "</Message></Error>"))
+	}
+	etag := func(data []byte) string {
+		sum := md5.Sum(data)
+		return `"` + hex.EncodeToString(sum[:]) + `"`
+	}
+
+	data, ok := s.objects[r.URL.Path]
+	switch r.Method {
+	case "GET":
+		if !ok {
+			fail(http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		w.Write(data)
+	case "PUT":
+		if m := r.Header.Get("If-None-Match"); m == "*" && ok {
+			fail(http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		if m := r.Header.Get("If-Match"); m != "" && (!ok || m != etag(data)) {
+			fail(http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+		w.Header().Set("ETag", etag(body))
+	case "DELETE":
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestBackends(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := &objectStore{objects: map[string][]byte{}}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	defer os.Setenv("AWS_ACCESS_KEY_ID", os.Getenv("AWS_ACCESS_KEY_ID"))
+	defer os.Setenv("AWS_SECRET_ACCESS_KEY", os.Getenv("AWS_SECRET_ACCESS_KEY"))
+	os.Setenv("AWS_ACCESS_KEY_ID", "kato")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "kato")
+
+	for _, u := range []string{
+		"file://" + dir,
+		srv.URL + "/state",
+		"s3://kato/state?region=eu-west-1&endpoint=" + srv.URL,
+	} {
+
+		b, err := Open(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Missing objects:
+		if _, _, err := b.Get("kato.json"); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected a not exist error, got %v", b, err)
+		}
+
+		// Versioned writes:
+		v1, err := b.Put("kato.json", []byte("1"), Absent)
+		if err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if _, err := b.Put("kato.json", []byte("2"), Absent); err != ErrConflict {
+			t.Errorf("%s: expected a conflict creating twice, got %v", b, err)
+		}
+		v2, err := b.Put("kato.json", []byte("2"), v1)
+		if err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if _, err := b.Put("kato.json", []byte("3"), v1); err != ErrConflict {
+			t.Errorf("%s: expected a conflict on a stale version, got %v", b, err)
+		}
+		if data, v, err := b.Get("kato.json"); err != nil || string(data) != "2" || v != v2 {
+			t.Errorf("%s: expected 2 at %s, got %s at %s (%v)", b, v2, data, v, err)
+		}
+
+		// Locks:
+		l, err := TryLock(b, "kato.json", "test", 0)
+		if err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if _, err := TryLock(b, "kato.json", "test", 0); err == nil {
+			t.Errorf("%s: expected a locked error", b)
+		} else if held, ok := err.(*LockedError); !ok || held.ID != l.ID {
+			t.Errorf("%s: expected lock %s, got %v", b, l.ID, err)
+		}
+		if err := Unlock(b, "kato.json", "other"); err == nil {
+			t.Errorf("%s: expected an error unlocking someone else's lock", b)
+		}
+		if err := Unlock(b, "kato.json", l.ID); err != nil {
+			t.Errorf("%s: %v", b, err)
+		}
+
+		// Stale locks are taken over:
+		stale, err := TryLock(b, "kato.json", "test", time.Millisecond)
+		if err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+		l, err = TryLock(b, "kato.json", "test", time.Minute)
+		if err != nil {
+			t.Fatalf("%s: expected the stale lock taken over, got %v", b, err)
+		}
+		if err := Unlock(b, "kato.json", stale.ID); err == nil {
+			t.Errorf("%s: expected an error releasing a lock taken over", b)
+		}
+		if err := Unlock(b, "kato.json", l.ID); err != nil {
+			t.Errorf("%s: %v", b, err)
+		}
+
+		// Delete:
+		if err := b.Delete("kato.json"); err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if _, _, err := b.Get("kato.json"); !os.IsNotExist(err) {
+			t.Errorf("%s: expected a not exist error, got %v", b, err)
+		}
+	}
+
+	// Nothing left behind:
+	if len(store.objects) != 0 {
+		t.Errorf("expected no objects left, got %v", store.objects)
+	}
+}
+
+func TestLocalLock(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d time.Duration) { lockTimeout = d }(lockTimeout)
+	lockTimeout = 100 * time.Millisecond
+
+	// Locked with flock, nothing stays behind:
+	b := &local{dir: dir}
+	l, err := AcquireLock(b, "kato.json", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if held, err := ReadLock(b, "kato.json"); err != nil || held != nil {
+		t.Errorf("expected no lock object, got %v (%v)", held, err)
+	}
+	if err := Release(b, l); err != nil {
+		t.Fatal(err)
+	}
+
+	// Contenders wait a bounded time and learn who holds it:
+	l, err = AcquireLock(b, "kato.json", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	_, err = AcquireLock(b, "kato.json", "second")
+	if e, ok := err.(*LockedError); !ok || e.Operation != "first" || e.ID != l.ID {
+		t.Errorf("expected a locked error held by first, got %v", err)
+	}
+	if time.Since(start) > 10*lockTimeout {
+		t.Errorf("waited %v for the flock", time.Since(start))
+	}
+	if err := Release(b, l); err != nil {
+		t.Fatal(err)
+	}
+
+	// Locks taken with 'katoctl state lock' still hold:
+	held, err := TryLock(b, "kato.json", "state lock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireLock(b, "kato.json", "test"); err == nil {
+		t.Error("expected a locked error")
+	}
+	if err := Unlock(b, "kato.json", held.ID); err != nil {
+		t.Fatal(err)
+	}
+	if l, err = AcquireLock(b, "kato.json", "test"); err != nil {
+		t.Fatal(err)
+	}
+	_ = Release(b, l)
+}
+
+func TestPush(t *testing.T) {
+
+	home, err := ioutil.TempDir("", "kato")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	srv := httptest.NewServer(&objectStore{objects: map[string][]byte{}})
+	defer srv.Close()
+
+	// Local state, spec and secrets:
+	for name, data := range map[string]string{
+		"kato.json":      `{"ClusterID": "kato"}`,
+		"kato.spec.yaml": "clusterID: kato\n",
+		"kato.secrets":   "sealed",
+	} {
+		if _, err := Local().Put(name, []byte(data), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Migrate them:
+	d := &Data{ClusterID: "kato", Backend: srv.URL}
+	d.open()
+	objects, err := d.objects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := d.push(objects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _, err := d.backend.Get("kato.secrets"); err != nil || string(data) != "sealed" {
+		t.Errorf("expected the secrets pushed, got %q (%v)", data, err)
+	}
+
+	// Only over the pulled version:
+	if _, err := d.push(objects); err == nil {
+		t.Error("expected an error without version")
+	}
+	d.Version = v1
+	if _, err := d.push(objects); err != nil {
+		t.Error(err)
+	}
+
+	// Not under someone else's lock:
+	if _, err := TryLock(d.backend, "kato.json", "test", 0); err != nil {
+		t.Fatal(err)
+	}
+	d.Force = true
+	if _, err := d.push(objects); err == nil {
+		t.Error("expected a locked error")
+	}
+}
@@ -0,0 +1,333 @@
+package udata
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	// Community:
+	"github.com/ajeddeloh/yaml"
+)
+
+//-----------------------------------------------------------------------------
+// Typedefs:
+//-----------------------------------------------------------------------------
+
+// Fragments, services and roles loaded from a bundle. They are overlaid on
+// top of the embedded defaults.
+type bundle struct {
+	fragments []bundleFragment
+	services  map[string]service
+	roles     map[string][]string
+}
+
+// Layout of every YAML file in a bundle.
+type bundleFile struct {
+	Fragments []bundleFragment      `yaml:"fragments"`
+	Services  map[string]bundleUnit `yaml:"services"`
+	Roles     map[string][]string   `yaml:"roles"`
+}
+
+// A fragment is matched by name. Unknown names are inserted after the named
+// fragment or appended at the end.
+type bundleFragment struct {
+	Name   string   `yaml:"name"`
+	After  string   `yaml:"after"`
+	Remove bool     `yaml:"remove"`
+	AnyOf  []string `yaml:"anyOf"`
+	NoneOf []string `yaml:"noneOf"`
+	AllOf  []string `yaml:"allOf"`
+	Data   string   `yaml:"data"`
+	File   string   `yaml:"file"`
+}
+
+// A service of the role/port catalog.
+type bundleUnit struct {
+	Unit   string   `yaml:"unit"`
+	Groups []string `yaml:"groups"`
+	Ports  []string `yaml:"ports"`
+}
+
+//-----------------------------------------------------------------------------
+// func: readBundle
+//-----------------------------------------------------------------------------
+
+// Read all the regular files of a directory or a (gzipped) tarball, indexed
+// by their slash separated path relative to the bundle root.
+func readBundle(bundlePath string) (map[string][]byte, error) {
+
+	files := map[string][]byte{}
+
+	fi, err := os.Stat(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk the directory:
+	if fi.IsDir() {
+		err := filepath.Walk(bundlePath, func(p string, info os.FileInfo, err error) error {
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(bundlePath, p)
+			if err != nil {
+				return err
+			}
+			data, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			files[filepath.ToSlash(rel)] = data
+			return nil
+		})
+		return files, err
+	}
+
+	// Open the tarball:
+	f, err := os.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Decompress if gzipped:
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil &&
+		magic[0] == 0x1f && magic[1] == 0x8b {
+		if r, err = gzip.NewReader(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// Read the entries:
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(strings.TrimPrefix(hdr.Name, "./"))] = data
+	}
+
+	return files, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: loadBundle
+//-----------------------------------------------------------------------------
+
+// Load a bundle from a directory or a (gzipped) tarball.
+func loadBundle(bundlePath string) (*bundle, error) {
+
+	// Read the bundle:
+	files, err := readBundle(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBundle(files)
+}
+
+//-----------------------------------------------------------------------------
+// func: defaultBundle
+//-----------------------------------------------------------------------------
+
+//go:generate go run udata_defaults_gen.go
+
+// The defaults bundle lives in the defaults directory and is embedded in
+// udata_defaults.go, run 'go generate' after editing it.
+func defaultBundle() (*bundle, error) {
+
+	files := map[string][]byte{}
+	for name, data := range defaultFiles {
+		files[name] = []byte(data)
+	}
+
+	return parseBundle(files)
+}
+
+//-----------------------------------------------------------------------------
+// func: parseBundle
+//-----------------------------------------------------------------------------
+
+// Parse the root YAML files of a bundle in lexical order, later files win.
+func parseBundle(files map[string][]byte) (*bundle, error) {
+
+	// Sort the YAML files at the root, templates live in subdirectories:
+	var names []string
+	for name := range files {
+		if ext := path.Ext(name); !strings.Contains(name, "/") &&
+			(ext == ".yml" || ext == ".yaml") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	b := &bundle{services: map[string]service{}, roles: map[string][]string{}}
+
+	for _, name := range names {
+
+		// Decode the file:
+		bf := bundleFile{}
+		if err := yaml.Unmarshal(files[name], &bf); err != nil {
+			return nil, errors.New(name + ": " + err.Error())
+		}
+
+		// Fragments, data may come from a template file:
+		for _, frag := range bf.Fragments {
+			if frag.File != "" {
+				data, ok := files[path.Join(path.Dir(name), frag.File)]
+				if !ok {
+					return nil, errors.New(name + ": file " + frag.File + " not found")
+				}
+				frag.Data = string(data)
+			}
+			b.fragments = append(b.fragments, frag)
+		}
+
+		// Services:
+		for k, v := range bf.Services {
+			s, err := v.service()
+			if err != nil {
+				return nil, errors.New(name + ": service " + k + ": " + err.Error())
+			}
+			b.services[k] = s
+		}
+
+		// Roles:
+		for k, v := range bf.Roles {
+			b.roles[k] = v
+		}
+	}
+
+	return b, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: service
+//-----------------------------------------------------------------------------
+
+// Ports are given as <port>/<protocol> or <start>:<end>/<protocol>.
+var portRegexp = regexp.MustCompile(`^(\d+)(?::(\d+))?/(tcp|udp)$`)
+
+func (u *bundleUnit) service() (service, error) {
+
+	s := service{name: u.Unit, groups: u.Groups}
+	if s.name == "" {
+		return s, errors.New("missing unit name")
+	}
+
+	for _, p := range u.Ports {
+		m := portRegexp.FindStringSubmatch(p)
+		if m == nil {
+			return s, errors.New("invalid port " + p)
+		}
+		start, _ := strconv.Atoi(m[1])
+		end := start
+		if m[2] != "" {
+			end, _ = strconv.Atoi(m[2])
+		}
+		s.ports = append(s.ports, portRange{
+			interval: startEnd{start, end}, protocol: m[3], ingress: ""})
+	}
+
+	return s, nil
+}
+
+//-----------------------------------------------------------------------------
+// func: overlay
+//-----------------------------------------------------------------------------
+
+func (fragments *fragmentSlice) overlay(overrides []bundleFragment) error {
+
+	for _, o := range overrides {
+
+		if o.Name == "" {
+			return errors.New("fragment without name")
+		}
+
+		// Remove:
+		i := fragments.index(o.Name)
+		if o.Remove {
+			if i >= 0 {
+				*fragments = append((*fragments)[:i], (*fragments)[i+1:]...)
+			}
+			continue
+		}
+
+		// Fragments are concatenated, each one starts on a new line:
+		f := fragment{
+			name:   o.Name,
+			filter: filter{anyOf: o.AnyOf, noneOf: o.NoneOf, allOf: o.AllOf},
+			data:   "\n" + strings.TrimRight(strings.TrimLeft(o.Data, "\n"), "\n"),
+		}
+
+		switch {
+
+		// Override, keep the filter unless a new one is given:
+		case i >= 0:
+			if len(o.AnyOf)+len(o.NoneOf)+len(o.AllOf) == 0 {
+				f.filter = (*fragments)[i].filter
+			}
+			(*fragments)[i] = f
+
+		// A new fragment never matches without anyOf:
+		case len(o.AnyOf) == 0:
+			return errors.New("fragment " + o.Name + " needs anyOf")
+
+		// Insert:
+		case o.After != "":
+			j := fragments.index(o.After)
+			if j < 0 {
+				return errors.New("fragment " + o.Name + ": " + o.After + " not found")
+			}
+			*fragments = append((*fragments)[:j+1],
+				append(fragmentSlice{f}, (*fragments)[j+1:]...)...)
+
+		// Append:
+		default:
+			*fragments = append(*fragments, f)
+		}
+	}
+
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// func: index
+//-----------------------------------------------------------------------------
+
+func (fragments *fragmentSlice) index(name string) int {
+	for i, f := range *fragments {
+		if f.name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,68 @@
+//go:build ignore
+// +build ignore
+
+package main
+
+//-----------------------------------------------------------------------------
+// Package factored import statement:
+//-----------------------------------------------------------------------------
+
+import (
+
+	// Stdlib:
+	"bytes"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+// func: main
+//-----------------------------------------------------------------------------
+
+// Embed the files of the defaults bundle into udata_defaults.go, one quoted
+// line per source line to keep the diffs readable.
+func main() {
+
+	// Read the bundle files:
+	names, err := filepath.Glob(filepath.Join("defaults", "*.yml"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	sort.Strings(names)
+
+	// Forge the source:
+	var b bytes.Buffer
+	b.WriteString("// Code generated by udata_defaults_gen.go. DO NOT EDIT.\n\n")
+	b.WriteString("package udata\n\n")
+	b.WriteString("// Files of the defaults bundle, indexed by name.\n")
+	b.WriteString("var defaultFiles = map[string]string{\n")
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var lines []string
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line != "" {
+				lines = append(lines, strconv.Quote(line))
+			}
+		}
+		b.WriteString(strconv.Quote(filepath.Base(name)) + ": \"\" +\n")
+		b.WriteString(strings.Join(lines, " +\n") + ",\n")
+	}
+	b.WriteString("}\n")
+
+	// Format and write it:
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("udata_defaults.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -73,10 +73,10 @@ var (
 		String()
 
 	flUdataDNSProvider = cmdUdata.Flag("dns-provider",
-		"DNS provider [ none | ns1 | r53 ]").
+		"DNS provider [ none | ns1 | r53 | rfc2136 ]").
 		Default("none").PlaceHolder("KATO_UDATA_DNS_PROVIDER").
 		OverrideDefaultFromEnvar("KATO_UDATA_DNS_PROVIDER").
-		Enum("none", "ns1", "r53")
+		Enum("none", "ns1", "r53", "rfc2136")
 
 	flUdataDNSApikey = cmdUdata.Flag("dns-api-key",
 		"DNS private API key.").
@@ -84,6 +84,11 @@ var (
 		OverrideDefaultFromEnvar("KATO_UDATA_DNS_API_KEY").
 		String()
 
+	flUdataELBAlias = cmdUdata.Flag("elb-alias",
+		"ext.<domain> is an alias of the ELB, nodes publish no ext A records.").
+		OverrideDefaultFromEnvar("KATO_UDATA_ELB_ALIAS").
+		Bool()
+
 	flUdataCaCertPath = cmdUdata.Flag("ca-cert-path",
 		"Path to CA certificate.").
 		PlaceHolder("KATO_UDATA_CA_CERT_PATH").
@@ -96,6 +101,83 @@ var (
 		OverrideDefaultFromEnvar("KATO_UDATA_ETCD_TOKEN").
 		String()
 
+	flUdataEtcdDiscovery = cmdUdata.Flag("etcd-discovery",
+		"Etcd discovery URL, takes precedence over --etcd-token.").
+		PlaceHolder("KATO_UDATA_ETCD_DISCOVERY").
+		OverrideDefaultFromEnvar("KATO_UDATA_ETCD_DISCOVERY").
+		String()
+
+	flUdataEtcdTLS = cmdUdata.Flag("etcd-tls",
+		"Secure etcd peer and client traffic with TLS.").
+		OverrideDefaultFromEnvar("KATO_UDATA_ETCD_TLS").
+		Bool()
+
+	flUdataEtcdCaCert = cmdUdata.Flag("etcd-ca-cert",
+		"Path to the etcd CA certificate, the one of the cluster state if not given.").
+		PlaceHolder("KATO_UDATA_ETCD_CA_CERT").
+		OverrideDefaultFromEnvar("KATO_UDATA_ETCD_CA_CERT").
+		ExistingFile()
+
+	flUdataEtcdCaKey = cmdUdata.Flag("etcd-ca-key",
+		"Path to the etcd CA private key, goes with --etcd-ca-cert.").
+		PlaceHolder("KATO_UDATA_ETCD_CA_KEY").
+		OverrideDefaultFromEnvar("KATO_UDATA_ETCD_CA_KEY").
+		ExistingFile()
+
+	flUdataNodeCert = cmdUdata.Flag("node-cert",
+		"PEM certificate of this node signed by the cluster CA of 'katoctl pki'.").
+		PlaceHolder("KATO_UDATA_NODE_CERT").
+		OverrideDefaultFromEnvar("KATO_UDATA_NODE_CERT").
+		String()
+
+	flUdataNodeKey = cmdUdata.Flag("node-key",
+		"PEM private key of the --node-cert certificate.").
+		PlaceHolder("KATO_UDATA_NODE_KEY").
+		OverrideDefaultFromEnvar("KATO_UDATA_NODE_KEY").
+		String()
+
+	flUdataPkiCA = cmdUdata.Flag("pki-ca",
+		"PEM certificate of the cluster CA of 'katoctl pki'.").
+		PlaceHolder("KATO_UDATA_PKI_CA").
+		OverrideDefaultFromEnvar("KATO_UDATA_PKI_CA").
+		String()
+
+	flUdataPkiCRL = cmdUdata.Flag("pki-crl",
+		"PEM revocation list of the cluster CA of 'katoctl pki'.").
+		PlaceHolder("KATO_UDATA_PKI_CRL").
+		OverrideDefaultFromEnvar("KATO_UDATA_PKI_CRL").
+		String()
+
+	flUdataPrivateIP = cmdUdata.Flag("private-ip",
+		"Private IP of this node when known in advance, added to its etcd certificates.").
+		PlaceHolder("KATO_UDATA_PRIVATE_IP").
+		OverrideDefaultFromEnvar("KATO_UDATA_PRIVATE_IP").
+		String()
+
+	flUdataKmsKeyID = cmdUdata.Flag("kms-key-id",
+		"Seal the secrets of this node with this KMS key (ec2 only).").
+		PlaceHolder("KATO_UDATA_KMS_KEY_ID").
+		OverrideDefaultFromEnvar("KATO_UDATA_KMS_KEY_ID").
+		String()
+
+	flUdataQuorumIPs = cmdUdata.Flag("quorum-ip",
+		"Static etcd bootstrap, private IP of each quorum node in order.").
+		PlaceHolder("KATO_UDATA_QUORUM_IP").
+		OverrideDefaultFromEnvar("KATO_UDATA_QUORUM_IP").
+		Strings()
+
+	flUdataEtcdInitialCluster = cmdUdata.Flag("etcd-initial-cluster",
+		"Join an existing etcd cluster with these members, as printed by 'etcdctl member add'.").
+		PlaceHolder("KATO_UDATA_ETCD_INITIAL_CLUSTER").
+		OverrideDefaultFromEnvar("KATO_UDATA_ETCD_INITIAL_CLUSTER").
+		String()
+
+	flUdataFragmentsDir = cmdUdata.Flag("fragments-dir",
+		"Directory or tarball of fragment, service and role overrides.").
+		PlaceHolder("KATO_UDATA_FRAGMENTS_DIR").
+		OverrideDefaultFromEnvar("KATO_UDATA_FRAGMENTS_DIR").
+		ExistingFileOrDir()
+
 	flUdataGzipUdata = cmdUdata.Flag("gzip-udata",
 		"Enable udata compression.").
 		Default("false").OverrideDefaultFromEnvar("KATO_UDATA_GZIP_UDATA").
@@ -170,6 +252,19 @@ func RunCmd(cmd string) bool {
 
 	// katoctl udata
 	case cmdUdata.FullCommand():
+		if (*flUdataEtcdCaCert == "") != (*flUdataEtcdCaKey == "") {
+			cli.App.Fatalf("--etcd-ca-cert and --etcd-ca-key go together, try --help")
+		}
+		if *flUdataKmsKeyID != "" && (*flUdataIaasProvider != "ec2" || *flUdataEc2Region == "") {
+			cli.App.Fatalf("--kms-key-id requires --iaas-provider ec2 and --ec2-region, try --help")
+		}
+		if *flUdataIaasProvider == "ec2" && *flUdataKmsKeyID == "" && (*flUdataEtcdTLS || *flUdataNodeCert != "" ||
+			*flUdataDNSApikey != "" || *flUdataSMTPURL != "" || *flUdataSlackWebhook != "") {
+			cli.App.Fatalf("--iaas-provider ec2 requires --kms-key-id with secrets, --etcd-tls or --node-cert, try --help")
+		}
+		if *flUdataNodeCert != "" && (*flUdataNodeKey == "" || *flUdataPkiCA == "") {
+			cli.App.Fatalf("--node-cert requires --node-key and --pki-ca, try --help")
+		}
 		d := CmdData{
 			CmdFlags: CmdFlags{
 				AdminEmail:          *flUdataAdminEmail,
@@ -179,16 +274,30 @@ func RunCmd(cmd string) bool {
 				ClusterState:        *flUdataClusterState,
 				Domain:              *flUdataDomain,
 				Ec2Region:           *flUdataEc2Region,
+				ELBAlias:            *flUdataELBAlias,
+				EtcdCaCert:          *flUdataEtcdCaCert,
+				EtcdCaKey:           *flUdataEtcdCaKey,
+				EtcdDiscovery:       *flUdataEtcdDiscovery,
+				EtcdInitialCluster:  *flUdataEtcdInitialCluster,
+				EtcdTLS:             *flUdataEtcdTLS,
 				EtcdToken:           *flUdataEtcdToken,
+				FragmentsDir:        *flUdataFragmentsDir,
 				GzipUdata:           *flUdataGzipUdata,
 				HostID:              *flUdataHostID,
 				HostName:            *flUdataHostName,
 				IaasProvider:        *flUdataIaasProvider,
+				KmsKeyID:            *flUdataKmsKeyID,
 				MasterCount:         *flUdataMasterCount,
+				NodeCert:            *flUdataNodeCert,
+				NodeKey:             *flUdataNodeKey,
+				PkiCA:               *flUdataPkiCA,
+				PkiCRL:              *flUdataPkiCRL,
+				PrivateIP:           *flUdataPrivateIP,
 				DNSProvider:         *flUdataDNSProvider,
 				DNSApiKey:           *flUdataDNSApikey,
 				Prometheus:          *flUdataPrometheus,
 				QuorumCount:         *flUdataQuorumCount,
+				QuorumIPs:           *flUdataQuorumIPs,
 				RexrayEndpointIP:    *flUdataRexrayEndpointIP,
 				RexrayStorageDriver: *flUdataRexrayStorageDriver,
 				Roles:               strings.Split(*flUdataRoles, ","),
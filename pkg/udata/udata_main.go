@@ -9,12 +9,15 @@ import (
 	// Stdlib:
 	"bytes"
 	"compress/gzip"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"text/template"
 
 	// Community:
@@ -22,6 +25,10 @@ import (
 	log "github.com/Sirupsen/logrus"
 	ct "github.com/coreos/container-linux-config-transpiler/config"
 	"github.com/coreos/coreos-cloudinit/config/validate"
+
+	// Local:
+	"github.com/katosys/kato/pkg/kato"
+	"github.com/katosys/kato/pkg/pki"
 )
 
 //-----------------------------------------------------------------------------
@@ -46,14 +53,28 @@ type CmdFlags struct {
 	DNSApiKey           string   // --dns-api-key
 	DNSProvider         string   // --dns-provider
 	Ec2Region           string   // --ec2-region
+	ELBAlias            bool     // --elb-alias
+	EtcdCaCert          string   // --etcd-ca-cert
+	EtcdCaKey           string   // --etcd-ca-key
+	EtcdDiscovery       string   // --etcd-discovery
+	EtcdInitialCluster  string   // --etcd-initial-cluster
+	EtcdTLS             bool     // --etcd-tls
 	EtcdToken           string   // --etcd-token
+	FragmentsDir        string   // --fragments-dir
 	GzipUdata           bool     // --gzip-udata
 	HostID              string   // --host-id
 	HostName            string   // --host-name
 	IaasProvider        string   // --iaas-provider
+	KmsKeyID            string   // --kms-key-id
 	MasterCount         int      // --master-count
+	NodeCert            string   // --node-cert
+	NodeKey             string   // --node-key
+	PkiCA               string   // --pki-ca
+	PkiCRL              string   // --pki-crl
+	PrivateIP           string   // --private-ip
 	Prometheus          bool     // --prometheus
 	QuorumCount         int      // --quorum-count
+	QuorumIPs           []string // --quorum-ip
 	RexrayEndpointIP    string   // --rexray-endpoint-ip
 	RexrayStorageDriver string   // --rexray-storage-driver
 	Roles               []string // --roles
@@ -67,17 +88,32 @@ type PostProc struct {
 	AlertManagers string
 	Aliases       []string
 	CaCert        string
+	DiscoveryURL  string
+	EtcdCerts
 	EtcdEndpoints string
+	EtcdScheme    string
 	EtcdServers   string
 	HostTCPPorts  []string
 	HostUDPPorts  []string
 	KatoState     string
 	MesosDNSPort  int
 	SMTP
+	Sealed       string
 	SystemdUnits []string
 	ZkServers    string
 }
 
+// EtcdCerts issued to a node, PEM encoded
+type EtcdCerts struct {
+	EtcdCA        string
+	EtcdServer    string
+	EtcdServerKey string
+	EtcdPeer      string
+	EtcdPeerKey   string
+	EtcdClient    string
+	EtcdClientKey string
+}
+
 // SMTP structure
 type SMTP struct {
 	Host string
@@ -86,8 +122,12 @@ type SMTP struct {
 	Pass string
 }
 
+// KMS encryption of the node secrets (overridden in tests):
+var seal = kato.SealKMS
+
 // Internal logic data
 type intData struct {
+	bundle    *bundle
 	fragments fragmentSlice
 	services  serviceMap
 	template  string
@@ -127,10 +167,16 @@ func zkServers(quorumCount int) (zkServers string) {
 // func: etcdServers
 //-----------------------------------------------------------------------------
 
-func etcdServers(quorumCount int) (etcdServers string) {
+// Static etcd members, addressed by IP when known as quorum-N names are not
+// resolvable before etcd is up.
+func etcdServers(scheme string, quorumCount int, quorumIPs []string) (etcdServers string) {
 	for i := 1; i <= quorumCount; i++ {
+		host := "quorum-" + strconv.Itoa(i)
+		if i <= len(quorumIPs) {
+			host = quorumIPs[i-1]
+		}
 		etcdServers = etcdServers +
-			"quorum-" + strconv.Itoa(i) + "=http://quorum-" + strconv.Itoa(i) + ":2380"
+			"quorum-" + strconv.Itoa(i) + "=" + scheme + "://" + host + ":2380"
 		if i != quorumCount {
 			etcdServers = etcdServers + ","
 		}
@@ -138,14 +184,37 @@ func etcdServers(quorumCount int) (etcdServers string) {
 	return
 }
 
+//-----------------------------------------------------------------------------
+// func: etcdScheme
+//-----------------------------------------------------------------------------
+
+func etcdScheme(tls bool) string {
+	if tls {
+		return "https"
+	}
+	return "http"
+}
+
+//-----------------------------------------------------------------------------
+// func: discoveryURL
+//-----------------------------------------------------------------------------
+
+// A bare token is looked up in the public discovery service.
+func discoveryURL(discovery, token string) string {
+	if discovery == "" && token != "" {
+		return kato.EtcdPublicDiscovery + token
+	}
+	return discovery
+}
+
 //-----------------------------------------------------------------------------
 // func: etcdEndpoints
 //-----------------------------------------------------------------------------
 
-func etcdEndpoints(quorumCount int) (etcdEndpoints string) {
+func etcdEndpoints(scheme string, quorumCount int) (etcdEndpoints string) {
 	for i := 1; i <= quorumCount; i++ {
 		etcdEndpoints = etcdEndpoints +
-			"http://quorum-" + strconv.Itoa(i) + ":2379"
+			scheme + "://quorum-" + strconv.Itoa(i) + ":2379"
 		if i != quorumCount {
 			etcdEndpoints = etcdEndpoints + ","
 		}
@@ -187,6 +256,96 @@ func smtpURLSplit(smtpURL string) (smtp SMTP) {
 	return
 }
 
+//-----------------------------------------------------------------------------
+// func: roleSecrets
+//-----------------------------------------------------------------------------
+
+// Secrets used by this node as shell variables. The DNS API key is used by
+// all nodes, SMTP and Slack only by the alertmanager of the masters.
+func (d *CmdData) roleSecrets() (env string) {
+
+	quote := func(s string) string {
+		return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+	}
+
+	if d.DNSApiKey != "" {
+		env += "KATO_DNS_API_KEY=" + quote(d.DNSApiKey) + "\n"
+	}
+
+	master := false
+	for _, role := range d.Roles {
+		master = master || role == "master"
+	}
+
+	if d.Prometheus && master {
+		if d.SMTPURL != "" {
+			env += "KATO_SMTP_HOST=" + quote(d.SMTP.Host) + "\n" +
+				"KATO_SMTP_PORT=" + quote(d.SMTP.Port) + "\n" +
+				"KATO_SMTP_USER=" + quote(d.SMTP.User) + "\n" +
+				"KATO_SMTP_PASS=" + quote(d.SMTP.Pass) + "\n"
+		}
+		if d.SlackWebhook != "" {
+			env += "KATO_SLACK_WEBHOOK=" + quote(d.SlackWebhook) + "\n"
+		}
+	}
+
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: keySecrets
+//-----------------------------------------------------------------------------
+
+// Private keys of this node as base64 shell variables, written to disk at
+// boot by kato-secrets.
+func (d *CmdData) keySecrets() (env string) {
+	for _, k := range []struct{ name, key string }{
+		{"KATO_ETCD_SERVER_KEY", d.EtcdServerKey},
+		{"KATO_ETCD_PEER_KEY", d.EtcdPeerKey},
+		{"KATO_ETCD_CLIENT_KEY", d.EtcdClientKey},
+		{"KATO_NODE_KEY", d.NodeKey},
+	} {
+		if k.key != "" {
+			env += k.name + "=" + base64.StdEncoding.EncodeToString([]byte(k.key)) + "\n"
+		}
+	}
+	return
+}
+
+//-----------------------------------------------------------------------------
+// func: sealSecrets
+//-----------------------------------------------------------------------------
+
+// Replace the secrets and private keys of this node with a KMS sealed blob,
+// decrypted at boot into /run/kato/secrets.env. Templates get references to
+// those variables and leave the keys out.
+func (d *CmdData) sealSecrets() {
+
+	env := d.roleSecrets() + d.keySecrets()
+	if d.KmsKeyID == "" || env == "" {
+		return
+	}
+
+	sealed, err := seal(d.Ec2Region, d.KmsKeyID, []byte(env))
+	if err != nil {
+		log.WithFields(log.Fields{"cmd": "udata", "id": d.KmsKeyID}).Fatal(err)
+	}
+
+	d.Sealed = sealed
+	d.EtcdServerKey, d.EtcdPeerKey, d.EtcdClientKey, d.NodeKey = "", "", "", ""
+	if d.SMTPURL != "" {
+		d.SMTP = SMTP{
+			Host: "${KATO_SMTP_HOST}",
+			Port: "${KATO_SMTP_PORT}",
+			User: "${KATO_SMTP_USER}",
+			Pass: "${KATO_SMTP_PASS}",
+		}
+	}
+	if d.SlackWebhook != "" {
+		d.SlackWebhook = "${KATO_SLACK_WEBHOOK}"
+	}
+}
+
 //-----------------------------------------------------------------------------
 // func: mesosDNSPort
 //-----------------------------------------------------------------------------
@@ -244,13 +403,49 @@ func (d *CmdData) listOfTags() (tags []string) {
 		tags = append(tags, "cacert")
 	}
 
+	if d.EtcdTLS {
+		tags = append(tags, "etcdtls")
+	}
+
+	if d.NodeCert != "" {
+		tags = append(tags, "pki")
+	}
+
+	if d.Sealed != "" {
+		tags = append(tags, "sealed")
+	}
+
 	if d.Prometheus {
 		tags = append(tags, "prometheus")
 	}
 
+	if d.DNSProvider != "" && d.DNSProvider != "none" {
+		tags = append(tags, "dns")
+	}
+
 	return
 }
 
+//-----------------------------------------------------------------------------
+// func: loadFragments
+//-----------------------------------------------------------------------------
+
+// The embedded defaults with the bundle overrides (if any) on top.
+func (d *CmdData) loadFragments() {
+
+	// Load the defaults:
+	if err := d.fragments.load(); err != nil {
+		log.WithField("cmd", "udata").Fatal(err)
+	}
+
+	// Apply the bundle overrides:
+	if d.bundle != nil {
+		if err := d.fragments.overlay(d.bundle.fragments); err != nil {
+			log.WithFields(log.Fields{"cmd": "udata", "id": d.FragmentsDir}).Fatal(err)
+		}
+	}
+}
+
 //-----------------------------------------------------------------------------
 // func: composeTemplate
 //-----------------------------------------------------------------------------
@@ -323,6 +518,43 @@ func (d *CmdData) renderIgnition() {
 	}
 }
 
+//-----------------------------------------------------------------------------
+// func: render
+//-----------------------------------------------------------------------------
+
+// Render the Ignition JSON into the userData buffer.
+func (d *CmdData) render() {
+
+	// Post-processed data:
+	d.ZkServers = zkServers(d.QuorumCount)
+	d.EtcdScheme = etcdScheme(d.EtcdTLS)
+	d.EtcdServers = etcdServers(d.EtcdScheme, d.QuorumCount, d.QuorumIPs)
+	d.DiscoveryURL = discoveryURL(d.EtcdDiscovery, d.EtcdToken)
+	if d.EtcdInitialCluster != "" {
+		d.EtcdServers, d.DiscoveryURL = d.EtcdInitialCluster, ""
+	}
+	d.EtcdEndpoints = etcdEndpoints(d.EtcdScheme, d.QuorumCount)
+	d.AlertManagers = alertManagers(d.MasterCount)
+	d.SMTP = smtpURLSplit(d.SMTPURL)
+	d.sealSecrets()
+	d.MesosDNSPort = mesosDNSPort(d.Roles)
+	d.Aliases = aliases(d.Roles, d.HostName)
+
+	// Systemd units and ports:
+	if err := d.services.load(d.Roles, groups(d.Prometheus), d.bundle); err != nil {
+		log.WithField("cmd", "udata").Fatal(err)
+	}
+	d.SystemdUnits = d.services.listUnits()
+	d.HostTCPPorts = d.services.listPorts("tcp")
+	d.HostUDPPorts = d.services.listPorts("udp")
+
+	// Template to ignition JSON:
+	d.loadFragments()   // Load all fragments.
+	d.composeTemplate() // Compose the template.
+	d.renderTemplate()  // Container linux config.
+	d.renderIgnition()  // Ignition JSON.
+}
+
 //-----------------------------------------------------------------------------
 // func: validateUserData
 //-----------------------------------------------------------------------------
@@ -376,28 +608,91 @@ func (d *CmdData) CmdRun() {
 
 	// Variables:
 	d.CaCert = readFile(d.CaCertPath)
-	d.KatoState = readFile(os.Getenv("HOME") + "/.kato/" + d.ClusterID + ".json")
-	d.ZkServers = zkServers(d.QuorumCount)
-	d.EtcdServers = etcdServers(d.QuorumCount)
-	d.EtcdEndpoints = etcdEndpoints(d.QuorumCount)
-	d.AlertManagers = alertManagers(d.MasterCount)
-	d.SMTP = smtpURLSplit(d.SMTPURL)
-	d.MesosDNSPort = mesosDNSPort(d.Roles)
-	d.Aliases = aliases(d.Roles, d.HostName)
+	if raw, err := kato.ReadState(d.ClusterID); err == nil {
+		d.KatoState = string(raw)
+	}
 
-	// Systemd units and ports:
-	d.services.load(d.Roles, groups(d.Prometheus))
-	d.SystemdUnits = d.services.listUnits()
-	d.HostTCPPorts = d.services.listPorts("tcp")
-	d.HostUDPPorts = d.services.listPorts("udp")
+	// Etcd certificates of this node:
+	if d.EtcdTLS {
+		if err := d.issueEtcdCerts(); err != nil {
+			log.WithFields(log.Fields{"cmd": "udata", "id": d.ClusterID}).Fatal(err)
+		}
+	}
+
+	// Fragments, services and roles overrides:
+	if d.FragmentsDir != "" {
+		b, err := loadBundle(d.FragmentsDir)
+		if err != nil {
+			log.WithFields(log.Fields{"cmd": "udata", "id": d.FragmentsDir}).Fatal(err)
+		}
+		d.bundle = b
+	}
 
 	// Template to ignition JSON:
-	d.fragments.load()  // Load all fragments.
-	d.composeTemplate() // Compose the template.
-	d.renderTemplate()  // Container linux config.
-	d.renderIgnition()  // Ignition JSON.
+	d.render()
 
 	// User data:
 	d.validateUserData() // Validate the generated user data.
 	d.outputUserData()   // Output user data to stdout.
 }
+
+//-----------------------------------------------------------------------------
+// func: issueEtcdCerts
+//-----------------------------------------------------------------------------
+
+// Issue the etcd server, peer and client certificates of this node. Members
+// are reached by name, by quorum-N and, when known, by their private IP. The
+// CA is the given one or the one stored with the cluster state.
+func (d *CmdData) issueEtcdCerts() error {
+
+	// Load the etcd CA:
+	var ca *pki.CA
+	var err error
+	if d.EtcdCaCert != "" {
+		ca, err = pki.LoadCA(d.EtcdCaCert, d.EtcdCaKey)
+	} else {
+		ca, err = pki.OpenEtcdCA(d.ClusterID)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Subject alternative names:
+	name := d.HostName + "-" + d.HostID
+	hosts := []string{name, name + "." + d.Domain, "localhost", "127.0.0.1"}
+	for _, role := range d.Roles {
+		if role != d.HostName {
+			hosts = append(hosts, role+"-"+d.HostID, role+"-"+d.HostID+"."+d.Domain)
+		}
+		if i, _ := strconv.Atoi(d.HostID); role == "quorum" && i > 0 && i <= len(d.QuorumIPs) {
+			hosts = append(hosts, d.QuorumIPs[i-1])
+		}
+	}
+	if d.PrivateIP != "" {
+		hosts = append(hosts, d.PrivateIP)
+	}
+
+	// Issue them:
+	cn := name + "." + d.Domain
+	both := []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	for _, c := range []struct {
+		cert, key *string
+		cn        string
+		hosts     []string
+		usage     []x509.ExtKeyUsage
+	}{
+		{&d.EtcdServer, &d.EtcdServerKey, cn, hosts, both},
+		{&d.EtcdPeer, &d.EtcdPeerKey, cn, hosts, both},
+		{&d.EtcdClient, &d.EtcdClientKey, "client." + cn, nil,
+			[]x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}},
+	} {
+		cert, key, err := ca.Issue(c.cn, c.hosts, c.usage...)
+		if err != nil {
+			return err
+		}
+		*c.cert, *c.key = string(cert), string(key)
+	}
+
+	d.EtcdCA = string(ca.CertPEM)
+	return nil
+}
@@ -175,236 +175,28 @@ func (s *serviceMap) listPorts(protocol string) (list []string) {
 // func: load
 //-----------------------------------------------------------------------------
 
-func (s *serviceMap) load(roles, groups []string) {
-
-	// Map roles to services:
-	roleServices := map[string][]string{
-
-		"quorum": {
-			"docker", "rexray", "etchosts", "zookeeper", "etcd-master", "rkt-api",
-			"cadvisor", "node-exporter", "zookeeper-exporter"},
-
-		"master": {
-			"docker", "rexray", "etchosts", "etcd-proxy", "calico", "mesos-dns",
-			"mesos-master", "marathon", "rkt-api", "cadvisor", "node-exporter",
-			"mesos-master-exporter", "confd", "alertmanager", "prometheus"},
-
-		"worker": {
-			"docker", "rexray", "etchosts", "etcd-proxy", "calico", "go-dnsmasq",
-			"marathon-lb", "mesos-agent", "rkt-api", "cadvisor", "node-exporter",
-			"mesos-agent-exporter", "haproxy-exporter"},
-
-		"border": {
-			"docker", "rexray", "etchosts", "etcd-proxy", "calico", "mongodb",
-			"pritunl", "rkt-api", "cadvisor", "node-exporter"},
+// The defaults bundle maps roles to services, the given bundle (if any)
+// overrides it.
+func (s *serviceMap) load(roles, groups []string, b *bundle) error {
+
+	// Load the defaults:
+	defaults, err := defaultBundle()
+	if err != nil {
+		return err
 	}
 
-	// Map services to config:
-	serviceConfig := map[string]service{
-
-		"docker": {
-			name:   "docker.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{2375, 2375}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"rexray": {
-			name:   "rexray.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{7979, 7979}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"etchosts": {
-			name:   "etchosts.timer",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{22, 22}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"etcd-proxy": {
-			name:   "etcd-member.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{2379, 2379}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"calico": {
-			name:   "calico.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{179, 179}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"zookeeper": {
-			name:   "zookeeper.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{2181, 2181}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{2888, 2888}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{3888, 3888}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"etcd-master": {
-			name:   "etcd-member.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{2379, 2380}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"mesos-dns": {
-			name:   "mesos-dns.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{53, 54}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{53, 54}, protocol: "udp", ingress: ""},
-			},
-		},
-
-		"mesos-master": {
-			name:   "mesos-master.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{5050, 5050}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"marathon": {
-			name:   "marathon.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{8080, 8080}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{9292, 9292}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"go-dnsmasq": {
-			name:   "go-dnsmasq.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{53, 53}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"marathon-lb": {
-			name:   "marathon-lb.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{80, 80}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{443, 443}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{9090, 9091}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{10000, 10100}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"mesos-agent": {
-			name:   "mesos-agent.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{5051, 5051}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"mongodb": {
-			name:   "mongodb.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{27017, 27017}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"pritunl": {
-			name:   "pritunl.service",
-			groups: []string{"base"},
-			ports: []portRange{
-				{interval: startEnd{80, 80}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{443, 443}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{9756, 9756}, protocol: "tcp", ingress: ""},
-				{interval: startEnd{18443, 18443}, protocol: "udp", ingress: ""},
-			},
-		},
-
-		"rkt-api": {
-			name:   "rkt-api.service",
-			groups: []string{"insight"},
-		},
-
-		"cadvisor": {
-			name:   "cadvisor.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{4194, 4194}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"node-exporter": {
-			name:   "node-exporter.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9101, 9101}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"zookeeper-exporter": {
-			name:   "zookeeper-exporter.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9103, 9103}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"mesos-master-exporter": {
-			name:   "mesos-master-exporter.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9104, 9104}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"mesos-agent-exporter": {
-			name:   "mesos-agent-exporter.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9105, 9105}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"haproxy-exporter": {
-			name:   "haproxy-exporter.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9102, 9102}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"confd": {
-			name:   "confd.service",
-			groups: []string{"insight"},
-		},
-
-		"alertmanager": {
-			name:   "alertmanager.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9093, 9093}, protocol: "tcp", ingress: ""},
-			},
-		},
-
-		"prometheus": {
-			name:   "prometheus.service",
-			groups: []string{"insight"},
-			ports: []portRange{
-				{interval: startEnd{9191, 9191}, protocol: "tcp", ingress: ""},
-			},
-		},
+	// Overlay the bundles:
+	roleServices := map[string][]string{}
+	serviceConfig := map[string]service{}
+	for _, o := range []*bundle{defaults, b} {
+		if o != nil {
+			for k, v := range o.roles {
+				roleServices[k] = v
+			}
+			for k, v := range o.services {
+				serviceConfig[k] = v
+			}
+		}
 	}
 
 	// Filter my services:
@@ -416,4 +208,6 @@ func (s *serviceMap) load(roles, groups []string) {
 			}
 		}
 	}
+
+	return nil
 }